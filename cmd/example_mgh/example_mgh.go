@@ -59,17 +59,13 @@ func main() {
 
 	fmt.Printf("%sRead Mgh with data dimensions (%d, %d, %d, %d) from file '%s'.\n", apptag, int(h.Dim1Length), int(h.Dim2Length), int(h.Dim3Length), int(h.Dim4Length), mghfile)
 
-	switch mgh.Data.MghDataType {
-	case neuro.MRI_UCHAR:
-		fmt.Printf("%sThe first value of the MGH data is %d.\n", apptag, mgh.Data.DataMriUchar[0])
-	case neuro.MRI_INT:
-		fmt.Printf("%sThe first value of the MGH data is %d.\n", apptag, mgh.Data.DataMriInt[0])
-	case neuro.MRI_FLOAT:
-		fmt.Printf("%sThe first value of the MGH data is %f.\n", apptag, mgh.Data.DataMriFloat[0])
-	case neuro.MRI_SHORT:
-		fmt.Printf("%sThe first value of the MGH data is %d.\n", apptag, mgh.Data.DataMriShort[0])
-	default:
-		fmt.Printf("%sUnknown data type %d in MGH file.\n", apptag, mgh.Data.MghDataType)
+	volume, err := neuro.NewVolumeFromMgh(mgh)
+	if err != nil {
+		fmt.Printf("%sFailed to create volume from Mgh data: '%s'.\n", apptag, err)
+		return
 	}
 
+	firstValue, _ := volume.At(0, 0, 0, 0)
+	fmt.Printf("%sThe first value of the MGH data is %f.\n", apptag, firstValue)
+
 }
@@ -0,0 +1,249 @@
+package neuro
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//go:embed data/FreeSurferColorLUT.txt
+var defaultColorLUTText string
+
+// ColorLUTEntry is an entry of a color lookup table, which gives a name and a color to an integer value, e.g., a structure in a segmentation volume.
+type ColorLUTEntry struct {
+	Index int32  // The integer value, e.g., the voxel value of the structure in 'aseg.mgz'.
+	Name  string // The name of the structure, e.g., 'Left-Hippocampus'.
+	R     int32  // The red channel of the color, in range 0 to 255.
+	G     int32  // The green channel of the color, in range 0 to 255.
+	B     int32  // The blue channel of the color, in range 0 to 255.
+	A     int32  // The fourth color channel, in range 0 to 255. FreeSurfer treats it as transparency, and it is typically 0.
+}
+
+// ColorLUT is a color lookup table, like FreeSurferColorLUT.txt, which maps the integer values of segmentation volumes like 'aseg.mgz' to structure names and colors.
+//
+// Create one with ReadColorLUT, ColorLUTFromColortable, NewColorLUT or DefaultColorLUT.
+type ColorLUT struct {
+	entries []ColorLUTEntry
+	byIndex map[int32]int
+	byName  map[string]int
+}
+
+// NewColorLUT creates a color lookup table from a list of entries.
+//
+// Parameters:
+//   - entries: the entries. The indices must be unique. If several entries have the same name, ByName returns the first one.
+//
+// Returns:
+//   - ColorLUT: the color lookup table
+//   - error: an error if an index occurs more than once
+func NewColorLUT(entries []ColorLUTEntry) (ColorLUT, error) {
+	lut := ColorLUT{entries: make([]ColorLUTEntry, len(entries)), byIndex: make(map[int32]int, len(entries)), byName: make(map[string]int, len(entries))}
+	copy(lut.entries, entries)
+	for idx, entry := range lut.entries {
+		if _, ok := lut.byIndex[entry.Index]; ok {
+			return ColorLUT{}, fmt.Errorf("NewColorLUT: duplicate index %d ('%s').", entry.Index, entry.Name)
+		}
+		lut.byIndex[entry.Index] = idx
+		if _, ok := lut.byName[entry.Name]; !ok {
+			lut.byName[entry.Name] = idx
+		}
+	}
+	return lut, nil
+}
+
+// Len returns the number of entries.
+func (lut ColorLUT) Len() int {
+	return len(lut.entries)
+}
+
+// Entries returns a copy of the entries, in the order of the file.
+func (lut ColorLUT) Entries() []ColorLUTEntry {
+	return append([]ColorLUTEntry{}, lut.entries...)
+}
+
+// ByIndex returns the entry for an integer value, e.g., a voxel value of a segmentation volume.
+//
+// Parameters:
+//   - index: the integer value
+//
+// Returns:
+//   - ColorLUTEntry: the entry
+//   - bool: whether the table contains an entry for the value
+func (lut ColorLUT) ByIndex(index int32) (ColorLUTEntry, bool) {
+	idx, ok := lut.byIndex[index]
+	if !ok {
+		return ColorLUTEntry{}, false
+	}
+	return lut.entries[idx], true
+}
+
+// ByName returns the entry for a structure name.
+//
+// Parameters:
+//   - name: the structure name, e.g., 'Left-Hippocampus'. The comparison is case-sensitive.
+//
+// Returns:
+//   - ColorLUTEntry: the entry
+//   - bool: whether the table contains an entry with that name
+func (lut ColorLUT) ByName(name string) (ColorLUTEntry, bool) {
+	idx, ok := lut.byName[name]
+	if !ok {
+		return ColorLUTEntry{}, false
+	}
+	return lut.entries[idx], true
+}
+
+// Colortable converts the color lookup table to a colortable, e.g., to write an annotation with WriteFsAnnot. The indices become the structure IDs.
+func (lut ColorLUT) Colortable() FsColortable {
+	var ct FsColortable
+	for _, entry := range lut.entries {
+		ct.StructureId = append(ct.StructureId, entry.Index)
+		ct.Name = append(ct.Name, entry.Name)
+		ct.R = append(ct.R, entry.R)
+		ct.G = append(ct.G, entry.G)
+		ct.B = append(ct.B, entry.B)
+		ct.A = append(ct.A, entry.A)
+	}
+	return ct
+}
+
+// ColorLUTFromColortable creates a color lookup table from a colortable, e.g., the colortable embedded in an annotation read with ReadFsAnnot.
+//
+// Parameters:
+//   - ct: the colortable. The structure IDs become the indices of the entries.
+//
+// Returns:
+//   - ColorLUT: the color lookup table
+//   - error: an error if the slices of the colortable have different lengths or a structure ID occurs more than once
+func ColorLUTFromColortable(ct FsColortable) (ColorLUT, error) {
+	n := ct.NumEntries()
+	if len(ct.StructureId) != n || len(ct.R) != n || len(ct.G) != n || len(ct.B) != n || len(ct.A) != n {
+		return ColorLUT{}, fmt.Errorf("ColorLUTFromColortable: the slices of the colortable must all have length %d (the number of names).", n)
+	}
+	entries := make([]ColorLUTEntry, n)
+	for idx := range entries {
+		entries[idx] = ColorLUTEntry{Index: ct.StructureId[idx], Name: ct.Name[idx], R: ct.R[idx], G: ct.G[idx], B: ct.B[idx], A: ct.A[idx]}
+	}
+	return NewColorLUT(entries)
+}
+
+// DefaultColorLUT returns the color lookup table bundled with this package.
+//
+// It is a subset of FreeSurferColorLUT.txt from FreeSurfer 7, with the structures of the subcortical segmentation ('aseg.mgz') and the
+// cortical regions of the Desikan-Killiany atlas as used in 'aparc+aseg.mgz' (indices 1000 to 1035 and 2000 to 2035). Use ReadColorLUT
+// to read the full table from '$FREESURFER_HOME/FreeSurferColorLUT.txt' if you need other entries.
+func DefaultColorLUT() ColorLUT {
+	lut, err := readColorLUT(strings.NewReader(defaultColorLUTText))
+	if err != nil {
+		panic(fmt.Sprintf("DefaultColorLUT: the bundled color lookup table is invalid: %s", err))
+	}
+	return lut
+}
+
+// readColorLUT reads a color lookup table in FreeSurferColorLUT format from r.
+//
+// Each line contains the index, the name and the R, G, B and A color values, separated by whitespace. The A value is optional.
+// Empty lines and lines starting with '#' are ignored.
+//
+// Parameters:
+//   - r: the reader
+//
+// Returns:
+//   - ColorLUT: the color lookup table
+//   - error: an error if one occurred
+func readColorLUT(r io.Reader) (ColorLUT, error) {
+	lines, err := readLinesFrom(r)
+	if err != nil {
+		return ColorLUT{}, err
+	}
+
+	var entries []ColorLUTEntry
+	for lineIdx, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 5 && len(fields) != 6 {
+			return ColorLUT{}, fmt.Errorf("line %d contains %d fields, expected index, name, R, G, B and optionally A.", lineIdx+1, len(fields))
+		}
+
+		values := make([]int32, 5)
+		for fieldIdx, field := range append([]string{fields[0]}, fields[2:]...) {
+			v, err := strconv.ParseInt(field, 10, 32)
+			if err != nil {
+				return ColorLUT{}, fmt.Errorf("line %d: invalid integer value '%s': %w", lineIdx+1, field, err)
+			}
+			values[fieldIdx] = int32(v)
+		}
+		entries = append(entries, ColorLUTEntry{Index: values[0], Name: fields[1], R: values[1], G: values[2], B: values[3], A: values[4]})
+	}
+
+	if Verbosity > 0 {
+		fmt.Printf("readColorLUT: read %d entries.\n", len(entries))
+	}
+	return NewColorLUT(entries)
+}
+
+// ReadColorLUT reads a color lookup table in FreeSurferColorLUT format, e.g., '$FREESURFER_HOME/FreeSurferColorLUT.txt'.
+//
+// The format is a text file with one entry per line, containing the index, the name and the R, G, B and A color values, separated by whitespace.
+// Empty lines and comment lines starting with '#' are ignored.
+//
+// Parameters:
+//   - filepath: path to the color lookup table file
+//
+// Returns:
+//   - ColorLUT: the color lookup table
+//   - error: an error if one occurred
+func ReadColorLUT(filepath string) (ColorLUT, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return ColorLUT{}, fmt.Errorf("ReadColorLUT: could not open color lookup table file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	lut, err := readColorLUT(file)
+	if err != nil {
+		return lut, fmt.Errorf("ReadColorLUT: failed to read color lookup table file '%s': %w", filepath, err)
+	}
+	return lut, nil
+}
+
+// ReadColorLUTFS reads a color lookup table in FreeSurferColorLUT format from the file system fsys, see ReadColorLUT.
+//
+// Parameters:
+//   - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//   - name: the name of the color lookup table file in fsys
+//
+// Returns:
+//   - ColorLUT: the color lookup table
+//   - error: an error if one occurred
+func ReadColorLUTFS(fsys fs.FS, name string) (ColorLUT, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return ColorLUT{}, fmt.Errorf("ReadColorLUTFS: could not open color lookup table file '%s': %w", name, err)
+	}
+	defer file.Close()
+
+	lut, err := readColorLUT(file)
+	if err != nil {
+		return lut, fmt.Errorf("ReadColorLUTFS: failed to read color lookup table file '%s': %w", name, err)
+	}
+	return lut, nil
+}
+
+// ReadColorLUTFrom reads a color lookup table in FreeSurferColorLUT format from r, see ReadColorLUT.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File or a *strings.Reader
+//
+// Returns:
+//   - ColorLUT: the color lookup table
+//   - error: an error if one occurred
+func ReadColorLUTFrom(r io.Reader) (ColorLUT, error) {
+	return readColorLUT(r)
+}
@@ -0,0 +1,129 @@
+package neuro
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testColorLUTText = `#$Id: FreeSurferColorLUT.txt $
+
+#No. Label Name:                            R   G   B   A
+
+0   Unknown                                 0   0   0   0
+17  Left-Hippocampus                        220 216 20  0
+   53  Right-Hippocampus	220 216 20
+1024    ctx-lh-precentral                   60  20  220 0
+`
+
+func TestReadColorLUTFrom(t *testing.T) {
+	lut, err := ReadColorLUTFrom(strings.NewReader(testColorLUTText))
+	if err != nil {
+		t.Fatalf("ReadColorLUTFrom failed: %v", err)
+	}
+	if lut.Len() != 4 {
+		t.Fatalf("got %d entries, wanted 4", lut.Len())
+	}
+
+	entry, ok := lut.ByIndex(17)
+	if !ok {
+		t.Fatalf("ByIndex(17) found no entry")
+	}
+	if diff := cmp.Diff(ColorLUTEntry{Index: 17, Name: "Left-Hippocampus", R: 220, G: 216, B: 20, A: 0}, entry); diff != "" {
+		t.Error(diff)
+	}
+
+	// The A column is optional, and the line may be indented with tabs and spaces.
+	entry, ok = lut.ByName("Right-Hippocampus")
+	if !ok || entry.Index != 53 || entry.A != 0 {
+		t.Errorf("got entry %v (found=%t) for 'Right-Hippocampus', wanted index 53", entry, ok)
+	}
+
+	if _, ok := lut.ByIndex(18); ok {
+		t.Errorf("ByIndex(18) found an entry, expected none")
+	}
+	if _, ok := lut.ByName("left-hippocampus"); ok {
+		t.Errorf("ByName is expected to be case-sensitive")
+	}
+
+	if diff := cmp.Diff([]int32{0, 17, 53, 1024}, lut.Colortable().StructureId); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReadColorLUTInvalid(t *testing.T) {
+	invalid := map[string]string{
+		"too few fields":  "0 Unknown 0 0\n",
+		"invalid value":   "0 Unknown 0 0 zero 0\n",
+		"duplicate index": "0 Unknown 0 0 0 0\n0 Again 1 1 1 0\n",
+	}
+	for name, text := range invalid {
+		if _, err := ReadColorLUTFrom(strings.NewReader(text)); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestReadColorLUTFileAndFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lut.txt"), []byte(testColorLUTText), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	lut, err := ReadColorLUT(filepath.Join(dir, "lut.txt"))
+	if err != nil || lut.Len() != 4 {
+		t.Errorf("ReadColorLUT: got %d entries and error %v, wanted 4 entries", lut.Len(), err)
+	}
+	lut, err = ReadColorLUTFS(os.DirFS(dir), "lut.txt")
+	if err != nil || lut.Len() != 4 {
+		t.Errorf("ReadColorLUTFS: got %d entries and error %v, wanted 4 entries", lut.Len(), err)
+	}
+	if _, err := ReadColorLUT(filepath.Join(dir, "no_such_file.txt")); err == nil {
+		t.Errorf("expected error for missing file, got nil")
+	}
+}
+
+func TestColorLUTFromAnnotColortable(t *testing.T) {
+	lut, err := ColorLUTFromColortable(getTestFsColortable())
+	if err != nil {
+		t.Fatalf("ColorLUTFromColortable failed: %v", err)
+	}
+	entry, ok := lut.ByName("precentral")
+	if !ok || entry.Index != 2 || entry.R != 20 || entry.G != 30 || entry.B != 40 {
+		t.Errorf("got entry %v (found=%t) for 'precentral'", entry, ok)
+	}
+
+	ct := lut.Colortable()
+	ct.Filename = getTestFsColortable().Filename
+	if diff := cmp.Diff(getTestFsColortable(), ct); diff != "" {
+		t.Error(diff)
+	}
+
+	invalid := getTestFsColortable()
+	invalid.R = invalid.R[:1]
+	if _, err := ColorLUTFromColortable(invalid); err == nil {
+		t.Errorf("expected error for invalid colortable, got nil")
+	}
+}
+
+func TestDefaultColorLUT(t *testing.T) {
+	lut := DefaultColorLUT()
+	for name, index := range map[string]int32{"Unknown": 0, "Left-Hippocampus": 17, "Right-Amygdala": 54, "ctx-lh-precentral": 1024, "ctx-rh-insula": 2035} {
+		entry, ok := lut.ByName(name)
+		if !ok || entry.Index != index {
+			t.Errorf("got entry %v (found=%t) for '%s', wanted index %d", entry, ok, name, index)
+		}
+	}
+}
+
+func ExampleDefaultColorLUT() {
+	lut := DefaultColorLUT()
+
+	// Look up the name of a voxel value from a segmentation like aseg.mgz.
+	entry, _ := lut.ByIndex(17)
+	fmt.Printf("%d: %s, color (%d, %d, %d)\n", entry.Index, entry.Name, entry.R, entry.G, entry.B)
+	// Output: 17: Left-Hippocampus, color (220, 216, 20)
+}
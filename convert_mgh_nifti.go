@@ -0,0 +1,141 @@
+package neuro
+
+import (
+	"fmt"
+	"math"
+)
+
+// ConvertMghToNifti converts MGH data to a Nifti1 struct, which can be written with WriteNifti1.
+//
+// The geometry of the MGH header (the voxel sizes, the direction cosines Mdc and the center coordinates Pxyz_c) is stored as both
+// the sform and the qform of the NIfTI header, so the NIfTI vox2ras matrix is the one returned by MghHeader.Vox2Ras. Both use
+// the code NIFTI_XFORM_SCANNER_ANAT. If the MGH footer contains scan parameters, the repetition time is stored in Pixdim[4] in ms.
+// The voxel data is shared with mgh, not copied.
+//
+// Parameters:
+//   - mgh: the MGH data, e.g., from ReadFsMgh
+//
+// Returns:
+//   - Nifti1: the NIfTI-1 header and the voxel data
+//   - error: an error if one occurred, e.g., if the data type is not supported or the orientation is invalid
+func ConvertMghToNifti(mgh Mgh) (Nifti1, error) {
+	var nii Nifti1
+
+	volume, err := NewVolumeFromMgh(mgh)
+	if err != nil {
+		return nii, fmt.Errorf("ConvertMghToNifti: %w", err)
+	}
+	nii.Volume = volume
+
+	vox2ras := mgh.Header.Vox2Ras()
+	quatern, qoffset, pixdim, err := getNiftiQuaternion(vox2ras)
+	if err != nil {
+		return nii, fmt.Errorf("ConvertMghToNifti: invalid orientation in MGH header: %w", err)
+	}
+
+	hdr := &nii.Header
+	hdr.SformCode = NIFTI_XFORM_SCANNER_ANAT
+	for col := 0; col < 4; col++ {
+		hdr.SrowX[col] = float32(vox2ras[0][col])
+		hdr.SrowY[col] = float32(vox2ras[1][col])
+		hdr.SrowZ[col] = float32(vox2ras[2][col])
+	}
+
+	hdr.QformCode = NIFTI_XFORM_SCANNER_ANAT
+	hdr.QuaternB, hdr.QuaternC, hdr.QuaternD = float32(quatern[0]), float32(quatern[1]), float32(quatern[2])
+	hdr.QoffsetX, hdr.QoffsetY, hdr.QoffsetZ = float32(qoffset[0]), float32(qoffset[1]), float32(qoffset[2])
+	for idx := 0; idx < 4; idx++ {
+		hdr.Pixdim[idx] = float32(pixdim[idx])
+	}
+
+	hdr.XyztUnits = NIFTI_UNITS_MM
+	if mgh.Footer.HasScanParameters && mgh.Footer.TR > 0 {
+		hdr.Pixdim[4] = mgh.Footer.TR
+		hdr.XyztUnits |= NIFTI_UNITS_MSEC
+	}
+
+	shape := volume.Shape()
+	hdr.Dim[0] = 3
+	if shape[3] > 1 {
+		hdr.Dim[0] = 4
+	}
+	for idx := 0; idx < 4; idx++ {
+		if shape[idx] > math.MaxInt16 {
+			return nii, fmt.Errorf("ConvertMghToNifti: the length %d of dimension %d exceeds the NIfTI-1 maximum of %d.", shape[idx], idx+1, math.MaxInt16)
+		}
+		hdr.Dim[idx+1] = int16(shape[idx])
+	}
+	hdr.Datatype, hdr.Bitpix, err = getNiftiDataTypeForMri(volume.DataType())
+	if err != nil {
+		return nii, fmt.Errorf("ConvertMghToNifti: %w", err)
+	}
+	hdr.SizeofHdr = 348
+	hdr.VoxOffset = 352
+	copy(hdr.Magic[:], "n+1\x00")
+	copy(hdr.Descrip[:], "FreeSurfer MGH")
+	return nii, nil
+}
+
+// ConvertNiftiToMgh converts a Nifti1 struct to MGH data, which can be written with WriteFsMgh.
+//
+// The vox2ras matrix of the NIfTI header (see Nifti1Header.Vox2Ras) is decomposed into the voxel sizes, the direction cosines Mdc and
+// the center coordinates Pxyz_c of the MGH header, so MghHeader.Vox2Ras of the result is the NIfTI vox2ras matrix. A repetition time
+// in Pixdim[4] is stored in ms in the footer. The voxel data is shared with nii, not copied.
+//
+// Parameters:
+//   - nii: the NIfTI-1 data, e.g., from ReadNifti1
+//
+// Returns:
+//   - Mgh: the MGH header, data and footer
+//   - error: an error if one occurred, e.g., if the volume is empty or the vox2ras matrix is degenerate
+func ConvertNiftiToMgh(nii Nifti1) (Mgh, error) {
+	var mgh Mgh
+
+	if nii.Volume.Len() == 0 {
+		return mgh, fmt.Errorf("ConvertNiftiToMgh: the volume contains no data.")
+	}
+	shape := nii.Volume.Shape()
+
+	hdr := &mgh.Header
+	hdr.MghVersion = 1
+	hdr.Dim1Length, hdr.Dim2Length, hdr.Dim3Length, hdr.Dim4Length = int32(shape[0]), int32(shape[1]), int32(shape[2]), int32(shape[3])
+	hdr.MghDataType = nii.Volume.DataType()
+	hdr.RasGoodFlag = 1
+
+	vox2ras := nii.Header.Vox2Ras()
+	var delta [3]float64
+	for col := 0; col < 3; col++ {
+		delta[col] = math.Sqrt(vox2ras[0][col]*vox2ras[0][col] + vox2ras[1][col]*vox2ras[1][col] + vox2ras[2][col]*vox2ras[2][col])
+		if delta[col] == 0 {
+			return mgh, fmt.Errorf("ConvertNiftiToMgh: column %d of the vox2ras matrix is zero.", col)
+		}
+		for row := 0; row < 3; row++ {
+			hdr.Mdc[col*3+row] = float32(vox2ras[row][col] / delta[col])
+		}
+	}
+	hdr.XSize, hdr.YSize, hdr.ZSize = float32(delta[0]), float32(delta[1]), float32(delta[2])
+
+	// The center of the volume, see getMghVox2Ras.
+	pcrs_c := [3]float64{float64(shape[0]) / 2.0, float64(shape[1]) / 2.0, float64(shape[2]) / 2.0}
+	for row := 0; row < 3; row++ {
+		p := vox2ras[row][3]
+		for col := 0; col < 3; col++ {
+			p += vox2ras[row][col] * pcrs_c[col]
+		}
+		hdr.Pxyz_c[row] = float32(p)
+	}
+
+	mgh.Data = nii.Volume.Data()
+
+	if tr := float64(nii.Header.Pixdim[4]); tr > 0 {
+		switch nii.Header.XyztUnits & 0x38 {
+		case NIFTI_UNITS_SEC:
+			tr *= 1000.0
+		case NIFTI_UNITS_USEC:
+			tr /= 1000.0
+		}
+		mgh.Footer.HasScanParameters = true
+		mgh.Footer.TR = float32(tr)
+	}
+	return mgh, nil
+}
@@ -0,0 +1,123 @@
+package neuro
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConvertMghToNiftiAndBack(t *testing.T) {
+	mgh, err := ReadFsMgh("testdata/brain.mgz", "auto")
+	if err != nil {
+		t.Fatalf("ReadFsMgh failed: %v", err)
+	}
+
+	nii, err := ConvertMghToNifti(mgh)
+	if err != nil {
+		t.Fatalf("ConvertMghToNifti failed: %v", err)
+	}
+	// Both the sform and the qform must describe the MGH geometry.
+	checkMatrixAlmostEqual(t, "sform", nii.Header.SformMatrix(), mgh.Header.Vox2Ras())
+	checkMatrixAlmostEqual(t, "qform", nii.Header.QformMatrix(), mgh.Header.Vox2Ras())
+	if nii.Header.Pixdim[4] != 2300 || nii.Header.XyztUnits != NIFTI_UNITS_MM|NIFTI_UNITS_MSEC {
+		t.Errorf("got Pixdim[4]=%f, XyztUnits=%d, wanted 2300 and %d", nii.Header.Pixdim[4], nii.Header.XyztUnits, NIFTI_UNITS_MM|NIFTI_UNITS_MSEC)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteNifti1To(&buf, nii, true); err != nil {
+		t.Fatalf("WriteNifti1To failed: %v", err)
+	}
+	reread, err := ReadNifti1From(&buf)
+	if err != nil {
+		t.Fatalf("ReadNifti1From failed: %v", err)
+	}
+
+	// Use only the qform, to check that it alone is enough to restore the geometry.
+	reread.Header.SformCode = 0
+	back, err := ConvertNiftiToMgh(reread)
+	if err != nil {
+		t.Fatalf("ConvertNiftiToMgh failed: %v", err)
+	}
+
+	hdr, backHdr := mgh.Header, back.Header
+	if diff := cmp.Diff([]int32{hdr.Dim1Length, hdr.Dim2Length, hdr.Dim3Length, hdr.Dim4Length, hdr.MghDataType}, []int32{backHdr.Dim1Length, backHdr.Dim2Length, backHdr.Dim3Length, backHdr.Dim4Length, backHdr.MghDataType}); diff != "" {
+		t.Error(diff)
+	}
+	if backHdr.RasGoodFlag != 1 {
+		t.Errorf("got RasGoodFlag %d, wanted 1", backHdr.RasGoodFlag)
+	}
+	for idx, want := range []float32{hdr.XSize, hdr.YSize, hdr.ZSize} {
+		got := []float32{backHdr.XSize, backHdr.YSize, backHdr.ZSize}[idx]
+		if !almostEqualF64(float64(got), float64(want), 1e-4) {
+			t.Errorf("got voxel size %f for axis %d, wanted %f", got, idx, want)
+		}
+	}
+	for idx := range hdr.Mdc {
+		if !almostEqualF64(float64(backHdr.Mdc[idx]), float64(hdr.Mdc[idx]), 1e-4) {
+			t.Errorf("got Mdc value %f at index %d, wanted %f", backHdr.Mdc[idx], idx, hdr.Mdc[idx])
+		}
+	}
+	for idx := range hdr.Pxyz_c {
+		if !almostEqualF64(float64(backHdr.Pxyz_c[idx]), float64(hdr.Pxyz_c[idx]), 1e-3) {
+			t.Errorf("got Pxyz_c value %f at index %d, wanted %f", backHdr.Pxyz_c[idx], idx, hdr.Pxyz_c[idx])
+		}
+	}
+	checkMatrixAlmostEqual(t, "Vox2Ras", backHdr.Vox2Ras(), hdr.Vox2Ras())
+
+	if !bytes.Equal(back.Data.DataMriUchar, mgh.Data.DataMriUchar) {
+		t.Errorf("the voxel data differs after the round trip")
+	}
+	if !back.Footer.HasScanParameters || back.Footer.TR != 2300 {
+		t.Errorf("got TR %f, wanted 2300", back.Footer.TR)
+	}
+}
+
+func TestConvertNiftiToMghTimeUnits(t *testing.T) {
+	nii := getTestNifti1()
+	nii.Header.Pixdim[4] = 2.5
+	nii.Header.XyztUnits = NIFTI_UNITS_MM | NIFTI_UNITS_SEC
+
+	mgh, err := ConvertNiftiToMgh(nii)
+	if err != nil {
+		t.Fatalf("ConvertNiftiToMgh failed: %v", err)
+	}
+	if mgh.Footer.TR != 2500 {
+		t.Errorf("got TR %f, wanted 2500", mgh.Footer.TR)
+	}
+	checkMatrixAlmostEqual(t, "Vox2Ras", mgh.Header.Vox2Ras(), nii.Header.Vox2Ras())
+
+	if _, err := ConvertNiftiToMgh(Nifti1{}); err == nil {
+		t.Errorf("expected error for empty volume, got nil")
+	}
+}
+
+func TestGetNiftiQuaternionRoundTrip(t *testing.T) {
+	// A rotation of 180 degrees around the x axis with a flipped third axis, and one with a rotation of about 90 degrees around z.
+	affines := [][4][4]float64{
+		{{1, 0, 0, 5}, {0, -2, 0, 6}, {0, 0, 3, 7}, {0, 0, 0, 1}},
+		{{0, -1, 0, 0}, {1, 0, 0, 0}, {0, 0, -1.5, 0}, {0, 0, 0, 1}},
+		{{-1, 0, 0, 128}, {0, 0, 1, -128}, {0, -1, 0, 128}, {0, 0, 0, 1}},
+	}
+	for idx, affine := range affines {
+		quatern, qoffset, pixdim, err := getNiftiQuaternion(affine)
+		if err != nil {
+			t.Fatalf("getNiftiQuaternion failed: %v", err)
+		}
+		checkMatrixAlmostEqual(t, fmt.Sprintf("affine %d", idx), getNiftiQformMatrix(quatern, qoffset, pixdim), affine)
+	}
+
+	if _, _, _, err := getNiftiQuaternion([4][4]float64{{1, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 1}}); err == nil {
+		t.Errorf("expected error for degenerate affine, got nil")
+	}
+}
+
+func ExampleConvertMghToNifti() {
+	mgh, _ := ReadFsMgh("testdata/brain.mgz", "auto")
+	nii, _ := ConvertMghToNifti(mgh)
+	// Write it with WriteNifti1("brain.nii.gz", nii, "auto").
+
+	fmt.Printf("NIfTI dimensions: %v, sform code: %d\n", nii.Header.Dim, nii.Header.SformCode)
+	// Output: NIfTI dimensions: [3 256 256 256 1 0 0 0], sform code: 1
+}
@@ -0,0 +1,191 @@
+package neuro
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Tag type codes used by FreeSurfer for the tagged data blocks at the end of MGH and surface files.
+// The names follow the FreeSurfer source code (utils/tags.h).
+const (
+	TAG_OLD_COLORTABLE         int32 = 1
+	TAG_OLD_USEREALRAS         int32 = 2
+	TAG_CMDLINE                int32 = 3
+	TAG_USEREALRAS             int32 = 4
+	TAG_COLORTABLE             int32 = 5
+	TAG_GCAMORPH_GEOM          int32 = 10
+	TAG_GCAMORPH_TYPE          int32 = 11
+	TAG_GCAMORPH_LABELS        int32 = 12
+	TAG_OLD_SURF_GEOM          int32 = 20
+	TAG_SURF_GEOM              int32 = 21
+	TAG_OLD_MGH_XFORM          int32 = 30
+	TAG_MGH_XFORM              int32 = 31
+	TAG_GROUP_AVG_SURFACE_AREA int32 = 32
+	TAG_AUTO_ALIGN             int32 = 33
+	TAG_SCALAR_DOUBLE          int32 = 40
+	TAG_PEDIR                  int32 = 41
+	TAG_MRI_FRAME              int32 = 42
+	TAG_FIELDSTRENGTH          int32 = 43
+	TAG_ORIG_RAS2VOX           int32 = 44
+)
+
+// FsTag models a tagged data block, as found at the end of FreeSurfer MGH and surface files.
+//
+// Tags are used to store meta data like the command lines used to create a file, or the path of the talairach transform.
+// The interpretation of the data depends on the tag type.
+type FsTag struct {
+	TagType int32  // The tag type code, e.g., TAG_CMDLINE. See the TAG_* constants in this package.
+	Data    []byte // The raw data of the tag. For text tags like TAG_CMDLINE, this is a string that may contain trailing null bytes.
+}
+
+// String returns the data of a text tag as a string, with trailing null bytes removed.
+//
+// Returns:
+//   - string: the tag data as a string
+func (tag FsTag) String() string {
+	return strings.TrimRight(string(tag.Data), "\x00")
+}
+
+// fsTagLengthFieldSize returns the size in bytes of the length field that follows the tag type code of a tag.
+//
+// Parameters:
+//   - tagType: the tag type code
+//
+// Returns:
+//   - int: the size of the length field in bytes, 4 for the old MGH transform tag, 0 for old tags without length field, and 8 for all others.
+func fsTagLengthFieldSize(tagType int32) int {
+	switch tagType {
+	case TAG_OLD_MGH_XFORM:
+		return 4
+	case TAG_OLD_SURF_GEOM, TAG_OLD_USEREALRAS, TAG_OLD_COLORTABLE:
+		return 0
+	default:
+		return 8
+	}
+}
+
+// readFsTags reads tagged data blocks from r until the end of the input is reached.
+//
+// Like FreeSurfer, this function stops at a truncated tag and returns the tags read before it.
+//
+// Parameters:
+//   - r: the reader, positioned at the start of the first tag
+//
+// Returns:
+//   - []FsTag: the tags that were read
+//   - error: an error if one occurred, e.g., if the length of a tag is invalid
+func readFsTags(r io.Reader) ([]FsTag, error) {
+	endian := binary.BigEndian
+	var tags []FsTag
+
+	for {
+		var tagType int32
+		if err := binary.Read(r, endian, &tagType); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return tags, fmt.Errorf("readFsTags: failed to read tag type of tag %d: %w", len(tags), err)
+		}
+		if tagType == 0 {
+			break
+		}
+
+		tag, err := readFsTagData(r, tagType, len(tags))
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				if Verbosity >= 1 {
+					fmt.Printf("readFsTags: Ignoring truncated tag %d with type %d.\n", len(tags), tagType)
+				}
+				break
+			}
+			return tags, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// readFsTagData reads the length field and the data of a tag from r, after the tag type code has been read.
+//
+// Parameters:
+//   - r: the reader, positioned after the tag type code
+//   - tagType: the tag type code
+//   - tagIndex: the index of the tag in the file, for error messages
+//
+// Returns:
+//   - FsTag: the tag
+//   - error: an error if one occurred, e.g., if the data of the tag is truncated
+func readFsTagData(r io.Reader, tagType int32, tagIndex int) (FsTag, error) {
+	endian := binary.BigEndian
+
+	var length int64
+	switch fsTagLengthFieldSize(tagType) {
+	case 4:
+		var length32 int32
+		if err := binary.Read(r, endian, &length32); err != nil {
+			return FsTag{}, fmt.Errorf("readFsTags: failed to read length of tag %d with type %d: %w", tagIndex, tagType, err)
+		}
+		length = int64(length32)
+	case 8:
+		if err := binary.Read(r, endian, &length); err != nil {
+			return FsTag{}, fmt.Errorf("readFsTags: failed to read length of tag %d with type %d: %w", tagIndex, tagType, err)
+		}
+	}
+	if length < 0 {
+		return FsTag{}, fmt.Errorf("readFsTags: invalid length %d of tag %d with type %d.", length, tagIndex, tagType)
+	}
+
+	// Do not trust the length field for the allocation, it may be garbage in broken files.
+	data, err := io.ReadAll(io.LimitReader(r, length))
+	if err == nil && int64(len(data)) != length {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return FsTag{}, fmt.Errorf("readFsTags: failed to read %d bytes of data of tag %d with type %d: %w", length, tagIndex, tagType, err)
+	}
+	tag := FsTag{TagType: tagType, Data: data}
+
+	if Verbosity >= 2 {
+		fmt.Printf("readFsTags: Read tag of type %d with %d bytes of data.\n", tag.TagType, len(tag.Data))
+	}
+	return tag, nil
+}
+
+// writeFsTags writes tagged data blocks to w, in the format read by readFsTags.
+//
+// Parameters:
+//   - w: the writer
+//   - tags: the tags to write
+//
+// Returns:
+//   - error: an error if one occurred
+func writeFsTags(w io.Writer, tags []FsTag) error {
+	endian := binary.BigEndian
+
+	for idx, tag := range tags {
+		if err := binary.Write(w, endian, tag.TagType); err != nil {
+			return err
+		}
+		switch fsTagLengthFieldSize(tag.TagType) {
+		case 4:
+			if err := binary.Write(w, endian, int32(len(tag.Data))); err != nil {
+				return err
+			}
+		case 8:
+			if err := binary.Write(w, endian, int64(len(tag.Data))); err != nil {
+				return err
+			}
+		default:
+			if len(tag.Data) > 0 {
+				return fmt.Errorf("writeFsTags: tag %d has type %d, which cannot hold data, but has %d bytes of data.", idx, tag.TagType, len(tag.Data))
+			}
+		}
+		if _, err := w.Write(tag.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
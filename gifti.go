@@ -0,0 +1,735 @@
+package neuro
+
+// Related software: nibabel for Python, see https://nipy.org/nibabel/ and the GIFTI format specification at
+// https://www.nitrc.org/projects/gifti/
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GIFTI encodings of the data of a data array, used in the Encoding field of GiftiDataArray.
+const (
+	GIFTI_ENCODING_ASCII       string = "ASCII"            // whitespace-separated numbers in text form
+	GIFTI_ENCODING_BASE64      string = "Base64Binary"     // base64-encoded binary data
+	GIFTI_ENCODING_GZIP_BASE64 string = "GZipBase64Binary" // base64-encoded, zlib-compressed binary data. This is the default when writing.
+)
+
+// GiftiCoordinateSystem is a coordinate system transformation of a GIFTI data array, typically of a pointset.
+type GiftiCoordinateSystem struct {
+	DataSpace        string        // The name of the space of the data, e.g., 'NIFTI_XFORM_UNKNOWN' or 'NIFTI_XFORM_TALAIRACH'.
+	TransformedSpace string        // The name of the space the matrix transforms the data to.
+	Matrix           [4][4]float64 // The affine matrix, row-major.
+}
+
+// GiftiDataArray is a data array of a GIFTI file, e.g., the vertex coordinates of a mesh or per-vertex thickness values.
+//
+// The data is always stored in row-major order, i.e., for a 2D array with Dims [n, 3], the 3 values of the first row come first.
+// Only one of the data fields is used, depending on DataType.
+type GiftiDataArray struct {
+	Intent            int16                   // The NIFTI_INTENT_* code that describes what the data means, e.g., NIFTI_INTENT_POINTSET for vertex coordinates.
+	DataType          int16                   // The NIfTI data type code, one of NIFTI_TYPE_FLOAT32, NIFTI_TYPE_INT32 or NIFTI_TYPE_UINT8.
+	Dims              []int                   // The lengths of the dimensions of the array, e.g., [n, 3] for the vertex coordinates of a mesh with n vertices.
+	Encoding          string                  // The encoding of the data in the file, see GIFTI_ENCODING_ASCII and the other GIFTI_ENCODING_* constants. If empty, GIFTI_ENCODING_GZIP_BASE64 is used when writing.
+	MetaData          map[string]string       // The metadata of the array, nil if there is none.
+	CoordinateSystems []GiftiCoordinateSystem // The coordinate system transformations of the array, nil if there are none.
+	DataFloat32       []float32               // The data, if DataType is NIFTI_TYPE_FLOAT32.
+	DataInt32         []int32                 // The data, if DataType is NIFTI_TYPE_INT32.
+	DataUint8         []uint8                 // The data, if DataType is NIFTI_TYPE_UINT8.
+}
+
+// Gifti models a GIFTI file, the XML-based format for surface meshes and per-vertex data used by Connectome Workbench, nilearn and other tools.
+//
+// Use ReadGiftiSurface and WriteGiftiSurface to exchange meshes in GIFTI format directly.
+type Gifti struct {
+	Version    string            // The GIFTI version, '1.0' if empty when writing.
+	MetaData   map[string]string // The metadata of the file, nil if there is none.
+	LabelTable []GiftiLabel      // The label table, which gives names and colors to the values of NIFTI_INTENT_LABEL arrays. Nil if there is none.
+	DataArrays []GiftiDataArray  // The data arrays.
+}
+
+// GiftiLabel is an entry of the label table of a GIFTI file, e.g., a brain region of a parcellation in a '.label.gii' file.
+type GiftiLabel struct {
+	Key   int32   // The value of the vertices that belong to the label, in the NIFTI_INTENT_LABEL data array.
+	Name  string  // The name of the label, e.g., 'precentral'.
+	Red   float32 // The red channel of the label color, in range 0 to 1.
+	Green float32 // The green channel of the label color, in range 0 to 1.
+	Blue  float32 // The blue channel of the label color, in range 0 to 1.
+	Alpha float32 // The alpha channel (opacity) of the label color, in range 0 to 1.
+}
+
+// giftiIntentNames maps NIfTI intent codes to the names used in GIFTI files.
+var giftiIntentNames = map[int16]string{
+	0: "NIFTI_INTENT_NONE", 2: "NIFTI_INTENT_CORREL", 3: "NIFTI_INTENT_TTEST", 4: "NIFTI_INTENT_FTEST", 5: "NIFTI_INTENT_ZSCORE",
+	6: "NIFTI_INTENT_CHISQ", 7: "NIFTI_INTENT_BETA", 8: "NIFTI_INTENT_BINOM", 9: "NIFTI_INTENT_GAMMA", 10: "NIFTI_INTENT_POISSON",
+	11: "NIFTI_INTENT_NORMAL", 12: "NIFTI_INTENT_FTEST_NONC", 13: "NIFTI_INTENT_CHISQ_NONC", 14: "NIFTI_INTENT_LOGISTIC",
+	15: "NIFTI_INTENT_LAPLACE", 16: "NIFTI_INTENT_UNIFORM", 17: "NIFTI_INTENT_TTEST_NONC", 18: "NIFTI_INTENT_WEIBULL",
+	19: "NIFTI_INTENT_CHI", 20: "NIFTI_INTENT_INVGAUSS", 21: "NIFTI_INTENT_EXTVAL", 22: "NIFTI_INTENT_PVAL",
+	23: "NIFTI_INTENT_LOGPVAL", 24: "NIFTI_INTENT_LOG10PVAL", 1001: "NIFTI_INTENT_ESTIMATE", 1002: "NIFTI_INTENT_LABEL",
+	1003: "NIFTI_INTENT_NEURONAME", 1004: "NIFTI_INTENT_GENMATRIX", 1005: "NIFTI_INTENT_SYMMATRIX", 1006: "NIFTI_INTENT_DISPVECT",
+	1007: "NIFTI_INTENT_VECTOR", 1008: "NIFTI_INTENT_POINTSET", 1009: "NIFTI_INTENT_TRIANGLE", 1010: "NIFTI_INTENT_QUATERNION",
+	1011: "NIFTI_INTENT_DIMLESS", 2001: "NIFTI_INTENT_TIME_SERIES", 2002: "NIFTI_INTENT_NODE_INDEX", 2003: "NIFTI_INTENT_RGB_VECTOR",
+	2004: "NIFTI_INTENT_RGBA_VECTOR", 2005: "NIFTI_INTENT_SHAPE",
+}
+
+// giftiDataTypeNames maps the NIfTI data type codes supported by GIFTI to the names used in GIFTI files.
+var giftiDataTypeNames = map[int16]string{
+	NIFTI_TYPE_UINT8:   "NIFTI_TYPE_UINT8",
+	NIFTI_TYPE_INT32:   "NIFTI_TYPE_INT32",
+	NIFTI_TYPE_FLOAT32: "NIFTI_TYPE_FLOAT32",
+}
+
+// getGiftiCode returns the code for a GIFTI intent or data type name, using one of the maps giftiIntentNames and giftiDataTypeNames.
+func getGiftiCode(names map[int16]string, name string) (int16, error) {
+	for code, n := range names {
+		if n == name {
+			return code, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported or invalid name '%s'.", name)
+}
+
+// The giftiXml* structs model the XML elements of a GIFTI file, they are only used for encoding and decoding.
+type giftiXml struct {
+	XMLName            xml.Name            `xml:"GIFTI"`
+	Version            string              `xml:"Version,attr"`
+	NumberOfDataArrays int                 `xml:"NumberOfDataArrays,attr"`
+	MetaData           giftiXmlMetaData    `xml:"MetaData"`
+	LabelTable         giftiXmlLabelTable  `xml:"LabelTable"`
+	DataArrays         []giftiXmlDataArray `xml:"DataArray"`
+}
+
+type giftiXmlLabelTable struct {
+	Labels []giftiXmlLabel `xml:"Label"`
+}
+
+type giftiXmlLabel struct {
+	Key   string `xml:"Key,attr"`
+	Index string `xml:"Index,attr,omitempty"` // Used instead of Key by files written with GIFTI versions before 1.0.
+	Red   string `xml:"Red,attr,omitempty"`
+	Green string `xml:"Green,attr,omitempty"`
+	Blue  string `xml:"Blue,attr,omitempty"`
+	Alpha string `xml:"Alpha,attr,omitempty"`
+	Name  string `xml:",chardata"`
+}
+
+type giftiXmlMetaData struct {
+	MD []giftiXmlMD `xml:"MD"`
+}
+
+type giftiXmlMD struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+type giftiXmlDataArray struct {
+	Intent             string                `xml:"Intent,attr"`
+	DataType           string                `xml:"DataType,attr"`
+	ArrayIndexingOrder string                `xml:"ArrayIndexingOrder,attr"`
+	Dimensionality     int                   `xml:"Dimensionality,attr"`
+	Dims               []xml.Attr            `xml:",any,attr"` // The Dim0, Dim1, ... attributes.
+	Encoding           string                `xml:"Encoding,attr"`
+	Endian             string                `xml:"Endian,attr"`
+	ExternalFileName   string                `xml:"ExternalFileName,attr,omitempty"`
+	ExternalFileOffset string                `xml:"ExternalFileOffset,attr,omitempty"`
+	MetaData           giftiXmlMetaData      `xml:"MetaData"`
+	CoordinateSystems  []giftiXmlCoordSystem `xml:"CoordinateSystemTransformMatrix"`
+	Data               giftiXmlData          `xml:"Data"`
+}
+
+// giftiXmlData holds the text of a Data element.
+type giftiXmlData struct {
+	Text string `xml:",chardata"`
+}
+
+// MarshalXML writes the Data element with the text as a character data token, so the newlines of ASCII data are not escaped.
+func (d giftiXmlData) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(xml.CharData(d.Text)); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+type giftiXmlCoordSystem struct {
+	DataSpace        string `xml:"DataSpace"`
+	TransformedSpace string `xml:"TransformedSpace"`
+	MatrixData       string `xml:"MatrixData"`
+}
+
+// getGiftiMetaData converts GIFTI XML metadata to a map, or nil if there is no metadata.
+func getGiftiMetaData(md giftiXmlMetaData) map[string]string {
+	if len(md.MD) == 0 {
+		return nil
+	}
+	metaData := make(map[string]string, len(md.MD))
+	for _, entry := range md.MD {
+		metaData[entry.Name] = entry.Value
+	}
+	return metaData
+}
+
+// getGiftiXmlMetaData converts a metadata map to GIFTI XML metadata, sorted by name so the output is deterministic.
+func getGiftiXmlMetaData(metaData map[string]string) giftiXmlMetaData {
+	var md giftiXmlMetaData
+	for name, value := range metaData {
+		md.MD = append(md.MD, giftiXmlMD{Name: name, Value: value})
+	}
+	sort.Slice(md.MD, func(i, j int) bool { return md.MD[i].Name < md.MD[j].Name })
+	return md
+}
+
+// getGiftiNumValues computes the number of values of an array from its dimensions.
+func getGiftiNumValues(dims []int) (int, error) {
+	if len(dims) < 1 || len(dims) > 6 {
+		return 0, fmt.Errorf("invalid number of dimensions %d, must be in range 1 to 6.", len(dims))
+	}
+	numValues := 1
+	for idx, d := range dims {
+		if d < 0 {
+			return 0, fmt.Errorf("invalid length %d of dimension %d.", d, idx)
+		}
+		if d > 0 && numValues > math.MaxInt/d {
+			return 0, fmt.Errorf("the dimensions %v describe too many values.", dims)
+		}
+		numValues *= d
+	}
+	return numValues, nil
+}
+
+// reorderGiftiColumnToRowMajor returns a copy of the data in src with the given dimensions, converted from column-major order (first dimension varies fastest) to row-major order.
+func reorderGiftiColumnToRowMajor[T any](src []T, dims []int) []T {
+	dst := make([]T, len(src))
+	index := make([]int, len(dims))
+	for r := range dst {
+		c, stride := 0, 1
+		for d := range dims {
+			c += index[d] * stride
+			stride *= dims[d]
+		}
+		dst[r] = src[c]
+
+		// Advance the row-major index, the last dimension varies fastest.
+		for d := len(dims) - 1; d >= 0; d-- {
+			index[d]++
+			if index[d] < dims[d] {
+				break
+			}
+			index[d] = 0
+		}
+	}
+	return dst
+}
+
+// decodeGiftiDataArray converts a data array from its XML representation.
+//
+// Parameters:
+//   - dx: the XML data array
+//
+// Returns:
+//   - GiftiDataArray: the decoded data array
+//   - error: an error if one occurred, e.g., if the encoding is not supported or the number of values does not match the dimensions
+func decodeGiftiDataArray(dx giftiXmlDataArray) (GiftiDataArray, error) {
+	var da GiftiDataArray
+	var err error
+
+	if da.Intent, err = getGiftiCode(giftiIntentNames, dx.Intent); err != nil {
+		return da, fmt.Errorf("invalid intent: %w", err)
+	}
+	if da.DataType, err = getGiftiCode(giftiDataTypeNames, dx.DataType); err != nil {
+		return da, fmt.Errorf("invalid data type: %w", err)
+	}
+
+	if dx.Dimensionality < 1 || dx.Dimensionality > 6 {
+		return da, fmt.Errorf("invalid number of dimensions %d, must be in range 1 to 6.", dx.Dimensionality)
+	}
+	da.Dims = make([]int, dx.Dimensionality)
+	found := make([]bool, dx.Dimensionality)
+	for _, attr := range dx.Dims {
+		if !strings.HasPrefix(attr.Name.Local, "Dim") {
+			continue
+		}
+		d, err := strconv.Atoi(strings.TrimPrefix(attr.Name.Local, "Dim"))
+		if err != nil || d < 0 || d >= dx.Dimensionality {
+			return da, fmt.Errorf("unexpected attribute '%s' for an array with %d dimensions.", attr.Name.Local, dx.Dimensionality)
+		}
+		if da.Dims[d], err = strconv.Atoi(strings.TrimSpace(attr.Value)); err != nil {
+			return da, fmt.Errorf("invalid length '%s' of dimension %d: %w", attr.Value, d, err)
+		}
+		found[d] = true
+	}
+	for d, ok := range found {
+		if !ok {
+			return da, fmt.Errorf("missing attribute Dim%d.", d)
+		}
+	}
+	numValues, err := getGiftiNumValues(da.Dims)
+	if err != nil {
+		return da, err
+	}
+
+	da.Encoding = dx.Encoding
+	switch dx.Encoding {
+	case GIFTI_ENCODING_ASCII:
+		err = decodeGiftiAsciiData(&da, dx.Data.Text, numValues)
+	case GIFTI_ENCODING_BASE64, GIFTI_ENCODING_GZIP_BASE64:
+		err = decodeGiftiBinaryData(&da, dx, numValues)
+	case "ExternalFileBinary":
+		err = fmt.Errorf("data in external file '%s' is not supported.", dx.ExternalFileName)
+	default:
+		err = fmt.Errorf("invalid encoding '%s'.", dx.Encoding)
+	}
+	if err != nil {
+		return da, err
+	}
+
+	if dx.ArrayIndexingOrder == "ColumnMajorOrder" && len(da.Dims) > 1 {
+		switch da.DataType {
+		case NIFTI_TYPE_FLOAT32:
+			da.DataFloat32 = reorderGiftiColumnToRowMajor(da.DataFloat32, da.Dims)
+		case NIFTI_TYPE_INT32:
+			da.DataInt32 = reorderGiftiColumnToRowMajor(da.DataInt32, da.Dims)
+		case NIFTI_TYPE_UINT8:
+			da.DataUint8 = reorderGiftiColumnToRowMajor(da.DataUint8, da.Dims)
+		}
+	}
+
+	da.MetaData = getGiftiMetaData(dx.MetaData)
+	for _, cx := range dx.CoordinateSystems {
+		cs := GiftiCoordinateSystem{DataSpace: strings.TrimSpace(cx.DataSpace), TransformedSpace: strings.TrimSpace(cx.TransformedSpace)}
+		fields := strings.Fields(cx.MatrixData)
+		if len(fields) != 16 {
+			return da, fmt.Errorf("coordinate system matrix has %d values, expected 16.", len(fields))
+		}
+		for idx, field := range fields {
+			if cs.Matrix[idx/4][idx%4], err = strconv.ParseFloat(field, 64); err != nil {
+				return da, fmt.Errorf("invalid value in coordinate system matrix: %w", err)
+			}
+		}
+		da.CoordinateSystems = append(da.CoordinateSystems, cs)
+	}
+	return da, nil
+}
+
+// decodeGiftiAsciiData parses the whitespace-separated values of an ASCII-encoded data array into the data field of da that matches da.DataType.
+func decodeGiftiAsciiData(da *GiftiDataArray, data string, numValues int) error {
+	fields := strings.Fields(data)
+	if len(fields) != numValues {
+		return fmt.Errorf("found %d values, expected %d from the dimensions.", len(fields), numValues)
+	}
+	switch da.DataType {
+	case NIFTI_TYPE_FLOAT32:
+		da.DataFloat32 = make([]float32, numValues)
+		for idx, field := range fields {
+			v, err := strconv.ParseFloat(field, 32)
+			if err != nil {
+				return fmt.Errorf("invalid float value: %w", err)
+			}
+			da.DataFloat32[idx] = float32(v)
+		}
+	case NIFTI_TYPE_INT32:
+		da.DataInt32 = make([]int32, numValues)
+		for idx, field := range fields {
+			v, err := strconv.ParseInt(field, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid int32 value: %w", err)
+			}
+			da.DataInt32[idx] = int32(v)
+		}
+	case NIFTI_TYPE_UINT8:
+		da.DataUint8 = make([]uint8, numValues)
+		for idx, field := range fields {
+			v, err := strconv.ParseUint(field, 10, 8)
+			if err != nil {
+				return fmt.Errorf("invalid uint8 value: %w", err)
+			}
+			da.DataUint8[idx] = uint8(v)
+		}
+	}
+	return nil
+}
+
+// decodeGiftiBinaryData decodes the base64-encoded, optionally compressed data of a data array into the data field of da that matches da.DataType.
+func decodeGiftiBinaryData(da *GiftiDataArray, dx giftiXmlDataArray, numValues int) error {
+	raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(dx.Data.Text), ""))
+	if err != nil {
+		return fmt.Errorf("invalid base64 data: %w", err)
+	}
+
+	if dx.Encoding == GIFTI_ENCODING_GZIP_BASE64 {
+		// Despite the name, most software writes zlib streams. Accept real gzip streams as well.
+		var zr io.ReadCloser
+		if len(raw) >= 2 && raw[0] == 0x1f && raw[1] == 0x8b {
+			zr, err = gzip.NewReader(bytes.NewReader(raw))
+		} else {
+			zr, err = zlib.NewReader(bytes.NewReader(raw))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decompress data: %w", err)
+		}
+		defer zr.Close()
+		if raw, err = io.ReadAll(zr); err != nil {
+			return fmt.Errorf("failed to decompress data: %w", err)
+		}
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if dx.Endian == "BigEndian" {
+		order = binary.BigEndian
+	}
+
+	// Check the length before allocating, the dimensions may be much larger than the data.
+	bytesPerValue := 4
+	if da.DataType == NIFTI_TYPE_UINT8 {
+		bytesPerValue = 1
+	}
+	if len(raw)%bytesPerValue != 0 || len(raw)/bytesPerValue != numValues {
+		return fmt.Errorf("found %d bytes of data, expected %d values of %d bytes from the dimensions and data type.", len(raw), numValues, bytesPerValue)
+	}
+
+	var data interface{}
+	switch da.DataType {
+	case NIFTI_TYPE_FLOAT32:
+		da.DataFloat32 = make([]float32, numValues)
+		data = da.DataFloat32
+	case NIFTI_TYPE_INT32:
+		da.DataInt32 = make([]int32, numValues)
+		data = da.DataInt32
+	case NIFTI_TYPE_UINT8:
+		da.DataUint8 = make([]uint8, numValues)
+		data = da.DataUint8
+	}
+	return binary.Read(bytes.NewReader(raw), order, data)
+}
+
+// encodeGiftiDataArray converts a data array to its XML representation.
+//
+// Parameters:
+//   - da: the data array
+//
+// Returns:
+//   - giftiXmlDataArray: the XML data array
+//   - error: an error if one occurred, e.g., if the number of values does not match the dimensions
+func encodeGiftiDataArray(da GiftiDataArray) (giftiXmlDataArray, error) {
+	var dx giftiXmlDataArray
+
+	intentName, ok := giftiIntentNames[da.Intent]
+	if !ok {
+		return dx, fmt.Errorf("invalid intent code %d.", da.Intent)
+	}
+	dataTypeName, ok := giftiDataTypeNames[da.DataType]
+	if !ok {
+		return dx, &UnsupportedNiftiDataTypeError{Code: da.DataType}
+	}
+	numValues, err := getGiftiNumValues(da.Dims)
+	if err != nil {
+		return dx, err
+	}
+
+	var data interface{}
+	var numData int
+	switch da.DataType {
+	case NIFTI_TYPE_FLOAT32:
+		data, numData = da.DataFloat32, len(da.DataFloat32)
+	case NIFTI_TYPE_INT32:
+		data, numData = da.DataInt32, len(da.DataInt32)
+	case NIFTI_TYPE_UINT8:
+		data, numData = da.DataUint8, len(da.DataUint8)
+	}
+	if numData != numValues {
+		return dx, fmt.Errorf("the array contains %d values, but the dimensions %v require %d.", numData, da.Dims, numValues)
+	}
+
+	dx.Intent = intentName
+	dx.DataType = dataTypeName
+	dx.ArrayIndexingOrder = "RowMajorOrder"
+	dx.Dimensionality = len(da.Dims)
+	for idx, d := range da.Dims {
+		dx.Dims = append(dx.Dims, xml.Attr{Name: xml.Name{Local: fmt.Sprintf("Dim%d", idx)}, Value: strconv.Itoa(d)})
+	}
+	dx.Encoding = da.Encoding
+	if dx.Encoding == "" {
+		dx.Encoding = GIFTI_ENCODING_GZIP_BASE64
+	}
+	dx.Endian = "LittleEndian"
+	dx.MetaData = getGiftiXmlMetaData(da.MetaData)
+	for _, cs := range da.CoordinateSystems {
+		values := make([]string, 0, 16)
+		for _, row := range cs.Matrix {
+			for _, v := range row {
+				values = append(values, strconv.FormatFloat(v, 'g', -1, 64))
+			}
+		}
+		dx.CoordinateSystems = append(dx.CoordinateSystems, giftiXmlCoordSystem{DataSpace: cs.DataSpace, TransformedSpace: cs.TransformedSpace, MatrixData: strings.Join(values, " ")})
+	}
+
+	switch dx.Encoding {
+	case GIFTI_ENCODING_ASCII:
+		dx.Data.Text = encodeGiftiAsciiData(da, numValues)
+	case GIFTI_ENCODING_BASE64, GIFTI_ENCODING_GZIP_BASE64:
+		var buf bytes.Buffer
+		var w io.Writer = &buf
+		var zw *zlib.Writer
+		if dx.Encoding == GIFTI_ENCODING_GZIP_BASE64 {
+			zw = zlib.NewWriter(&buf)
+			w = zw
+		}
+		if err := binary.Write(w, binary.LittleEndian, data); err != nil {
+			return dx, err
+		}
+		if zw != nil {
+			if err := zw.Close(); err != nil {
+				return dx, err
+			}
+		}
+		dx.Data.Text = base64.StdEncoding.EncodeToString(buf.Bytes())
+	default:
+		return dx, fmt.Errorf("invalid encoding '%s', must be one of '%s', '%s' or '%s'.", dx.Encoding, GIFTI_ENCODING_ASCII, GIFTI_ENCODING_BASE64, GIFTI_ENCODING_GZIP_BASE64)
+	}
+	return dx, nil
+}
+
+// encodeGiftiAsciiData formats the values of a data array as text, with one row of the array per line.
+func encodeGiftiAsciiData(da GiftiDataArray, numValues int) string {
+	rowLength := 1
+	if len(da.Dims) > 1 {
+		rowLength = da.Dims[len(da.Dims)-1]
+	}
+	var sb strings.Builder
+	sb.WriteString("\n")
+	for idx := 0; idx < numValues; idx++ {
+		switch da.DataType {
+		case NIFTI_TYPE_FLOAT32:
+			sb.WriteString(strconv.FormatFloat(float64(da.DataFloat32[idx]), 'g', -1, 32))
+		case NIFTI_TYPE_INT32:
+			sb.WriteString(strconv.FormatInt(int64(da.DataInt32[idx]), 10))
+		case NIFTI_TYPE_UINT8:
+			sb.WriteString(strconv.FormatUint(uint64(da.DataUint8[idx]), 10))
+		}
+		if (idx+1)%rowLength == 0 {
+			sb.WriteString("\n")
+		} else {
+			sb.WriteString(" ")
+		}
+	}
+	return sb.String()
+}
+
+// decodeGiftiLabel converts an entry of the label table from its XML representation. Missing color channels are 0, a missing alpha channel is 1.
+func decodeGiftiLabel(lx giftiXmlLabel) (GiftiLabel, error) {
+	label := GiftiLabel{Name: strings.TrimSpace(lx.Name), Alpha: 1.0}
+
+	keyStr := lx.Key
+	if keyStr == "" {
+		keyStr = lx.Index
+	}
+	key, err := strconv.ParseInt(strings.TrimSpace(keyStr), 10, 32)
+	if err != nil {
+		return label, fmt.Errorf("invalid key '%s': %w", keyStr, err)
+	}
+	label.Key = int32(key)
+
+	channels := []struct {
+		value string
+		dst   *float32
+	}{{lx.Red, &label.Red}, {lx.Green, &label.Green}, {lx.Blue, &label.Blue}, {lx.Alpha, &label.Alpha}}
+	for _, channel := range channels {
+		if channel.value == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(channel.value), 32)
+		if err != nil {
+			return label, fmt.Errorf("invalid color value '%s': %w", channel.value, err)
+		}
+		*channel.dst = float32(v)
+	}
+	return label, nil
+}
+
+// readGifti reads a GIFTI file from r.
+//
+// Parameters:
+//   - r: the reader, positioned at the start of the XML document
+//
+// Returns:
+//   - Gifti: the GIFTI data
+//   - error: an error if one occurred
+func readGifti(r io.Reader) (Gifti, error) {
+	var gifti Gifti
+	var gx giftiXml
+
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// The XML is read as UTF-8, which is fine for ASCII-compatible encodings as long as all text is ASCII, like in all GIFTI files in practice.
+		switch strings.ToLower(charset) {
+		case "us-ascii", "ascii", "iso-8859-1", "latin1":
+			return input, nil
+		}
+		return nil, fmt.Errorf("unsupported XML charset '%s'.", charset)
+	}
+	if err := decoder.Decode(&gx); err != nil {
+		return gifti, fmt.Errorf("failed to parse GIFTI XML: %w", err)
+	}
+
+	gifti.Version = gx.Version
+	gifti.MetaData = getGiftiMetaData(gx.MetaData)
+	for idx, lx := range gx.LabelTable.Labels {
+		label, err := decodeGiftiLabel(lx)
+		if err != nil {
+			return gifti, fmt.Errorf("label %d of the label table: %w", idx, err)
+		}
+		gifti.LabelTable = append(gifti.LabelTable, label)
+	}
+	for idx, dx := range gx.DataArrays {
+		da, err := decodeGiftiDataArray(dx)
+		if err != nil {
+			return gifti, fmt.Errorf("data array %d: %w", idx, err)
+		}
+		gifti.DataArrays = append(gifti.DataArrays, da)
+	}
+
+	if Verbosity > 0 {
+		fmt.Printf("readGifti: GIFTI version '%s' with %d data arrays.\n", gifti.Version, len(gifti.DataArrays))
+	}
+	return gifti, nil
+}
+
+// ReadGifti reads a file in GIFTI format, e.g., a '.surf.gii' or '.func.gii' file.
+//
+// The data arrays may use ASCII, Base64Binary or GZipBase64Binary encoding, in both byte orders and both array indexing orders.
+// Data in external files is not supported. See ReadGiftiSurface to read a mesh.
+//
+// Parameters:
+//   - filepath: path to the GIFTI file
+//
+// Returns:
+//   - Gifti: the GIFTI metadata and data arrays
+//   - error: an error if one occurred
+func ReadGifti(filepath string) (Gifti, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return Gifti{}, fmt.Errorf("ReadGifti: could not open GIFTI file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	gifti, err := readGifti(file)
+	if err != nil {
+		return gifti, fmt.Errorf("ReadGifti: failed to read GIFTI file '%s': %w", filepath, err)
+	}
+	return gifti, nil
+}
+
+// ReadGiftiFS reads a file in GIFTI format from the file system fsys, see ReadGifti.
+//
+// Parameters:
+//   - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//   - name: the name of the GIFTI file in fsys
+//
+// Returns:
+//   - Gifti: the GIFTI metadata and data arrays
+//   - error: an error if one occurred
+func ReadGiftiFS(fsys fs.FS, name string) (Gifti, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return Gifti{}, fmt.Errorf("ReadGiftiFS: could not open GIFTI file '%s': %w", name, err)
+	}
+	defer file.Close()
+
+	gifti, err := readGifti(file)
+	if err != nil {
+		return gifti, fmt.Errorf("ReadGiftiFS: failed to read GIFTI file '%s': %w", name, err)
+	}
+	return gifti, nil
+}
+
+// ReadGiftiFrom reads data in GIFTI format from r, see ReadGifti.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File or a *bytes.Reader
+//
+// Returns:
+//   - Gifti: the GIFTI metadata and data arrays
+//   - error: an error if one occurred
+func ReadGiftiFrom(r io.Reader) (Gifti, error) {
+	return readGifti(r)
+}
+
+// WriteGifti writes a Gifti struct to a file in GIFTI format.
+//
+// Each data array is written with its Encoding, or GZipBase64Binary if it is empty, in little endian byte order and row-major order.
+//
+// Parameters:
+//   - filepath: path to the output file, e.g., 'lh.white.surf.gii'. The directory must exist.
+//   - gifti: the Gifti struct to write
+//
+// Returns:
+//   - error: an error if one occurred, e.g., if a data array is invalid
+func WriteGifti(filepath string, gifti Gifti) error {
+	var buf bytes.Buffer
+	if err := WriteGiftiTo(&buf, gifti); err != nil {
+		return fmt.Errorf("WriteGifti: failed to write GIFTI file '%s': %w", filepath, err)
+	}
+	if err := os.WriteFile(filepath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("WriteGifti: could not write GIFTI file '%s': %w", filepath, err)
+	}
+	return nil
+}
+
+// WriteGiftiTo writes a Gifti struct to w in GIFTI format.
+//
+// This is the io.Writer version of WriteGifti, see there for details.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - gifti: the Gifti struct to write
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteGiftiTo(w io.Writer, gifti Gifti) error {
+	gx := giftiXml{Version: gifti.Version, NumberOfDataArrays: len(gifti.DataArrays), MetaData: getGiftiXmlMetaData(gifti.MetaData)}
+	for _, label := range gifti.LabelTable {
+		gx.LabelTable.Labels = append(gx.LabelTable.Labels, giftiXmlLabel{
+			Key:   strconv.FormatInt(int64(label.Key), 10),
+			Red:   strconv.FormatFloat(float64(label.Red), 'g', -1, 32),
+			Green: strconv.FormatFloat(float64(label.Green), 'g', -1, 32),
+			Blue:  strconv.FormatFloat(float64(label.Blue), 'g', -1, 32),
+			Alpha: strconv.FormatFloat(float64(label.Alpha), 'g', -1, 32),
+			Name:  label.Name,
+		})
+	}
+	if gx.Version == "" {
+		gx.Version = "1.0"
+	}
+	for idx, da := range gifti.DataArrays {
+		dx, err := encodeGiftiDataArray(da)
+		if err != nil {
+			return fmt.Errorf("WriteGiftiTo: data array %d: %w", idx, err)
+		}
+		gx.DataArrays = append(gx.DataArrays, dx)
+	}
+
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE GIFTI SYSTEM \"http://www.nitrc.org/frs/download.php/115/gifti.dtd\">\n"); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(gx); err != nil {
+		return fmt.Errorf("WriteGiftiTo: failed to encode GIFTI XML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,212 @@
+package neuro
+
+import (
+	"fmt"
+	"io"
+)
+
+// GiftiLabelData holds the per-vertex labels of a GIFTI label file ('.label.gii'), e.g., a cortical parcellation.
+type GiftiLabelData struct {
+	Keys       []int32      // The label key of each vertex. The key identifies the label in LabelTable.
+	LabelTable []GiftiLabel // The names and colors of the labels.
+}
+
+// getGiftiPerVertexDataArray returns the data array with the given index, and checks that it contains one value per vertex.
+func getGiftiPerVertexDataArray(gifti Gifti, index int) (GiftiDataArray, error) {
+	if index < 0 || index >= len(gifti.DataArrays) {
+		return GiftiDataArray{}, fmt.Errorf("data array index %d out of range, the file contains %d data arrays.", index, len(gifti.DataArrays))
+	}
+	da := gifti.DataArrays[index]
+	if len(da.Dims) == 2 && da.Dims[1] != 1 || len(da.Dims) > 2 {
+		return da, fmt.Errorf("data array %d has dimensions %v, expected one value per vertex.", index, da.Dims)
+	}
+	return da, nil
+}
+
+// GiftiToPerVertexData extracts per-vertex data, like cortical thickness, from a data array of a Gifti struct.
+//
+// The values are converted to float32, so they can be used like the data from ReadFsCurv.
+//
+// Parameters:
+//   - gifti: the Gifti struct, e.g., from ReadGifti
+//   - index: the index of the data array. Files with several arrays, e.g., a time series in a '.func.gii' file, contain one array per frame.
+//
+// Returns:
+//   - []float32: the per-vertex values
+//   - error: an error if the index is out of range or the array does not contain one value per vertex
+func GiftiToPerVertexData(gifti Gifti, index int) ([]float32, error) {
+	da, err := getGiftiPerVertexDataArray(gifti, index)
+	if err != nil {
+		return nil, fmt.Errorf("GiftiToPerVertexData: %w", err)
+	}
+
+	switch da.DataType {
+	case NIFTI_TYPE_INT32:
+		data := make([]float32, len(da.DataInt32))
+		for idx, v := range da.DataInt32 {
+			data[idx] = float32(v)
+		}
+		return data, nil
+	case NIFTI_TYPE_UINT8:
+		data := make([]float32, len(da.DataUint8))
+		for idx, v := range da.DataUint8 {
+			data[idx] = float32(v)
+		}
+		return data, nil
+	}
+	return da.DataFloat32, nil
+}
+
+// PerVertexDataToGifti creates a Gifti struct with a single float32 data array for per-vertex data.
+//
+// Parameters:
+//   - data: the per-vertex values, e.g., from ReadFsCurv
+//   - intent: the NIFTI_INTENT_* code, e.g., NIFTI_INTENT_SHAPE for '.shape.gii' files with morphometry data like thickness, or NIFTI_INTENT_NONE for '.func.gii' files.
+//   - metaData: the metadata of the data array, e.g., {"Name": "thickness"}. May be nil.
+//   - encoding: the encoding of the data array, see GIFTI_ENCODING_ASCII and the other GIFTI_ENCODING_* constants. If empty, GIFTI_ENCODING_GZIP_BASE64 is used.
+//
+// Returns:
+//   - Gifti: the Gifti struct, which can be written with WriteGifti
+func PerVertexDataToGifti(data []float32, intent int16, metaData map[string]string, encoding string) Gifti {
+	da := GiftiDataArray{Intent: intent, DataType: NIFTI_TYPE_FLOAT32, Dims: []int{len(data)}, Encoding: encoding, MetaData: metaData, DataFloat32: data}
+	return Gifti{DataArrays: []GiftiDataArray{da}}
+}
+
+// ReadGiftiPerVertexData reads per-vertex data from the first data array of a GIFTI file, e.g., a '.shape.gii' or '.func.gii' file.
+//
+// This is the GIFTI version of ReadFsCurv. Use ReadGifti and GiftiToPerVertexData to read other data arrays.
+//
+// Parameters:
+//   - filepath: path to the GIFTI file
+//
+// Returns:
+//   - []float32: the per-vertex values
+//   - error: an error if one occurred
+func ReadGiftiPerVertexData(filepath string) ([]float32, error) {
+	gifti, err := ReadGifti(filepath)
+	if err != nil {
+		return nil, err
+	}
+	return GiftiToPerVertexData(gifti, 0)
+}
+
+// ReadGiftiPerVertexDataFrom reads per-vertex data in GIFTI format from r, see ReadGiftiPerVertexData.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File or a *bytes.Reader
+//
+// Returns:
+//   - []float32: the per-vertex values
+//   - error: an error if one occurred
+func ReadGiftiPerVertexDataFrom(r io.Reader) ([]float32, error) {
+	gifti, err := readGifti(r)
+	if err != nil {
+		return nil, fmt.Errorf("ReadGiftiPerVertexDataFrom: %w", err)
+	}
+	return GiftiToPerVertexData(gifti, 0)
+}
+
+// WriteGiftiPerVertexData writes per-vertex data to a file in GIFTI format, see PerVertexDataToGifti.
+//
+// This is the GIFTI version of WriteFsCurv.
+//
+// Parameters:
+//   - filepath: path to the output file, e.g., 'lh.thickness.shape.gii'. The directory must exist.
+//   - data: the per-vertex values
+//   - intent: the NIFTI_INTENT_* code, e.g., NIFTI_INTENT_SHAPE
+//   - metaData: the metadata of the data array, may be nil
+//   - encoding: the encoding of the data array. If empty, GIFTI_ENCODING_GZIP_BASE64 is used.
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteGiftiPerVertexData(filepath string, data []float32, intent int16, metaData map[string]string, encoding string) error {
+	return WriteGifti(filepath, PerVertexDataToGifti(data, intent, metaData, encoding))
+}
+
+// GiftiToLabelData extracts the per-vertex labels and the label table from a Gifti struct.
+//
+// The labels are taken from the first data array with intent NIFTI_INTENT_LABEL.
+//
+// Parameters:
+//   - gifti: the Gifti struct, e.g., from ReadGifti
+//
+// Returns:
+//   - GiftiLabelData: the per-vertex label keys and the label table
+//   - error: an error if there is no label array or it does not contain one int32 value per vertex
+func GiftiToLabelData(gifti Gifti) (GiftiLabelData, error) {
+	labels := GiftiLabelData{LabelTable: gifti.LabelTable}
+	for idx, da := range gifti.DataArrays {
+		if da.Intent != NIFTI_INTENT_LABEL {
+			continue
+		}
+		if _, err := getGiftiPerVertexDataArray(gifti, idx); err != nil {
+			return labels, fmt.Errorf("GiftiToLabelData: %w", err)
+		}
+		if da.DataType != NIFTI_TYPE_INT32 {
+			return labels, fmt.Errorf("GiftiToLabelData: the label array must have data type NIFTI_TYPE_INT32, found %d.", da.DataType)
+		}
+		labels.Keys = da.DataInt32
+		return labels, nil
+	}
+	return labels, fmt.Errorf("GiftiToLabelData: no data array with intent NIFTI_INTENT_LABEL found.")
+}
+
+// LabelDataToGifti creates a Gifti struct with the label table and a NIFTI_INTENT_LABEL data array for per-vertex labels.
+//
+// Parameters:
+//   - labels: the per-vertex label keys and the label table
+//   - metaData: the metadata of the data array, may be nil
+//   - encoding: the encoding of the data array. If empty, GIFTI_ENCODING_GZIP_BASE64 is used.
+//
+// Returns:
+//   - Gifti: the Gifti struct, which can be written with WriteGifti
+func LabelDataToGifti(labels GiftiLabelData, metaData map[string]string, encoding string) Gifti {
+	da := GiftiDataArray{Intent: NIFTI_INTENT_LABEL, DataType: NIFTI_TYPE_INT32, Dims: []int{len(labels.Keys)}, Encoding: encoding, MetaData: metaData, DataInt32: labels.Keys}
+	return Gifti{LabelTable: labels.LabelTable, DataArrays: []GiftiDataArray{da}}
+}
+
+// ReadGiftiLabel reads the per-vertex labels and the label table from a GIFTI label file, e.g., 'lh.aparc.label.gii'.
+//
+// Parameters:
+//   - filepath: path to the GIFTI label file
+//
+// Returns:
+//   - GiftiLabelData: the per-vertex label keys and the label table
+//   - error: an error if one occurred
+func ReadGiftiLabel(filepath string) (GiftiLabelData, error) {
+	gifti, err := ReadGifti(filepath)
+	if err != nil {
+		return GiftiLabelData{}, err
+	}
+	return GiftiToLabelData(gifti)
+}
+
+// ReadGiftiLabelFrom reads per-vertex labels in GIFTI format from r, see ReadGiftiLabel.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File or a *bytes.Reader
+//
+// Returns:
+//   - GiftiLabelData: the per-vertex label keys and the label table
+//   - error: an error if one occurred
+func ReadGiftiLabelFrom(r io.Reader) (GiftiLabelData, error) {
+	gifti, err := readGifti(r)
+	if err != nil {
+		return GiftiLabelData{}, fmt.Errorf("ReadGiftiLabelFrom: %w", err)
+	}
+	return GiftiToLabelData(gifti)
+}
+
+// WriteGiftiLabel writes per-vertex labels and their label table to a file in GIFTI format, see LabelDataToGifti.
+//
+// Parameters:
+//   - filepath: path to the output file, e.g., 'lh.aparc.label.gii'. The directory must exist.
+//   - labels: the per-vertex label keys and the label table
+//   - metaData: the metadata of the data array, may be nil
+//   - encoding: the encoding of the data array. If empty, GIFTI_ENCODING_GZIP_BASE64 is used.
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteGiftiLabel(filepath string, labels GiftiLabelData, metaData map[string]string, encoding string) error {
+	return WriteGifti(filepath, LabelDataToGifti(labels, metaData, encoding))
+}
@@ -0,0 +1,152 @@
+package neuro
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteRereadGiftiPerVertexData(t *testing.T) {
+	thickness, err := ReadFsCurv("testdata/lh.thickness")
+	if err != nil {
+		t.Fatalf("ReadFsCurv failed: %v", err)
+	}
+
+	shapeFile := filepath.Join(t.TempDir(), "lh.thickness.shape.gii")
+	if err := WriteGiftiPerVertexData(shapeFile, thickness, NIFTI_INTENT_SHAPE, map[string]string{"Name": "thickness"}, ""); err != nil {
+		t.Fatalf("WriteGiftiPerVertexData failed: %v", err)
+	}
+	data, err := ReadGiftiPerVertexData(shapeFile)
+	if err != nil {
+		t.Fatalf("ReadGiftiPerVertexData failed: %v", err)
+	}
+	if diff := cmp.Diff(thickness, data); diff != "" {
+		t.Error(diff)
+	}
+
+	gifti, err := ReadGifti(shapeFile)
+	if err != nil {
+		t.Fatalf("ReadGifti failed: %v", err)
+	}
+	if gifti.DataArrays[0].Intent != NIFTI_INTENT_SHAPE || gifti.DataArrays[0].MetaData["Name"] != "thickness" {
+		t.Errorf("got intent %d and metadata %v, wanted %d and the name", gifti.DataArrays[0].Intent, gifti.DataArrays[0].MetaData, NIFTI_INTENT_SHAPE)
+	}
+}
+
+func TestGiftiToPerVertexData(t *testing.T) {
+	gifti := Gifti{DataArrays: []GiftiDataArray{
+		{Intent: NIFTI_INTENT_TIME_SERIES, DataType: NIFTI_TYPE_FLOAT32, Dims: []int{3}, DataFloat32: []float32{0.5, 1.5, 2.5}},
+		{Intent: NIFTI_INTENT_TIME_SERIES, DataType: NIFTI_TYPE_INT32, Dims: []int{3, 1}, DataInt32: []int32{-1, 0, 1}},
+		{Intent: NIFTI_INTENT_NONE, DataType: NIFTI_TYPE_UINT8, Dims: []int{3}, DataUint8: []uint8{0, 1, 255}},
+		{Intent: NIFTI_INTENT_VECTOR, DataType: NIFTI_TYPE_FLOAT32, Dims: []int{1, 3}, DataFloat32: []float32{0, 0, 1}},
+	}}
+
+	want := [][]float32{{0.5, 1.5, 2.5}, {-1, 0, 1}, {0, 1, 255}}
+	for idx := range want {
+		data, err := GiftiToPerVertexData(gifti, idx)
+		if err != nil {
+			t.Fatalf("GiftiToPerVertexData failed for array %d: %v", idx, err)
+		}
+		if diff := cmp.Diff(want[idx], data); diff != "" {
+			t.Error(diff)
+		}
+	}
+
+	for _, idx := range []int{-1, 3, 4} {
+		if _, err := GiftiToPerVertexData(gifti, idx); err == nil {
+			t.Errorf("expected error for array %d, got nil", idx)
+		}
+	}
+}
+
+func TestWriteRereadGiftiLabel(t *testing.T) {
+	labels := GiftiLabelData{
+		Keys: []int32{0, 1, 1, 2, 0},
+		LabelTable: []GiftiLabel{
+			{Key: 0, Name: "???", Red: 0.0, Green: 0.0, Blue: 0.0, Alpha: 0.0},
+			{Key: 1, Name: "precentral", Red: 0.235, Green: 0.078, Blue: 0.863, Alpha: 1.0},
+			{Key: 2, Name: "a <strange> & name", Red: 1.0, Green: 0.5, Blue: 0.25, Alpha: 1.0},
+		},
+	}
+
+	labelFile := filepath.Join(t.TempDir(), "lh.aparc.label.gii")
+	if err := WriteGiftiLabel(labelFile, labels, nil, GIFTI_ENCODING_ASCII); err != nil {
+		t.Fatalf("WriteGiftiLabel failed: %v", err)
+	}
+	reread, err := ReadGiftiLabel(labelFile)
+	if err != nil {
+		t.Fatalf("ReadGiftiLabel failed: %v", err)
+	}
+	if diff := cmp.Diff(labels, reread); diff != "" {
+		t.Error(diff)
+	}
+
+	if _, err := GiftiToLabelData(PerVertexDataToGifti([]float32{1, 2}, NIFTI_INTENT_SHAPE, nil, "")); err == nil {
+		t.Errorf("expected error for file without label array, got nil")
+	}
+}
+
+func TestReadGiftiLabelFrom(t *testing.T) {
+	// A label table with the Index attribute of old files, and missing alpha values.
+	xmlData := `<?xml version="1.0" encoding="UTF-8"?>
+<GIFTI Version="1.0" NumberOfDataArrays="1">
+  <LabelTable>
+    <Label Index="0" Red="1" Green="1" Blue="1"><![CDATA[unknown]]></Label>
+    <Label Key="5" Red="0.5" Green="0" Blue="0" Alpha="0.5"><![CDATA[region5]]></Label>
+  </LabelTable>
+  <DataArray Intent="NIFTI_INTENT_LABEL" DataType="NIFTI_TYPE_INT32" ArrayIndexingOrder="RowMajorOrder" Dimensionality="1" Dim0="4" Encoding="ASCII" Endian="LittleEndian">
+    <Data>0 5 5 0</Data>
+  </DataArray>
+</GIFTI>`
+
+	labels, err := ReadGiftiLabelFrom(strings.NewReader(xmlData))
+	if err != nil {
+		t.Fatalf("ReadGiftiLabelFrom failed: %v", err)
+	}
+	want := GiftiLabelData{
+		Keys:       []int32{0, 5, 5, 0},
+		LabelTable: []GiftiLabel{{Key: 0, Name: "unknown", Red: 1, Green: 1, Blue: 1, Alpha: 1}, {Key: 5, Name: "region5", Red: 0.5, Alpha: 0.5}},
+	}
+	if diff := cmp.Diff(want, labels); diff != "" {
+		t.Error(diff)
+	}
+
+	invalid := strings.Replace(xmlData, `Key="5"`, `Key="five"`, 1)
+	if _, err := ReadGiftiLabelFrom(strings.NewReader(invalid)); err == nil {
+		t.Errorf("expected error for invalid key, got nil")
+	}
+}
+
+func TestReadGiftiPerVertexDataFrom(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGiftiTo(&buf, PerVertexDataToGifti([]float32{1.0, 2.0}, NIFTI_INTENT_NONE, nil, GIFTI_ENCODING_BASE64)); err != nil {
+		t.Fatalf("WriteGiftiTo failed: %v", err)
+	}
+	data, err := ReadGiftiPerVertexDataFrom(&buf)
+	if err != nil {
+		t.Fatalf("ReadGiftiPerVertexDataFrom failed: %v", err)
+	}
+	if diff := cmp.Diff([]float32{1.0, 2.0}, data); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func ExampleWriteGiftiPerVertexData() {
+	thickness, _ := ReadFsCurv("testdata/lh.thickness")
+
+	shapeFile := filepath.Join(os.TempDir(), "lh.thickness.shape.gii")
+	defer os.Remove(shapeFile)
+	if err := WriteGiftiPerVertexData(shapeFile, thickness, NIFTI_INTENT_SHAPE, map[string]string{"Name": "thickness"}, ""); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	data, _ := ReadGiftiPerVertexData(shapeFile)
+	fmt.Printf("Read %d values, the first one is %.2f.\n", len(data), data[0])
+	// Output: Read 149244 values, the first one is 2.56.
+}
@@ -0,0 +1,172 @@
+package neuro
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// GiftiToMesh extracts the mesh from a Gifti struct.
+//
+// The vertex coordinates are taken from the first data array with intent NIFTI_INTENT_POINTSET, and the faces from the first data array
+// with intent NIFTI_INTENT_TRIANGLE. Coordinate system transformations of the pointset are not applied.
+//
+// Parameters:
+//   - gifti: the Gifti struct, e.g., from ReadGifti
+//
+// Returns:
+//   - Mesh: the mesh
+//   - error: an error if one occurred, e.g., if there is no pointset or triangle array, or a face references a vertex that does not exist
+func GiftiToMesh(gifti Gifti) (Mesh, error) {
+	var mesh Mesh
+	var pointset, triangles *GiftiDataArray
+	for idx := range gifti.DataArrays {
+		da := &gifti.DataArrays[idx]
+		if da.Intent == NIFTI_INTENT_POINTSET && pointset == nil {
+			pointset = da
+		}
+		if da.Intent == NIFTI_INTENT_TRIANGLE && triangles == nil {
+			triangles = da
+		}
+	}
+
+	if pointset == nil {
+		return mesh, fmt.Errorf("GiftiToMesh: no data array with intent NIFTI_INTENT_POINTSET found.")
+	}
+	if pointset.DataType != NIFTI_TYPE_FLOAT32 || len(pointset.Dims) != 2 || pointset.Dims[1] != 3 {
+		return mesh, fmt.Errorf("GiftiToMesh: the pointset must have data type NIFTI_TYPE_FLOAT32 and dimensions [n, 3], found data type %d and dimensions %v.", pointset.DataType, pointset.Dims)
+	}
+	if triangles == nil {
+		return mesh, fmt.Errorf("GiftiToMesh: no data array with intent NIFTI_INTENT_TRIANGLE found.")
+	}
+	if triangles.DataType != NIFTI_TYPE_INT32 || len(triangles.Dims) != 2 || triangles.Dims[1] != 3 {
+		return mesh, fmt.Errorf("GiftiToMesh: the triangles must have data type NIFTI_TYPE_INT32 and dimensions [m, 3], found data type %d and dimensions %v.", triangles.DataType, triangles.Dims)
+	}
+
+	numVertices := int32(pointset.Dims[0])
+	for _, vertexIndex := range triangles.DataInt32 {
+		if vertexIndex < 0 || vertexIndex >= numVertices {
+			return mesh, fmt.Errorf("GiftiToMesh: face references vertex %d, but the mesh has only %d vertices.", vertexIndex, numVertices)
+		}
+	}
+
+	mesh.Vertices = pointset.DataFloat32
+	mesh.Faces = triangles.DataInt32
+	return mesh, nil
+}
+
+// MeshToGifti creates a Gifti struct for a mesh, with a NIFTI_INTENT_POINTSET array for the vertices and a NIFTI_INTENT_TRIANGLE array for the faces.
+//
+// Parameters:
+//   - mesh: the mesh
+//   - encoding: the encoding of the data arrays, see GIFTI_ENCODING_ASCII and the other GIFTI_ENCODING_* constants. If empty, GIFTI_ENCODING_GZIP_BASE64 is used.
+//
+// Returns:
+//   - Gifti: the Gifti struct, which can be written with WriteGifti
+//   - error: an error if the lengths of the vertex or face slices are not multiples of 3
+func MeshToGifti(mesh Mesh, encoding string) (Gifti, error) {
+	if len(mesh.Vertices)%3 != 0 || len(mesh.Faces)%3 != 0 {
+		return Gifti{}, fmt.Errorf("MeshToGifti: invalid mesh with %d vertex coordinates and %d face indices, both must be multiples of 3.", len(mesh.Vertices), len(mesh.Faces))
+	}
+	pointset := GiftiDataArray{
+		Intent:      NIFTI_INTENT_POINTSET,
+		DataType:    NIFTI_TYPE_FLOAT32,
+		Dims:        []int{len(mesh.Vertices) / 3, 3},
+		Encoding:    encoding,
+		DataFloat32: mesh.Vertices,
+		CoordinateSystems: []GiftiCoordinateSystem{
+			{DataSpace: "NIFTI_XFORM_UNKNOWN", TransformedSpace: "NIFTI_XFORM_UNKNOWN", Matrix: [4][4]float64{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}},
+		},
+	}
+	triangles := GiftiDataArray{
+		Intent:    NIFTI_INTENT_TRIANGLE,
+		DataType:  NIFTI_TYPE_INT32,
+		Dims:      []int{len(mesh.Faces) / 3, 3},
+		Encoding:  encoding,
+		DataInt32: mesh.Faces,
+	}
+	return Gifti{DataArrays: []GiftiDataArray{pointset, triangles}}, nil
+}
+
+// ReadGiftiSurface reads a mesh from a file in GIFTI format, e.g., a '.surf.gii' file, see ReadGifti and GiftiToMesh.
+//
+// Parameters:
+//   - filepath: path to the GIFTI surface file
+//
+// Returns:
+//   - Mesh: a Mesh struct containing the mesh data
+//   - error: an error if one occurred
+func ReadGiftiSurface(filepath string) (Mesh, error) {
+	gifti, err := ReadGifti(filepath)
+	if err != nil {
+		return Mesh{}, err
+	}
+	return GiftiToMesh(gifti)
+}
+
+// ReadGiftiSurfaceFS reads a mesh from a file in GIFTI format from the file system fsys, see ReadGiftiSurface.
+//
+// Parameters:
+//   - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//   - name: the name of the GIFTI surface file in fsys
+//
+// Returns:
+//   - Mesh: a Mesh struct containing the mesh data
+//   - error: an error if one occurred
+func ReadGiftiSurfaceFS(fsys fs.FS, name string) (Mesh, error) {
+	gifti, err := ReadGiftiFS(fsys, name)
+	if err != nil {
+		return Mesh{}, err
+	}
+	return GiftiToMesh(gifti)
+}
+
+// ReadGiftiSurfaceFrom reads a mesh in GIFTI format from r, see ReadGiftiSurface.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File or a *bytes.Reader
+//
+// Returns:
+//   - Mesh: a Mesh struct containing the mesh data
+//   - error: an error if one occurred
+func ReadGiftiSurfaceFrom(r io.Reader) (Mesh, error) {
+	gifti, err := readGifti(r)
+	if err != nil {
+		return Mesh{}, fmt.Errorf("ReadGiftiSurfaceFrom: %w", err)
+	}
+	return GiftiToMesh(gifti)
+}
+
+// WriteGiftiSurface writes a mesh to a file in GIFTI format, see MeshToGifti.
+//
+// Parameters:
+//   - filepath: path to the output file, e.g., 'lh.white.surf.gii'. The directory must exist.
+//   - mesh: the mesh to write
+//   - encoding: the encoding of the data arrays, see GIFTI_ENCODING_ASCII and the other GIFTI_ENCODING_* constants. If empty, GIFTI_ENCODING_GZIP_BASE64 is used.
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteGiftiSurface(filepath string, mesh Mesh, encoding string) error {
+	gifti, err := MeshToGifti(mesh, encoding)
+	if err != nil {
+		return err
+	}
+	return WriteGifti(filepath, gifti)
+}
+
+// WriteGiftiSurfaceTo writes a mesh to w in GIFTI format, see WriteGiftiSurface.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - mesh: the mesh to write
+//   - encoding: the encoding of the data arrays, see WriteGiftiSurface
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteGiftiSurfaceTo(w io.Writer, mesh Mesh, encoding string) error {
+	gifti, err := MeshToGifti(mesh, encoding)
+	if err != nil {
+		return err
+	}
+	return WriteGiftiTo(w, gifti)
+}
@@ -0,0 +1,86 @@
+package neuro
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadGiftiSurfaceFrom(t *testing.T) {
+	mesh, err := ReadGiftiSurfaceFrom(strings.NewReader(testGiftiXml))
+	if err != nil {
+		t.Fatalf("ReadGiftiSurfaceFrom failed: %v", err)
+	}
+	if diff := cmp.Diff(Mesh{Vertices: []float32{0, 0, 0, 1, 0, 0, 0, 1, 0}, Faces: []int32{0, 1, 2}}, mesh); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWriteRereadGiftiSurface(t *testing.T) {
+	cube := GenerateCube()
+	for _, encoding := range []string{GIFTI_ENCODING_ASCII, GIFTI_ENCODING_BASE64, GIFTI_ENCODING_GZIP_BASE64, ""} {
+		surfFile := filepath.Join(t.TempDir(), "cube.surf.gii")
+		if err := WriteGiftiSurface(surfFile, cube, encoding); err != nil {
+			t.Fatalf("'%s': WriteGiftiSurface failed: %v", encoding, err)
+		}
+		mesh, err := ReadGiftiSurface(surfFile)
+		if err != nil {
+			t.Fatalf("'%s': ReadGiftiSurface failed: %v", encoding, err)
+		}
+		if diff := cmp.Diff(cube, mesh); diff != "" {
+			t.Errorf("'%s': %s", encoding, diff)
+		}
+	}
+
+	surfFile := filepath.Join(t.TempDir(), "cube.surf.gii")
+	if err := WriteGiftiSurface(surfFile, cube, ""); err != nil {
+		t.Fatalf("WriteGiftiSurface failed: %v", err)
+	}
+	mesh, err := ReadGiftiSurfaceFS(os.DirFS(filepath.Dir(surfFile)), "cube.surf.gii")
+	if err != nil {
+		t.Fatalf("ReadGiftiSurfaceFS failed: %v", err)
+	}
+	if diff := cmp.Diff(cube, mesh); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGiftiToMeshInvalid(t *testing.T) {
+	gifti, _ := MeshToGifti(GenerateCube(), "")
+	if _, err := GiftiToMesh(Gifti{DataArrays: gifti.DataArrays[:1]}); err == nil {
+		t.Errorf("expected error for missing triangles, got nil")
+	}
+	if _, err := GiftiToMesh(Gifti{DataArrays: gifti.DataArrays[1:]}); err == nil {
+		t.Errorf("expected error for missing pointset, got nil")
+	}
+
+	gifti.DataArrays[1].DataInt32 = append([]int32{}, gifti.DataArrays[1].DataInt32...)
+	gifti.DataArrays[1].DataInt32[0] = 8
+	if _, err := GiftiToMesh(gifti); err == nil {
+		t.Errorf("expected error for invalid vertex index, got nil")
+	}
+
+	if err := WriteGiftiSurfaceTo(&bytes.Buffer{}, Mesh{Vertices: []float32{1, 2}}, ""); err == nil {
+		t.Errorf("expected error for invalid mesh, got nil")
+	}
+}
+
+func ExampleWriteGiftiSurface() {
+	cube := GenerateCube()
+
+	surfFile := filepath.Join(os.TempDir(), "cube.surf.gii")
+	defer os.Remove(surfFile)
+	if err := WriteGiftiSurface(surfFile, cube, GIFTI_ENCODING_GZIP_BASE64); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	mesh, _ := ReadGiftiSurface(surfFile)
+	fmt.Printf("Mesh has %d vertices and %d faces.\n", NumVertices(mesh), NumFaces(mesh))
+	// Output: Mesh has 8 vertices and 12 faces.
+}
@@ -0,0 +1,166 @@
+package neuro
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testGiftiXml is a small GIFTI file like those written by other software, with an ASCII-encoded pointset in column-major order
+// and zlib-compressed triangles.
+const testGiftiXml = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE GIFTI SYSTEM "http://www.nitrc.org/frs/download.php/115/gifti.dtd">
+<GIFTI Version="1.0" NumberOfDataArrays="2">
+  <MetaData>
+    <MD><Name><![CDATA[UserName]]></Name><Value><![CDATA[tester]]></Value></MD>
+  </MetaData>
+  <LabelTable/>
+  <DataArray Intent="NIFTI_INTENT_POINTSET" DataType="NIFTI_TYPE_FLOAT32" ArrayIndexingOrder="ColumnMajorOrder" Dimensionality="2" Dim0="3" Dim1="3" Encoding="ASCII" Endian="LittleEndian" ExternalFileName="" ExternalFileOffset="">
+    <MetaData>
+      <MD><Name><![CDATA[AnatomicalStructurePrimary]]></Name><Value><![CDATA[CortexLeft]]></Value></MD>
+    </MetaData>
+    <CoordinateSystemTransformMatrix>
+      <DataSpace><![CDATA[NIFTI_XFORM_TALAIRACH]]></DataSpace>
+      <TransformedSpace><![CDATA[NIFTI_XFORM_TALAIRACH]]></TransformedSpace>
+      <MatrixData>1 0 0 10 0 1 0 20 0 0 1 30 0 0 0 1</MatrixData>
+    </CoordinateSystemTransformMatrix>
+    <Data>0 1 0
+0 0 1
+0 0 0</Data>
+  </DataArray>
+  <DataArray Intent="NIFTI_INTENT_TRIANGLE" DataType="NIFTI_TYPE_INT32" ArrayIndexingOrder="RowMajorOrder" Dimensionality="2" Dim0="1" Dim1="3" Encoding="GZipBase64Binary" Endian="LittleEndian" ExternalFileName="" ExternalFileOffset="">
+    <MetaData/>
+    <Data>eJxjYGBgYARiJiAGAAAcAAQ=</Data>
+  </DataArray>
+</GIFTI>
+`
+
+func TestReadGiftiFrom(t *testing.T) {
+	gifti, err := ReadGiftiFrom(strings.NewReader(testGiftiXml))
+	if err != nil {
+		t.Fatalf("ReadGiftiFrom failed: %v", err)
+	}
+	if gifti.Version != "1.0" || len(gifti.DataArrays) != 2 {
+		t.Fatalf("got version '%s' and %d data arrays, wanted '1.0' and 2", gifti.Version, len(gifti.DataArrays))
+	}
+	if diff := cmp.Diff(map[string]string{"UserName": "tester"}, gifti.MetaData); diff != "" {
+		t.Error(diff)
+	}
+
+	pointset := gifti.DataArrays[0]
+	if pointset.Intent != NIFTI_INTENT_POINTSET || pointset.DataType != NIFTI_TYPE_FLOAT32 || pointset.Encoding != GIFTI_ENCODING_ASCII {
+		t.Errorf("got intent %d, data type %d, encoding '%s' for the pointset", pointset.Intent, pointset.DataType, pointset.Encoding)
+	}
+	// The column-major data is converted to row-major order.
+	if diff := cmp.Diff([]float32{0, 0, 0, 1, 0, 0, 0, 1, 0}, pointset.DataFloat32); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]int{3, 3}, pointset.Dims); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(map[string]string{"AnatomicalStructurePrimary": "CortexLeft"}, pointset.MetaData); diff != "" {
+		t.Error(diff)
+	}
+	wantCs := []GiftiCoordinateSystem{{DataSpace: "NIFTI_XFORM_TALAIRACH", TransformedSpace: "NIFTI_XFORM_TALAIRACH", Matrix: [4][4]float64{{1, 0, 0, 10}, {0, 1, 0, 20}, {0, 0, 1, 30}, {0, 0, 0, 1}}}}
+	if diff := cmp.Diff(wantCs, pointset.CoordinateSystems); diff != "" {
+		t.Error(diff)
+	}
+
+	if diff := cmp.Diff([]int32{0, 1, 2}, gifti.DataArrays[1].DataInt32); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReadGiftiBinaryEncodings(t *testing.T) {
+	// Big endian base64 data, and real gzip instead of zlib data.
+	xmlTemplate := `<GIFTI Version="1.0" NumberOfDataArrays="1"><DataArray Intent="%s" DataType="%s" Dimensionality="1" Dim0="%s" Encoding="%s" Endian="%s"><Data>%s</Data></DataArray></GIFTI>`
+	replacer := func(values ...string) string {
+		s := xmlTemplate
+		for _, v := range values {
+			s = strings.Replace(s, "%s", v, 1)
+		}
+		return s
+	}
+
+	gifti, err := ReadGiftiFrom(strings.NewReader(replacer("NIFTI_INTENT_SHAPE", "NIFTI_TYPE_FLOAT32", "6", "Base64Binary", "BigEndian", "P4AAAEAAAABAQAAAQIAAAECgAABAwAAA")))
+	if err != nil {
+		t.Fatalf("ReadGiftiFrom failed: %v", err)
+	}
+	if diff := cmp.Diff([]float32{1, 2, 3, 4, 5, 6}, gifti.DataArrays[0].DataFloat32); diff != "" {
+		t.Error(diff)
+	}
+
+	gifti, err = ReadGiftiFrom(strings.NewReader(replacer("NIFTI_INTENT_NODE_INDEX", "NIFTI_TYPE_INT32", "3", "GZipBase64Binary", "LittleEndian", "H4sIAAAAAAACA2NgYGBgBGImIAYAeg52HQwAAAA=")))
+	if err != nil {
+		t.Fatalf("ReadGiftiFrom failed: %v", err)
+	}
+	if diff := cmp.Diff([]int32{0, 1, 2}, gifti.DataArrays[0].DataInt32); diff != "" {
+		t.Error(diff)
+	}
+
+	invalid := map[string]string{
+		"wrong number of values":  replacer("NIFTI_INTENT_SHAPE", "NIFTI_TYPE_FLOAT32", "5", "Base64Binary", "BigEndian", "P4AAAEAAAABAQAAAQIAAAECgAABAwAAA"),
+		"unknown intent":          replacer("NIFTI_INTENT_FOO", "NIFTI_TYPE_FLOAT32", "6", "Base64Binary", "BigEndian", "P4AAAEAAAABAQAAAQIAAAECgAABAwAAA"),
+		"unsupported data type":   replacer("NIFTI_INTENT_SHAPE", "NIFTI_TYPE_FLOAT64", "3", "Base64Binary", "BigEndian", "P4AAAEAAAABAQAAAQIAAAECgAABAwAAA"),
+		"external file":           replacer("NIFTI_INTENT_SHAPE", "NIFTI_TYPE_FLOAT32", "6", "ExternalFileBinary", "BigEndian", ""),
+		"invalid base64":          replacer("NIFTI_INTENT_SHAPE", "NIFTI_TYPE_FLOAT32", "6", "Base64Binary", "BigEndian", "not base64!"),
+		"not xml":                 "this is not a GIFTI file",
+		"negative dimensionality": `<GIFTI Version="1.0" NumberOfDataArrays="1"><DataArray Intent="NIFTI_INTENT_SHAPE" DataType="NIFTI_TYPE_FLOAT32" Dimensionality="-1" Encoding="ASCII"><Data>1</Data></DataArray></GIFTI>`,
+		"overflowing dimensions":  `<GIFTI Version="1.0" NumberOfDataArrays="1"><DataArray Intent="NIFTI_INTENT_SHAPE" DataType="NIFTI_TYPE_FLOAT32" Dimensionality="2" Dim0="1099511627776" Dim1="1099511627776" Encoding="Base64Binary"><Data>P4AAAA==</Data></DataArray></GIFTI>`,
+		"huge dimensions":         `<GIFTI Version="1.0" NumberOfDataArrays="1"><DataArray Intent="NIFTI_INTENT_SHAPE" DataType="NIFTI_TYPE_FLOAT32" Dimensionality="1" Dim0="1099511627776" Encoding="Base64Binary"><Data>P4AAAA==</Data></DataArray></GIFTI>`,
+	}
+	for name, xmlData := range invalid {
+		if _, err := ReadGiftiFrom(strings.NewReader(xmlData)); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestWriteRereadGiftiAllEncodings(t *testing.T) {
+	for _, encoding := range []string{GIFTI_ENCODING_ASCII, GIFTI_ENCODING_BASE64, GIFTI_ENCODING_GZIP_BASE64} {
+		gifti := Gifti{
+			MetaData: map[string]string{"b": "2", "a": "1 & <2>"},
+			DataArrays: []GiftiDataArray{
+				{Intent: NIFTI_INTENT_SHAPE, DataType: NIFTI_TYPE_FLOAT32, Dims: []int{4}, Encoding: encoding, DataFloat32: []float32{0.5, -1.25, 3.0e-7, 42}},
+				{Intent: NIFTI_INTENT_NODE_INDEX, DataType: NIFTI_TYPE_INT32, Dims: []int{2, 2}, Encoding: encoding, DataInt32: []int32{-3, 7, 2147483647, 0}},
+				{Intent: NIFTI_INTENT_NONE, DataType: NIFTI_TYPE_UINT8, Dims: []int{3}, Encoding: encoding, DataUint8: []uint8{0, 128, 255}},
+			},
+		}
+
+		var buf bytes.Buffer
+		if err := WriteGiftiTo(&buf, gifti); err != nil {
+			t.Fatalf("%s: WriteGiftiTo failed: %v", encoding, err)
+		}
+		reread, err := ReadGiftiFrom(&buf)
+		if err != nil {
+			t.Fatalf("%s: ReadGiftiFrom failed: %v", encoding, err)
+		}
+		gifti.Version = "1.0"
+		if diff := cmp.Diff(gifti, reread); diff != "" {
+			t.Errorf("%s: %s", encoding, diff)
+		}
+	}
+}
+
+func TestWriteGiftiInvalid(t *testing.T) {
+	invalid := map[string]GiftiDataArray{
+		"wrong number of values": {Intent: NIFTI_INTENT_SHAPE, DataType: NIFTI_TYPE_FLOAT32, Dims: []int{3}, DataFloat32: []float32{1, 2}},
+		"no dimensions":          {Intent: NIFTI_INTENT_SHAPE, DataType: NIFTI_TYPE_FLOAT32},
+		"invalid encoding":       {Intent: NIFTI_INTENT_SHAPE, DataType: NIFTI_TYPE_FLOAT32, Dims: []int{1}, Encoding: "Morse", DataFloat32: []float32{1}},
+		"invalid intent":         {Intent: 999, DataType: NIFTI_TYPE_FLOAT32, Dims: []int{1}, DataFloat32: []float32{1}},
+	}
+	for name, da := range invalid {
+		if err := WriteGiftiTo(&bytes.Buffer{}, Gifti{DataArrays: []GiftiDataArray{da}}); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+
+	var dtErr *UnsupportedNiftiDataTypeError
+	da := GiftiDataArray{Intent: NIFTI_INTENT_SHAPE, DataType: NIFTI_TYPE_FLOAT64, Dims: []int{1}}
+	if err := WriteGiftiTo(&bytes.Buffer{}, Gifti{DataArrays: []GiftiDataArray{da}}); !errors.As(err, &dtErr) {
+		t.Errorf("expected UnsupportedNiftiDataTypeError, got %v", err)
+	}
+}
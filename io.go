@@ -2,6 +2,7 @@ package neuro
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"bufio"
 )
@@ -59,8 +60,21 @@ func readLines(path string) ([]string, error) {
     }
     defer file.Close()
 
+    return readLinesFrom(file)
+}
+
+// readLinesFrom reads all lines from a reader
+// and returns a slice of its lines.
+//
+// Parameters:
+//  - r: the reader
+//
+// Returns:
+//  - lines: a slice of strings, each string is a line
+//  - error: an error if one occurred
+func readLinesFrom(r io.Reader) ([]string, error) {
     var lines []string
-    scanner := bufio.NewScanner(file)
+    scanner := bufio.NewScanner(r)
     for scanner.Scan() {
         lines = append(lines, scanner.Text())
     }
@@ -88,3 +102,31 @@ func writeLines(lines []string, path string) error {
     }
     return w.Flush()
 }
+
+// readInt3 reads a 3-byte big endian unsigned integer, as used for the vertex and face counts of old FreeSurfer file formats.
+func readInt3(r io.Reader) (int32, error) {
+	var b [3]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return int32(b[0])<<16 | int32(b[1])<<8 | int32(b[2]), nil
+}
+
+// readInt3Slice reads n 3-byte big endian unsigned integers, see readInt3.
+func readInt3Slice(r io.Reader, n int) ([]int32, error) {
+	b := make([]byte, 3*n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	values := make([]int32, n)
+	for idx := range values {
+		values[idx] = int32(b[3*idx])<<16 | int32(b[3*idx+1])<<8 | int32(b[3*idx+2])
+	}
+	return values, nil
+}
+
+// writeInt3 writes the lowest 3 bytes of v as a big endian unsigned integer, see readInt3.
+func writeInt3(w io.Writer, v int32) error {
+	_, err := w.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+	return err
+}
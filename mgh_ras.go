@@ -0,0 +1,143 @@
+package neuro
+
+import (
+	"fmt"
+	"math"
+)
+
+// getMghRasInfo returns the voxel sizes, the direction cosines and the RAS coordinates of the volume center from an MghHeader.
+//
+// If the RasGoodFlag of the header is not 1, the RAS info fields of the header must be treated as random data. In that case,
+// the default orientation of a conformed FreeSurfer volume (LIA, 1 mm isotropic voxels, centered at the origin) is returned,
+// like FreeSurfer does.
+//
+// Parameters:
+//   - hdr: MghHeader struct containing the header data
+//
+// Returns:
+//   - [3]float64: the voxel sizes in x, y and z direction (mm)
+//   - [3][3]float64: the 3x3 direction cosine matrix, with the directions of the voxel axes x, y and z in the columns
+//   - [3]float64: the RAS coordinates of the center of the volume
+func getMghRasInfo(hdr MghHeader) ([3]float64, [3][3]float64, [3]float64) {
+	delta := [3]float64{1.0, 1.0, 1.0}
+	mdc := [3][3]float64{{-1.0, 0.0, 0.0}, {0.0, 0.0, 1.0}, {0.0, -1.0, 0.0}}
+	cras := [3]float64{0.0, 0.0, 0.0}
+
+	if hdr.RasGoodFlag == 1 {
+		delta = [3]float64{float64(hdr.XSize), float64(hdr.YSize), float64(hdr.ZSize)}
+		// The header stores the direction cosines as x_r, x_a, x_s, y_r, y_a, y_s, z_r, z_a, z_s, i.e., each triple is a column.
+		for col := 0; col < 3; col++ {
+			for row := 0; row < 3; row++ {
+				mdc[row][col] = float64(hdr.Mdc[col*3+row])
+			}
+		}
+		cras = [3]float64{float64(hdr.Pxyz_c[0]), float64(hdr.Pxyz_c[1]), float64(hdr.Pxyz_c[2])}
+	}
+	return delta, mdc, cras
+}
+
+// getMghVox2Ras computes a vox2ras matrix for the given header, with the center of the volume mapped to the given RAS coordinates.
+//
+// Parameters:
+//   - hdr: MghHeader struct containing the header data
+//   - useScannerCenter: whether to map the volume center to the scanner RAS coordinates from the header (true), or to the origin (false, tkregister space).
+//
+// Returns:
+//   - [4][4]float64: the 4x4 vox2ras matrix
+func getMghVox2Ras(hdr MghHeader, useScannerCenter bool) [4][4]float64 {
+	delta, mdc, cras := getMghRasInfo(hdr)
+	if !useScannerCenter {
+		cras = [3]float64{0.0, 0.0, 0.0}
+	}
+
+	// Voxel coordinates of the center of the volume.
+	pcrs_c := [3]float64{float64(hdr.Dim1Length) / 2.0, float64(hdr.Dim2Length) / 2.0, float64(hdr.Dim3Length) / 2.0}
+
+	var vox2ras [4][4]float64
+	for row := 0; row < 3; row++ {
+		p0 := cras[row]
+		for col := 0; col < 3; col++ {
+			vox2ras[row][col] = mdc[row][col] * delta[col]
+			p0 -= vox2ras[row][col] * pcrs_c[col]
+		}
+		vox2ras[row][3] = p0
+	}
+	vox2ras[3] = [4]float64{0.0, 0.0, 0.0, 1.0}
+	return vox2ras
+}
+
+// invertAffine computes the inverse of a 4x4 affine transformation matrix, i.e., a matrix with last row 0, 0, 0, 1.
+//
+// Parameters:
+//   - m: the affine matrix to invert
+//
+// Returns:
+//   - [4][4]float64: the inverse matrix
+//   - error: an error if the matrix is singular
+func invertAffine(m [4][4]float64) ([4][4]float64, error) {
+	var inv [4][4]float64
+
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	if math.Abs(det) < 1e-12 {
+		return inv, fmt.Errorf("invertAffine: matrix is singular, cannot invert.")
+	}
+
+	inv[0][0] = (m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det
+	inv[0][1] = (m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det
+	inv[0][2] = (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det
+	inv[1][0] = (m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det
+	inv[1][1] = (m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det
+	inv[1][2] = (m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det
+	inv[2][0] = (m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det
+	inv[2][1] = (m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det
+	inv[2][2] = (m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det
+
+	for row := 0; row < 3; row++ {
+		inv[row][3] = -(inv[row][0]*m[0][3] + inv[row][1]*m[1][3] + inv[row][2]*m[2][3])
+	}
+	inv[3] = [4]float64{0.0, 0.0, 0.0, 1.0}
+	return inv, nil
+}
+
+// Vox2Ras computes the scanner vox2ras matrix of the volume, which maps voxel indices (column, row, slice) to scanner RAS coordinates.
+//
+// This is the matrix that FreeSurfer's 'mri_info --vox2ras' reports. If the RasGoodFlag of the header is not 1, the default
+// orientation of a conformed FreeSurfer volume is used, see TkrVox2Ras.
+//
+// Returns:
+//   - [4][4]float64: the 4x4 vox2ras matrix, in row-major order (the first index is the row).
+func (hdr MghHeader) Vox2Ras() [4][4]float64 {
+	return getMghVox2Ras(hdr, true)
+}
+
+// Ras2Vox computes the scanner ras2vox matrix of the volume, the inverse of the matrix returned by Vox2Ras.
+//
+// Returns:
+//   - [4][4]float64: the 4x4 ras2vox matrix, in row-major order (the first index is the row).
+//   - error: an error if the vox2ras matrix is singular, e.g., because the voxel sizes in the header are 0.
+func (hdr MghHeader) Ras2Vox() ([4][4]float64, error) {
+	return invertAffine(hdr.Vox2Ras())
+}
+
+// TkrVox2Ras computes the tkregister vox2ras matrix of the volume, which maps voxel indices to FreeSurfer tkregister (surface) RAS coordinates.
+//
+// This is the matrix that FreeSurfer's 'mri_info --vox2ras-tkr' reports. It is the same as the scanner vox2ras, but with
+// the center of the volume at the origin. The coordinates of FreeSurfer surfaces like '<subject>/surf/lh.white' are in tkregister space.
+// If the RasGoodFlag of the header is not 1, the default orientation of a conformed FreeSurfer volume (LIA, 1 mm isotropic voxels) is used.
+//
+// Returns:
+//   - [4][4]float64: the 4x4 tkregister vox2ras matrix, in row-major order (the first index is the row).
+func (hdr MghHeader) TkrVox2Ras() [4][4]float64 {
+	return getMghVox2Ras(hdr, false)
+}
+
+// TkrRas2Vox computes the tkregister ras2vox matrix of the volume, the inverse of the matrix returned by TkrVox2Ras.
+//
+// Returns:
+//   - [4][4]float64: the 4x4 tkregister ras2vox matrix, in row-major order (the first index is the row).
+//   - error: an error if the vox2ras matrix is singular, e.g., because the voxel sizes in the header are 0.
+func (hdr MghHeader) TkrRas2Vox() ([4][4]float64, error) {
+	return invertAffine(hdr.TkrVox2Ras())
+}
@@ -0,0 +1,110 @@
+package neuro
+
+import (
+	"fmt"
+	"testing"
+)
+
+func checkMatrixAlmostEqual(t *testing.T, name string, got [4][4]float64, want [4][4]float64) {
+	t.Helper()
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			if !almostEqualF64(got[row][col], want[row][col], 1e-4) {
+				t.Errorf("%s: got value %f at row %d, column %d, wanted %f", name, got[row][col], row, col, want[row][col])
+			}
+		}
+	}
+}
+
+func TestMghVox2Ras(t *testing.T) {
+	hdr, _ := ReadFsMghHeader("testdata/brain.mgz", "auto")
+
+	// known from external tests with standard software, try on command line: mri_info --vox2ras testdata/brain.mgz
+	want := [4][4]float64{
+		{-1.0, 0.0, 0.0, 127.5000},
+		{0.0, 0.0, 1.0, -98.6273},
+		{0.0, -1.0, 0.0, 79.0953},
+		{0.0, 0.0, 0.0, 1.0}}
+	checkMatrixAlmostEqual(t, "Vox2Ras", hdr.Vox2Ras(), want)
+}
+
+func TestMghRas2Vox(t *testing.T) {
+	hdr, _ := ReadFsMghHeader("testdata/brain.mgz", "auto")
+
+	// known from external tests with standard software, try on command line: mri_info --ras2vox testdata/brain.mgz
+	want := [4][4]float64{
+		{-1.0, 0.0, 0.0, 127.5000},
+		{0.0, 0.0, -1.0, 79.0953},
+		{0.0, 1.0, 0.0, 98.6273},
+		{0.0, 0.0, 0.0, 1.0}}
+	got, err := hdr.Ras2Vox()
+	if err != nil {
+		t.Errorf("Ras2Vox failed: %v", err)
+	}
+	checkMatrixAlmostEqual(t, "Ras2Vox", got, want)
+}
+
+func TestMghTkrVox2Ras(t *testing.T) {
+	hdr, _ := ReadFsMghHeader("testdata/brain.mgz", "auto")
+
+	// known from external tests with standard software, try on command line: mri_info --vox2ras-tkr testdata/brain.mgz
+	want := [4][4]float64{
+		{-1.0, 0.0, 0.0, 128.0},
+		{0.0, 0.0, 1.0, -128.0},
+		{0.0, -1.0, 0.0, 128.0},
+		{0.0, 0.0, 0.0, 1.0}}
+	checkMatrixAlmostEqual(t, "TkrVox2Ras", hdr.TkrVox2Ras(), want)
+
+	wantInv := [4][4]float64{
+		{-1.0, 0.0, 0.0, 128.0},
+		{0.0, 0.0, -1.0, 128.0},
+		{0.0, 1.0, 0.0, 128.0},
+		{0.0, 0.0, 0.0, 1.0}}
+	gotInv, err := hdr.TkrRas2Vox()
+	if err != nil {
+		t.Errorf("TkrRas2Vox failed: %v", err)
+	}
+	checkMatrixAlmostEqual(t, "TkrRas2Vox", gotInv, wantInv)
+}
+
+func TestMghVox2RasRasNotGood(t *testing.T) {
+	// A header with RasGoodFlag != 1 and random data in the RAS fields.
+	hdr := MghHeader{MghVersion: 1, Dim1Length: 256, Dim2Length: 256, Dim3Length: 256, Dim4Length: 1, RasGoodFlag: 0,
+		XSize: 3.0, YSize: 0.0, ZSize: 2.0, Pxyz_c: [3]float32{10.0, 20.0, 30.0}}
+
+	want := [4][4]float64{
+		{-1.0, 0.0, 0.0, 128.0},
+		{0.0, 0.0, 1.0, -128.0},
+		{0.0, -1.0, 0.0, 128.0},
+		{0.0, 0.0, 0.0, 1.0}}
+	checkMatrixAlmostEqual(t, "Vox2Ras", hdr.Vox2Ras(), want)
+	checkMatrixAlmostEqual(t, "TkrVox2Ras", hdr.TkrVox2Ras(), want)
+}
+
+func TestMghRas2VoxSingular(t *testing.T) {
+	hdr := MghHeader{MghVersion: 1, Dim1Length: 10, Dim2Length: 10, Dim3Length: 10, Dim4Length: 1, RasGoodFlag: 1,
+		XSize: 0.0, YSize: 0.0, ZSize: 0.0}
+
+	_, err := hdr.Ras2Vox()
+	if err == nil {
+		t.Errorf("expected error for singular vox2ras matrix, got nil")
+	}
+}
+
+func ExampleMghHeader_Vox2Ras() {
+	var mgzFile string = "testdata/brain.mgz"
+
+	hdr, _ := ReadFsMghHeader(mgzFile, "auto")
+	vox2ras := hdr.Vox2Ras()
+
+	// Compute the scanner RAS coordinates of the voxel at indices (99, 99, 99).
+	voxel := [4]float64{99.0, 99.0, 99.0, 1.0}
+	var ras [3]float64
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 4; col++ {
+			ras[row] += vox2ras[row][col] * voxel[col]
+		}
+	}
+	fmt.Printf("RAS coordinates of voxel (99, 99, 99): %.2f, %.2f, %.2f\n", ras[0], ras[1], ras[2])
+	// Output: RAS coordinates of voxel (99, 99, 99): 28.50, 0.37, -19.90
+}
@@ -0,0 +1,252 @@
+package neuro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// newMghData creates an MghData struct with a data slice of the given type and length. All values are 0.
+//
+// Parameters:
+//   - dtCode: The MRI data type code, e.g., MRI_FLOAT.
+//   - numValues: The length of the data slice.
+//
+// Returns:
+//   - MghData: the MghData struct
+//   - error: an error if the data type is invalid or unsupported
+func newMghData(dtCode int32, numValues int) (MghData, error) {
+	data := MghData{MghDataType: dtCode}
+	switch dtCode {
+	case MRI_UCHAR:
+		data.DataMriUchar = make([]uint8, numValues)
+	case MRI_INT:
+		data.DataMriInt = make([]int32, numValues)
+	case MRI_FLOAT:
+		data.DataMriFloat = make([]float32, numValues)
+	case MRI_SHORT:
+		data.DataMriShort = make([]int16, numValues)
+	case MRI_LONG:
+		data.DataMriLong = make([]int64, numValues)
+	case MRI_USHRT:
+		data.DataMriUshort = make([]uint16, numValues)
+	default:
+		return MghData{MghDataType: -1}, &UnsupportedMghDataTypeError{Code: dtCode}
+	}
+	return data, nil
+}
+
+// decodeMghValues decodes the big endian values in bs into the valid data slice of data, starting at index pos of the slice.
+//
+// Parameters:
+//   - bs: the raw bytes, their length must be a multiple of the size of the data type
+//   - data: the MghData struct to store the values in, its data slice must be long enough
+//   - pos: the index of the data slice at which to store the first value
+func decodeMghValues(bs []byte, data MghData, pos int) {
+	endian := binary.BigEndian
+	switch data.MghDataType {
+	case MRI_UCHAR:
+		copy(data.DataMriUchar[pos:], bs)
+	case MRI_INT:
+		for idx := 0; idx < len(bs)/4; idx++ {
+			data.DataMriInt[pos+idx] = int32(endian.Uint32(bs[idx*4:]))
+		}
+	case MRI_FLOAT:
+		for idx := 0; idx < len(bs)/4; idx++ {
+			data.DataMriFloat[pos+idx] = math.Float32frombits(endian.Uint32(bs[idx*4:]))
+		}
+	case MRI_SHORT:
+		for idx := 0; idx < len(bs)/2; idx++ {
+			data.DataMriShort[pos+idx] = int16(endian.Uint16(bs[idx*2:]))
+		}
+	case MRI_LONG:
+		for idx := 0; idx < len(bs)/8; idx++ {
+			data.DataMriLong[pos+idx] = int64(endian.Uint64(bs[idx*8:]))
+		}
+	case MRI_USHRT:
+		for idx := 0; idx < len(bs)/2; idx++ {
+			data.DataMriUshort[pos+idx] = endian.Uint16(bs[idx*2:])
+		}
+	}
+}
+
+// MghReader provides random access to parts of the data of an uncompressed MGH file, without reading the whole file.
+//
+// This is useful for large 4D files, e.g., group-level per-vertex data on fsaverage with thousands of frames, where only
+// a single frame or the time course of a single voxel is needed. Because random access is not possible in gzip-compressed
+// data, MghReader cannot be used for MGZ files. Use an MghFrameIterator to read MGZ files frame by frame.
+//
+// All data returned by the methods of MghReader is in the same column-major order as in MGH files, i.e., the first dimension varies fastest.
+type MghReader struct {
+	Header MghHeader // The header of the MGH file.
+	ra     io.ReaderAt
+}
+
+// NewMghReader creates an MghReader for the uncompressed MGH data in ra, and reads the header.
+//
+// Parameters:
+//   - ra: the data source, typically an *os.File opened with os.Open. The caller is responsible for closing it after use.
+//
+// Returns:
+//   - *MghReader: the reader
+//   - error: an error if one occurred, e.g., if the header is invalid
+func NewMghReader(ra io.ReaderAt) (*MghReader, error) {
+	hdr, err := readFsMghHeader(io.NewSectionReader(ra, 0, 284))
+	if err != nil {
+		return nil, fmt.Errorf("NewMghReader: failed to read MGH header: %w", err)
+	}
+	// The size of all blocks is bounded by the checked number of values of the volume.
+	if _, err := getMghCheckedNumValues(hdr); err != nil {
+		return nil, fmt.Errorf("NewMghReader: %w", err)
+	}
+	return &MghReader{Header: hdr, ra: ra}, nil
+}
+
+// Dims returns the lengths of the 4 dimensions of the volume.
+//
+// Returns:
+//   - [4]int: the dimension lengths, from the header
+func (mr *MghReader) Dims() [4]int {
+	return [4]int{int(mr.Header.Dim1Length), int(mr.Header.Dim2Length), int(mr.Header.Dim3Length), int(mr.Header.Dim4Length)}
+}
+
+// ReadBlock reads a 4D sub-block of the volume.
+//
+// Parameters:
+//   - start: the index of the first voxel of the block in the 4 dimensions
+//   - size: the size of the block in the 4 dimensions. All sizes must be at least 1.
+//
+// Returns:
+//   - MghData: the data of the block, in column-major order, i.e., the value at block indices (i, j, k, t) is at index i + j*size[0] + k*size[0]*size[1] + t*size[0]*size[1]*size[2].
+//   - error: an error if one occurred, e.g., if the block is not inside the volume
+func (mr *MghReader) ReadBlock(start [4]int, size [4]int) (MghData, error) {
+	dims := mr.Dims()
+	numValues := 1
+	for dim := 0; dim < 4; dim++ {
+		if size[dim] < 1 || start[dim] < 0 || start[dim] > dims[dim] || size[dim] > dims[dim]-start[dim] {
+			return MghData{MghDataType: -1}, fmt.Errorf("ReadBlock: block with start %v and size %v exceeds volume with dimensions %v.", start, size, dims)
+		}
+		numValues *= size[dim]
+	}
+
+	dtSize, err := getMghDataTypeSize(mr.Header.MghDataType)
+	if err != nil {
+		return MghData{MghDataType: -1}, err
+	}
+	data, err := newMghData(mr.Header.MghDataType, numValues)
+	if err != nil {
+		return data, err
+	}
+
+	// Voxels are contiguous along the first dimension only, so read one run of size[0] values at a time.
+	run := make([]byte, size[0]*dtSize)
+	pos := 0
+	for t := start[3]; t < start[3]+size[3]; t++ {
+		for k := start[2]; k < start[2]+size[2]; k++ {
+			for j := start[1]; j < start[1]+size[1]; j++ {
+				voxelIndex := int64(start[0]) + int64(j)*int64(dims[0]) + int64(k)*int64(dims[0])*int64(dims[1]) + int64(t)*int64(dims[0])*int64(dims[1])*int64(dims[2])
+				offset := 284 + voxelIndex*int64(dtSize)
+				// ReaderAt implementations may return io.EOF together with all bytes at the end of the input.
+				if n, err := mr.ra.ReadAt(run, offset); err != nil && !(err == io.EOF && n == len(run)) {
+					return data, fmt.Errorf("ReadBlock: failed to read %d bytes at offset %d: %w", len(run), offset, err)
+				}
+				decodeMghValues(run, data, pos)
+				pos += size[0]
+			}
+		}
+	}
+	return data, nil
+}
+
+// ReadFrame reads a single frame, i.e., the 3D volume at the given index of the 4th dimension.
+//
+// Parameters:
+//   - frame: the index of the frame, in range 0 to Dim4Length-1
+//
+// Returns:
+//   - MghData: the data of the frame, in column-major order
+//   - error: an error if one occurred, e.g., if the frame index is out of range
+func (mr *MghReader) ReadFrame(frame int) (MghData, error) {
+	dims := mr.Dims()
+	return mr.ReadBlock([4]int{0, 0, 0, frame}, [4]int{dims[0], dims[1], dims[2], 1})
+}
+
+// ReadVoxelTimeCourse reads the values of a single voxel in all frames.
+//
+// Parameters:
+//   - i, j, k: the indices of the voxel in the first 3 dimensions
+//
+// Returns:
+//   - MghData: the values of the voxel, one per frame
+//   - error: an error if one occurred, e.g., if the voxel indices are out of range
+func (mr *MghReader) ReadVoxelTimeCourse(i int, j int, k int) (MghData, error) {
+	dims := mr.Dims()
+	return mr.ReadBlock([4]int{i, j, k, 0}, [4]int{1, 1, 1, dims[3]})
+}
+
+// MghFrameIterator reads the frames of MGH or MGZ data one after the other, so only a single frame has to be kept in memory.
+//
+// This is the sequential alternative to MghReader for gzip-compressed MGZ data, which does not support random access.
+type MghFrameIterator struct {
+	Header    MghHeader // The header of the MGH data.
+	stream    *mghStream
+	nextFrame int
+}
+
+// NewMghFrameIterator creates an MghFrameIterator for the MGH or MGZ data in r, and reads the header.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File. The caller is responsible for closing it after use.
+//   - isGzipped: Whether to treat the data as gzip-compressed. If "auto", the data is checked for the gzip magic bytes. If not "auto", it has to be "yes"/"mgz" or "no"/"mgh" to force MGZ or MGH format, respectively.
+//
+// Returns:
+//   - *MghFrameIterator: the iterator. Call Close when done.
+//   - error: an error if one occurred, e.g., if the header is invalid
+func NewMghFrameIterator(r io.Reader, isGzipped string) (*MghFrameIterator, error) {
+	s, err := newMghStream(r, isGzipped)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := readFsMghHeader(s)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("NewMghFrameIterator: failed to read MGH header: %w", err)
+	}
+	return &MghFrameIterator{Header: hdr, stream: s}, nil
+}
+
+// Next reads the next frame.
+//
+// Returns:
+//   - MghData: the data of the frame, in column-major order
+//   - error: io.EOF if all frames have been read, or another error if reading failed
+func (it *MghFrameIterator) Next() (MghData, error) {
+	if it.nextFrame >= int(it.Header.Dim4Length) {
+		return MghData{MghDataType: -1}, io.EOF
+	}
+	frameHdr := it.Header
+	frameHdr.Dim4Length = 1
+	data, err := readFsMghData(it.stream, frameHdr)
+	if err != nil {
+		return data, fmt.Errorf("MghFrameIterator: failed to read frame %d: %w", it.nextFrame, err)
+	}
+	it.nextFrame++
+	return data, nil
+}
+
+// Frame returns the index of the frame that the next call to Next will return.
+//
+// Returns:
+//   - int: the frame index
+func (it *MghFrameIterator) Frame() int {
+	return it.nextFrame
+}
+
+// Close releases the resources of the iterator. It does not close the underlying reader.
+//
+// Returns:
+//   - error: an error if one occurred
+func (it *MghFrameIterator) Close() error {
+	return it.stream.Close()
+}
@@ -0,0 +1,208 @@
+package neuro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// getTestMgh4D creates a small 4D float MGH volume in which each voxel value encodes its indices as i + 10*j + 100*k + 1000*t.
+func getTestMgh4D() Mgh {
+	dims := [4]int{4, 3, 2, 5}
+	data := make([]float32, dims[0]*dims[1]*dims[2]*dims[3])
+	idx := 0
+	for t := 0; t < dims[3]; t++ {
+		for k := 0; k < dims[2]; k++ {
+			for j := 0; j < dims[1]; j++ {
+				for i := 0; i < dims[0]; i++ {
+					data[idx] = float32(i + 10*j + 100*k + 1000*t)
+					idx++
+				}
+			}
+		}
+	}
+	hdr := MghHeader{MghVersion: 1, Dim1Length: int32(dims[0]), Dim2Length: int32(dims[1]), Dim3Length: int32(dims[2]), Dim4Length: int32(dims[3]), MghDataType: MRI_FLOAT}
+	return Mgh{Header: hdr, Data: MghData{DataMriFloat: data, MghDataType: MRI_FLOAT}}
+}
+
+func getTestMgh4DBytes(t *testing.T, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteFsMghTo(&buf, getTestMgh4D(), compress); err != nil {
+		t.Fatalf("WriteFsMghTo failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestMghReaderReadFrame(t *testing.T) {
+	mr, err := NewMghReader(bytes.NewReader(getTestMgh4DBytes(t, false)))
+	if err != nil {
+		t.Fatalf("NewMghReader failed: %v", err)
+	}
+	if diff := cmp.Diff([4]int{4, 3, 2, 5}, mr.Dims()); diff != "" {
+		t.Error(diff)
+	}
+
+	frame, err := mr.ReadFrame(3)
+	if err != nil {
+		t.Fatalf("ReadFrame failed: %v", err)
+	}
+	want := getTestMgh4D().Data.DataMriFloat[3*24 : 4*24]
+	if diff := cmp.Diff(want, frame.DataMriFloat); diff != "" {
+		t.Error(diff)
+	}
+	if frame.MghDataType != MRI_FLOAT {
+		t.Errorf("got data type %d, wanted %d", frame.MghDataType, MRI_FLOAT)
+	}
+}
+
+func TestMghReaderReadVoxelTimeCourse(t *testing.T) {
+	mr, err := NewMghReader(bytes.NewReader(getTestMgh4DBytes(t, false)))
+	if err != nil {
+		t.Fatalf("NewMghReader failed: %v", err)
+	}
+
+	tc, err := mr.ReadVoxelTimeCourse(2, 1, 1)
+	if err != nil {
+		t.Fatalf("ReadVoxelTimeCourse failed: %v", err)
+	}
+	if diff := cmp.Diff([]float32{112, 1112, 2112, 3112, 4112}, tc.DataMriFloat); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMghReaderReadBlock(t *testing.T) {
+	mr, err := NewMghReader(bytes.NewReader(getTestMgh4DBytes(t, false)))
+	if err != nil {
+		t.Fatalf("NewMghReader failed: %v", err)
+	}
+
+	block, err := mr.ReadBlock([4]int{1, 1, 0, 2}, [4]int{2, 2, 1, 2})
+	if err != nil {
+		t.Fatalf("ReadBlock failed: %v", err)
+	}
+	want := []float32{2011, 2012, 2021, 2022, 3011, 3012, 3021, 3022}
+	if diff := cmp.Diff(want, block.DataMriFloat); diff != "" {
+		t.Error(diff)
+	}
+
+	if _, err := mr.ReadBlock([4]int{3, 0, 0, 0}, [4]int{2, 1, 1, 1}); err == nil {
+		t.Errorf("expected error for block exceeding the volume, got nil")
+	}
+	if _, err := mr.ReadBlock([4]int{1, 0, 0, 0}, [4]int{math.MaxInt, 1, 1, 1}); err == nil {
+		t.Errorf("expected error for block size that overflows, got nil")
+	}
+	if _, err := mr.ReadFrame(5); err == nil {
+		t.Errorf("expected error for frame index out of range, got nil")
+	}
+}
+
+// eofReaderAt is an io.ReaderAt that returns io.EOF together with the last bytes of its data, which the io.ReaderAt contract allows.
+type eofReaderAt struct {
+	data []byte
+}
+
+func (r eofReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := bytes.NewReader(r.data).ReadAt(p, off)
+	if err == nil && off+int64(n) == int64(len(r.data)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func TestMghReaderReadBlockAtEndOfData(t *testing.T) {
+	// Without a footer, the last voxel is at the very end of the data.
+	data := getTestMgh4DBytes(t, false)[:284+120*4]
+	mr, err := NewMghReader(eofReaderAt{data: data})
+	if err != nil {
+		t.Fatalf("NewMghReader failed: %v", err)
+	}
+	block, err := mr.ReadBlock([4]int{2, 2, 1, 4}, [4]int{2, 1, 1, 1})
+	if err != nil {
+		t.Fatalf("ReadBlock failed: %v", err)
+	}
+	if diff := cmp.Diff([]float32{4122, 4123}, block.DataMriFloat); diff != "" {
+		t.Error(diff)
+	}
+
+	mr, err = NewMghReader(eofReaderAt{data: data[:len(data)-1]})
+	if err != nil {
+		t.Fatalf("NewMghReader failed: %v", err)
+	}
+	if _, err := mr.ReadBlock([4]int{2, 2, 1, 4}, [4]int{2, 1, 1, 1}); err == nil {
+		t.Errorf("expected error for truncated data, got nil")
+	}
+}
+
+func TestNewMghReaderInvalidDimensions(t *testing.T) {
+	for _, d := range [][4]int32{{50000, 50000, 50000, 50000}, {65536, 65536, 65536, 65536}, {2, -1, 1, 1}} {
+		var buf bytes.Buffer
+		hdr := MghHeader{MghVersion: 1, Dim1Length: d[0], Dim2Length: d[1], Dim3Length: d[2], Dim4Length: d[3], MghDataType: MRI_FLOAT}
+		binary.Write(&buf, binary.BigEndian, &hdr)
+		if _, err := NewMghReader(bytes.NewReader(buf.Bytes())); err == nil {
+			t.Errorf("expected error for dimensions %v, got nil", d)
+		}
+	}
+}
+
+func TestMghReaderFromFile(t *testing.T) {
+	file, err := os.Open("testdata/lh.thickness.fwhm5.fsaverage.mgh")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+
+	mr, err := NewMghReader(file)
+	if err != nil {
+		t.Fatalf("NewMghReader failed: %v", err)
+	}
+	mgh, err := ReadFsMgh("testdata/lh.thickness.fwhm5.fsaverage.mgh", "no")
+	if err != nil {
+		t.Fatalf("ReadFsMgh failed: %v", err)
+	}
+
+	block, err := mr.ReadBlock([4]int{1000, 0, 0, 0}, [4]int{10, 1, 1, 1})
+	if err != nil {
+		t.Fatalf("ReadBlock failed: %v", err)
+	}
+	if diff := cmp.Diff(mgh.Data.DataMriFloat[1000:1010], block.DataMriFloat); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMghFrameIterator(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		it, err := NewMghFrameIterator(bytes.NewReader(getTestMgh4DBytes(t, compress)), "auto")
+		if err != nil {
+			t.Fatalf("NewMghFrameIterator failed: %v", err)
+		}
+
+		want := getTestMgh4D().Data.DataMriFloat
+		numFrames := 0
+		for {
+			frameIndex := it.Frame()
+			frame, err := it.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Next failed for frame %d: %v", frameIndex, err)
+			}
+			if diff := cmp.Diff(want[frameIndex*24:(frameIndex+1)*24], frame.DataMriFloat); diff != "" {
+				t.Errorf("frame %d (compress=%t): %s", frameIndex, compress, diff)
+			}
+			numFrames++
+		}
+		if numFrames != 5 {
+			t.Errorf("got %d frames (compress=%t), wanted %d", numFrames, compress, 5)
+		}
+		if err := it.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package neuro
+
+import (
+	"fmt"
+
+	"gorgonia.org/tensor"
+)
+
+// reorderColumnToRowMajor returns a copy of the 4D data in src, converted from column-major order (the MGH layout, first dimension varies fastest) to row-major order (last dimension varies fastest).
+func reorderColumnToRowMajor[T any](src []T, shape [4]int) []T {
+	dst := make([]T, len(src))
+	r := 0
+	for i := 0; i < shape[0]; i++ {
+		for j := 0; j < shape[1]; j++ {
+			for k := 0; k < shape[2]; k++ {
+				for t := 0; t < shape[3]; t++ {
+					dst[r] = src[i+shape[0]*(j+shape[1]*(k+shape[2]*t))]
+					r++
+				}
+			}
+		}
+	}
+	return dst
+}
+
+// reorderRowToColumnMajor returns a copy of the 4D data in src, converted from row-major order to column-major order (the MGH layout). It is the inverse of reorderColumnToRowMajor.
+func reorderRowToColumnMajor[T any](src []T, shape [4]int) []T {
+	dst := make([]T, len(src))
+	r := 0
+	for i := 0; i < shape[0]; i++ {
+		for j := 0; j < shape[1]; j++ {
+			for k := 0; k < shape[2]; k++ {
+				for t := 0; t < shape[3]; t++ {
+					dst[i+shape[0]*(j+shape[1]*(k+shape[2]*t))] = src[r]
+					r++
+				}
+			}
+		}
+	}
+	return dst
+}
+
+// ToTensor converts the data of an Mgh struct into a 4D tensor from the gorgonia.org/tensor package.
+//
+// The tensor has shape (Dim1Length, Dim2Length, Dim3Length, Dim4Length), so the value of voxel (i, j, k) in frame t is at
+// tensor coordinates (i, j, k, t), like in FreeSurfer tools such as 'mri_info --voxel'. The data is copied, and reordered
+// from the column-major MGH layout to the row-major layout of the tensor. The dtype of the tensor matches the MRI data
+// type: tensor.Uint8 for MRI_UCHAR, tensor.Int32 for MRI_INT, tensor.Int64 for MRI_LONG, tensor.Float32 for MRI_FLOAT,
+// tensor.Int16 for MRI_SHORT and tensor.Uint16 for MRI_USHRT.
+//
+// The tensor does not contain the header, keep mgh.Header and pass it to MghFromTensor to convert the tensor back.
+//
+// Returns:
+//   - *tensor.Dense: the tensor
+//   - error: an error if one occurred, e.g., if the data type is unsupported or the data length does not match the header dimensions
+func (mgh Mgh) ToTensor() (*tensor.Dense, error) {
+	volume, err := NewVolumeFromMgh(mgh)
+	if err != nil {
+		return nil, fmt.Errorf("ToTensor: %w", err)
+	}
+	shape := volume.Shape()
+
+	var backing interface{}
+	switch mgh.Data.MghDataType {
+	case MRI_UCHAR:
+		backing = reorderColumnToRowMajor(mgh.Data.DataMriUchar, shape)
+	case MRI_INT:
+		backing = reorderColumnToRowMajor(mgh.Data.DataMriInt, shape)
+	case MRI_FLOAT:
+		backing = reorderColumnToRowMajor(mgh.Data.DataMriFloat, shape)
+	case MRI_SHORT:
+		backing = reorderColumnToRowMajor(mgh.Data.DataMriShort, shape)
+	case MRI_LONG:
+		backing = reorderColumnToRowMajor(mgh.Data.DataMriLong, shape)
+	case MRI_USHRT:
+		backing = reorderColumnToRowMajor(mgh.Data.DataMriUshort, shape)
+	}
+	return tensor.New(tensor.WithShape(shape[0], shape[1], shape[2], shape[3]), tensor.WithBacking(backing)), nil
+}
+
+// MghFromTensor creates an Mgh struct from a tensor of the gorgonia.org/tensor package, e.g., one created with Mgh.ToTensor.
+//
+// The tensor coordinates (i, j, k, t) are interpreted as voxel (i, j, k) in frame t. Tensors with less than 4 dimensions are
+// treated as if the missing trailing dimensions had length 1. The data is copied.
+//
+// Parameters:
+//   - t: the tensor. Its dtype must be one of tensor.Uint8, tensor.Int32, tensor.Int64, tensor.Float32, tensor.Int16 or tensor.Uint16, and it must have 1 to 4 dimensions.
+//   - hdr: the header to use, typically the header of the Mgh struct the tensor was created from. The dimension lengths and the data type are set from the tensor, all other fields (like the RAS information) are kept.
+//
+// Returns:
+//   - Mgh: the Mgh struct. Its footer is empty.
+//   - error: an error if one occurred, e.g., if the dtype of the tensor is not supported
+func MghFromTensor(t *tensor.Dense, hdr MghHeader) (Mgh, error) {
+	tensorShape := t.Shape()
+	if len(tensorShape) < 1 || len(tensorShape) > 4 {
+		return Mgh{}, fmt.Errorf("MghFromTensor: tensor must have 1 to 4 dimensions, but has %d.", len(tensorShape))
+	}
+	shape := [4]int{1, 1, 1, 1}
+	copy(shape[:], tensorShape)
+
+	// Views (e.g., from slicing or transposing) share the data of another tensor in a different layout, so copy them into a new tensor first.
+	if t.IsMaterializable() {
+		t = t.Materialize().(*tensor.Dense)
+	}
+	isColMajor := t.DataOrder().IsColMajor()
+
+	var data MghData
+	switch dt := t.Dtype(); dt {
+	case tensor.Uint8:
+		data.MghDataType = MRI_UCHAR
+		values := t.Data().([]uint8)
+		if isColMajor {
+			data.DataMriUchar = append([]uint8(nil), values...)
+		} else {
+			data.DataMriUchar = reorderRowToColumnMajor(values, shape)
+		}
+	case tensor.Int32:
+		data.MghDataType = MRI_INT
+		values := t.Data().([]int32)
+		if isColMajor {
+			data.DataMriInt = append([]int32(nil), values...)
+		} else {
+			data.DataMriInt = reorderRowToColumnMajor(values, shape)
+		}
+	case tensor.Float32:
+		data.MghDataType = MRI_FLOAT
+		values := t.Data().([]float32)
+		if isColMajor {
+			data.DataMriFloat = append([]float32(nil), values...)
+		} else {
+			data.DataMriFloat = reorderRowToColumnMajor(values, shape)
+		}
+	case tensor.Int16:
+		data.MghDataType = MRI_SHORT
+		values := t.Data().([]int16)
+		if isColMajor {
+			data.DataMriShort = append([]int16(nil), values...)
+		} else {
+			data.DataMriShort = reorderRowToColumnMajor(values, shape)
+		}
+	case tensor.Int64:
+		data.MghDataType = MRI_LONG
+		values := t.Data().([]int64)
+		if isColMajor {
+			data.DataMriLong = append([]int64(nil), values...)
+		} else {
+			data.DataMriLong = reorderRowToColumnMajor(values, shape)
+		}
+	case tensor.Uint16:
+		data.MghDataType = MRI_USHRT
+		values := t.Data().([]uint16)
+		if isColMajor {
+			data.DataMriUshort = append([]uint16(nil), values...)
+		} else {
+			data.DataMriUshort = reorderRowToColumnMajor(values, shape)
+		}
+	default:
+		return Mgh{}, fmt.Errorf("MghFromTensor: unsupported tensor dtype '%s', must be one of uint8, int32, int64, float32, int16 or uint16.", dt)
+	}
+
+	hdr.Dim1Length = int32(shape[0])
+	hdr.Dim2Length = int32(shape[1])
+	hdr.Dim3Length = int32(shape[2])
+	hdr.Dim4Length = int32(shape[3])
+	hdr.MghDataType = data.MghDataType
+	return Mgh{Header: hdr, Data: data}, nil
+}
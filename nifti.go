@@ -0,0 +1,557 @@
+package neuro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// NIfTI data type codes, used in the Datatype field of the NIfTI-1 and NIfTI-2 headers.
+const (
+	NIFTI_TYPE_UINT8      int16 = 2    // 8 bit unsigned integer
+	NIFTI_TYPE_INT16      int16 = 4    // 16 bit signed integer
+	NIFTI_TYPE_INT32      int16 = 8    // 32 bit signed integer
+	NIFTI_TYPE_FLOAT32    int16 = 16   // 32 bit float
+	NIFTI_TYPE_COMPLEX64  int16 = 32   // complex number made of two 32 bit floats, not supported by this package
+	NIFTI_TYPE_FLOAT64    int16 = 64   // 64 bit float
+	NIFTI_TYPE_RGB24      int16 = 128  // RGB color with 3 8 bit channels, not supported by this package
+	NIFTI_TYPE_INT8       int16 = 256  // 8 bit signed integer
+	NIFTI_TYPE_UINT16     int16 = 512  // 16 bit unsigned integer
+	NIFTI_TYPE_UINT32     int16 = 768  // 32 bit unsigned integer
+	NIFTI_TYPE_INT64      int16 = 1024 // 64 bit signed integer
+	NIFTI_TYPE_UINT64     int16 = 1280 // 64 bit unsigned integer
+	NIFTI_TYPE_FLOAT128   int16 = 1536 // 128 bit float, not supported by this package
+	NIFTI_TYPE_COMPLEX128 int16 = 1792 // complex number made of two 64 bit floats, not supported by this package
+	NIFTI_TYPE_COMPLEX256 int16 = 2048 // complex number made of two 128 bit floats, not supported by this package
+	NIFTI_TYPE_RGBA32     int16 = 2304 // RGBA color with 4 8 bit channels, not supported by this package
+)
+
+// NIfTI transform codes, used in the QformCode and SformCode fields of the NIfTI-1 and NIfTI-2 headers. They describe the world coordinate system of the affine matrix.
+const (
+	NIFTI_XFORM_UNKNOWN      int16 = 0 // the affine matrix is not set
+	NIFTI_XFORM_SCANNER_ANAT int16 = 1 // scanner-based anatomical coordinates, this is what MGH files use
+	NIFTI_XFORM_ALIGNED_ANAT int16 = 2 // coordinates aligned to another file or a truth
+	NIFTI_XFORM_TALAIRACH    int16 = 3 // Talairach-Tournoux atlas coordinates
+	NIFTI_XFORM_MNI_152      int16 = 4 // MNI 152 normalized coordinates
+)
+
+// NIfTI intent codes, used in the IntentCode field of the NIfTI-1 and NIfTI-2 headers and, by name, in the Intent attribute of GIFTI data arrays. They describe what the data means.
+const (
+	NIFTI_INTENT_NONE        int16 = 0    // no particular meaning
+	NIFTI_INTENT_TTEST       int16 = 3    // Student t statistic
+	NIFTI_INTENT_ZSCORE      int16 = 5    // z score
+	NIFTI_INTENT_PVAL        int16 = 22   // p value
+	NIFTI_INTENT_ESTIMATE    int16 = 1001 // estimate of a parameter
+	NIFTI_INTENT_LABEL       int16 = 1002 // index into a label table
+	NIFTI_INTENT_VECTOR      int16 = 1007 // a vector per element
+	NIFTI_INTENT_POINTSET    int16 = 1008 // coordinates of points, e.g., the vertices of a mesh
+	NIFTI_INTENT_TRIANGLE    int16 = 1009 // triangles given by three point indices, e.g., the faces of a mesh
+	NIFTI_INTENT_TIME_SERIES int16 = 2001 // a time series per element
+	NIFTI_INTENT_NODE_INDEX  int16 = 2002 // indices of the nodes (vertices) the data belongs to
+	NIFTI_INTENT_RGB_VECTOR  int16 = 2003 // an RGB color per element
+	NIFTI_INTENT_RGBA_VECTOR int16 = 2004 // an RGBA color per element
+	NIFTI_INTENT_SHAPE       int16 = 2005 // a shape measure per element, e.g., cortical thickness
+)
+
+// NIfTI unit codes, used in the XyztUnits field of the NIfTI-1 and NIfTI-2 headers. The spatial and the temporal unit are combined with a bitwise or.
+const (
+	NIFTI_UNITS_UNKNOWN uint8 = 0  // unknown unit
+	NIFTI_UNITS_METER   uint8 = 1  // spatial unit meter
+	NIFTI_UNITS_MM      uint8 = 2  // spatial unit millimeter
+	NIFTI_UNITS_MICRON  uint8 = 3  // spatial unit micrometer
+	NIFTI_UNITS_SEC     uint8 = 8  // temporal unit second
+	NIFTI_UNITS_MSEC    uint8 = 16 // temporal unit millisecond
+	NIFTI_UNITS_USEC    uint8 = 24 // temporal unit microsecond
+)
+
+// UnsupportedNiftiDataTypeError is the error returned when NIfTI data has a data type code that this package cannot read or write.
+//
+// Use errors.As to check for it, see UnsupportedMghDataTypeError for an example.
+type UnsupportedNiftiDataTypeError struct {
+	Code int16 // The NIfTI data type code from the header.
+}
+
+// Error returns the error message, which includes the code.
+func (e *UnsupportedNiftiDataTypeError) Error() string {
+	return fmt.Sprintf("Invalid or unsupported NIfTI data type code %d. Supported are 2=uint8, 4=int16, 8=int32, 16=float32, 64=float64, 256=int8, 512=uint16, 768=uint32, 1024=int64, 1280=uint64.", e.Code)
+}
+
+// NiftiExtension is an extension block of a NIfTI-1 or NIfTI-2 file. Extensions are stored between the header and the voxel data.
+type NiftiExtension struct {
+	Code int32  // The extension code, which identifies the format of the data, e.g., 4 for AFNI or 32 for CIFTI.
+	Data []byte // The extension data, without the 8 bytes for size and code. It is padded with zeros when written, so that the size of the block is a multiple of 16.
+}
+
+// getNiftiExtensionsSize computes the number of bytes needed to store the extensions, including the 4 byte extender that precedes them.
+//
+// Parameters:
+//   - extensions: the extensions
+//
+// Returns:
+//   - int64: the number of bytes, a multiple of 16 plus 4
+func getNiftiExtensionsSize(extensions []NiftiExtension) int64 {
+	size := int64(4)
+	for _, ext := range extensions {
+		size += getNiftiExtensionBlockSize(ext)
+	}
+	return size
+}
+
+// getNiftiExtensionBlockSize computes the size of the block of an extension, i.e., the esize field: 8 bytes for size and code plus the data, rounded up to a multiple of 16.
+func getNiftiExtensionBlockSize(ext NiftiExtension) int64 {
+	return (int64(len(ext.Data)) + 8 + 15) / 16 * 16
+}
+
+// readNiftiExtensions reads the extender and the extensions that follow the header of a NIfTI file, and skips any remaining bytes before the voxel data.
+//
+// Parameters:
+//   - r: reader positioned at the first byte after the header
+//   - order: the byte order of the file
+//   - numBytes: the number of bytes between the header and the voxel data, i.e., the vox_offset minus the header size
+//
+// Returns:
+//   - []NiftiExtension: the extensions, nil if there are none
+//   - error: an error if one occurred, e.g., if an extension block has an invalid size
+func readNiftiExtensions(r io.Reader, order binary.ByteOrder, numBytes int64) ([]NiftiExtension, error) {
+	var extensions []NiftiExtension
+	if numBytes < 4 {
+		_, err := io.CopyN(io.Discard, r, numBytes)
+		return extensions, err
+	}
+
+	var extender [4]byte
+	if _, err := io.ReadFull(r, extender[:]); err != nil {
+		return extensions, fmt.Errorf("readNiftiExtensions: failed to read extender: %w", err)
+	}
+	remaining := numBytes - 4
+
+	for extender[0] != 0 && remaining >= 8 {
+		var blockHeader [2]int32 // esize and ecode
+		if err := binary.Read(r, order, &blockHeader); err != nil {
+			return extensions, fmt.Errorf("readNiftiExtensions: failed to read header of extension %d: %w", len(extensions), err)
+		}
+		esize := int64(blockHeader[0])
+		if esize < 8 || esize > remaining {
+			return extensions, fmt.Errorf("readNiftiExtensions: invalid size %d of extension %d, with %d bytes left before the data.", esize, len(extensions), remaining)
+		}
+		data := make([]byte, esize-8)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return extensions, fmt.Errorf("readNiftiExtensions: failed to read data of extension %d: %w", len(extensions), err)
+		}
+		extensions = append(extensions, NiftiExtension{Code: blockHeader[1], Data: data})
+		remaining -= esize
+	}
+
+	if _, err := io.CopyN(io.Discard, r, remaining); err != nil {
+		return extensions, fmt.Errorf("readNiftiExtensions: failed to skip to the data: %w", err)
+	}
+	return extensions, nil
+}
+
+// writeNiftiExtensions writes the extender and the extensions. The extender is always written, and flags whether extensions follow.
+//
+// Parameters:
+//   - w: the writer, positioned at the first byte after the header
+//   - order: the byte order
+//   - extensions: the extensions to write
+//
+// Returns:
+//   - error: an error if one occurred
+func writeNiftiExtensions(w io.Writer, order binary.ByteOrder, extensions []NiftiExtension) error {
+	var extender [4]byte
+	if len(extensions) > 0 {
+		extender[0] = 1
+	}
+	if _, err := w.Write(extender[:]); err != nil {
+		return err
+	}
+	for _, ext := range extensions {
+		esize := getNiftiExtensionBlockSize(ext)
+		blockHeader := [2]int32{int32(esize), ext.Code}
+		if err := binary.Write(w, order, &blockHeader); err != nil {
+			return err
+		}
+		if _, err := w.Write(ext.Data); err != nil {
+			return err
+		}
+		if _, err := w.Write(make([]byte, esize-8-int64(len(ext.Data)))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getNiftiDataTypeForMri returns the NIfTI data type code and the number of bits per value for an MRI data type, for writing a Volume to a NIfTI file.
+//
+// Parameters:
+//   - dtCode: the MRI data type code, e.g., MRI_FLOAT
+//
+// Returns:
+//   - int16: the NIfTI data type code, e.g., NIFTI_TYPE_FLOAT32
+//   - int16: the number of bits per value, e.g., 32
+//   - error: an *UnsupportedMghDataTypeError if the MRI data type is invalid or unsupported
+func getNiftiDataTypeForMri(dtCode int32) (int16, int16, error) {
+	switch dtCode {
+	case MRI_UCHAR:
+		return NIFTI_TYPE_UINT8, 8, nil
+	case MRI_SHORT:
+		return NIFTI_TYPE_INT16, 16, nil
+	case MRI_INT:
+		return NIFTI_TYPE_INT32, 32, nil
+	case MRI_FLOAT:
+		return NIFTI_TYPE_FLOAT32, 32, nil
+	case MRI_LONG:
+		return NIFTI_TYPE_INT64, 64, nil
+	case MRI_USHRT:
+		return NIFTI_TYPE_UINT16, 16, nil
+	default:
+		return 0, 0, &UnsupportedMghDataTypeError{Code: dtCode}
+	}
+}
+
+// writeNiftiData writes the values of a Volume in the given byte order, using the NIfTI data type returned by getNiftiDataTypeForMri.
+//
+// Parameters:
+//   - w: the writer, positioned at the vox_offset
+//   - order: the byte order
+//   - volume: the volume
+//
+// Returns:
+//   - error: an error if one occurred
+func writeNiftiData(w io.Writer, order binary.ByteOrder, volume Volume) error {
+	data := volume.Data()
+	var dataSlice interface{}
+	switch data.MghDataType {
+	case MRI_UCHAR:
+		dataSlice = data.DataMriUchar
+	case MRI_SHORT:
+		dataSlice = data.DataMriShort
+	case MRI_INT:
+		dataSlice = data.DataMriInt
+	case MRI_FLOAT:
+		dataSlice = data.DataMriFloat
+	case MRI_LONG:
+		dataSlice = data.DataMriLong
+	case MRI_USHRT:
+		dataSlice = data.DataMriUshort
+	default:
+		return &UnsupportedMghDataTypeError{Code: data.MghDataType}
+	}
+	return binary.Write(w, order, dataSlice)
+}
+
+// getNiftiDim computes the dim field of a NIfTI header for a volume shape.
+//
+// If the shape matches the dimensions in oldDim (see getNiftiVolumeShape), oldDim is returned unchanged, which preserves dimensions above 4, e.g., for CIFTI files.
+// Otherwise, the number of dimensions is 4 if the 4th dimension has a length above 1, and 3 otherwise.
+//
+// Parameters:
+//   - shape: the volume shape
+//   - oldDim: the dim field of the header the volume was read with, may be all zeros
+//
+// Returns:
+//   - [8]int64: the dim field
+func getNiftiDim(shape [4]int, oldDim [8]int64) [8]int64 {
+	if oldShape, err := getNiftiVolumeShape(oldDim); err == nil && oldShape == shape {
+		return oldDim
+	}
+	dim := [8]int64{3, int64(shape[0]), int64(shape[1]), int64(shape[2]), int64(shape[3]), 1, 1, 1}
+	if shape[3] > 1 {
+		dim[0] = 4
+	}
+	return dim
+}
+
+// getNiftiDataTypeInfo returns the size of a single value of a NIfTI data type, and the MRI data type used to represent it in an MghData struct.
+//
+// NIfTI data types without an MRI counterpart are stored in the smallest MRI data type that can hold all their values, with two exceptions:
+// NIFTI_TYPE_FLOAT64 is stored as MRI_FLOAT (like FreeSurfer does), and NIFTI_TYPE_UINT64 is stored as MRI_LONG, with values above the int64 range clipped.
+//
+// Parameters:
+//   - dtCode: The NIfTI data type code, e.g., NIFTI_TYPE_FLOAT32.
+//
+// Returns:
+//   - int: The size of one value in bytes, e.g., 4 for NIFTI_TYPE_FLOAT32.
+//   - int32: The MRI data type code, e.g., MRI_FLOAT.
+//   - error: An *UnsupportedNiftiDataTypeError if the data type code is invalid or unsupported.
+func getNiftiDataTypeInfo(dtCode int16) (int, int32, error) {
+	switch dtCode {
+	case NIFTI_TYPE_UINT8:
+		return 1, MRI_UCHAR, nil
+	case NIFTI_TYPE_INT16:
+		return 2, MRI_SHORT, nil
+	case NIFTI_TYPE_INT32:
+		return 4, MRI_INT, nil
+	case NIFTI_TYPE_FLOAT32:
+		return 4, MRI_FLOAT, nil
+	case NIFTI_TYPE_FLOAT64:
+		return 8, MRI_FLOAT, nil
+	case NIFTI_TYPE_INT8:
+		return 1, MRI_SHORT, nil
+	case NIFTI_TYPE_UINT16:
+		return 2, MRI_USHRT, nil
+	case NIFTI_TYPE_UINT32:
+		return 4, MRI_LONG, nil
+	case NIFTI_TYPE_INT64:
+		return 8, MRI_LONG, nil
+	case NIFTI_TYPE_UINT64:
+		return 8, MRI_LONG, nil
+	default:
+		return 0, -1, &UnsupportedNiftiDataTypeError{Code: dtCode}
+	}
+}
+
+// readNiftiData reads and decodes the voxel data of a NIfTI file from r.
+//
+// Parameters:
+//   - r: reader positioned at the first byte of the voxel data
+//   - order: the byte order of the file
+//   - dtCode: the NIfTI data type code from the header
+//   - shape: the shape of the volume
+//   - sclSlope, sclInter: the scaling from the header. If the slope is not 0 and the scaling is not the identity, the values are scaled and returned as MRI_FLOAT.
+//
+// Returns:
+//   - Volume: the volume
+//   - error: an error if one occurred, e.g., if the data type is unsupported or the data is too short
+func readNiftiData(r io.Reader, order binary.ByteOrder, dtCode int16, shape [4]int, sclSlope float32, sclInter float32) (Volume, error) {
+	valueSize, mriDtCode, err := getNiftiDataTypeInfo(dtCode)
+	if err != nil {
+		return Volume{}, err
+	}
+	numValues := int64(shape[0]) * int64(shape[1]) * int64(shape[2]) * int64(shape[3])
+
+	if Verbosity >= 1 {
+		fmt.Printf("Reading %d values of NIfTI data type %d.\n", numValues, dtCode)
+	}
+
+	// Do not trust the header dimensions for the allocation, read the data first so truncated files fail before the volume is allocated.
+	numBytes := numValues * int64(valueSize)
+	bs, err := io.ReadAll(io.LimitReader(r, numBytes))
+	if err == nil && int64(len(bs)) != numBytes {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return Volume{}, fmt.Errorf("readNiftiData: failed to read %d values of NIfTI data type %d: %w", numValues, dtCode, err)
+	}
+
+	volume, err := NewVolume(shape, mriDtCode)
+	if err != nil {
+		return volume, err
+	}
+
+	data := volume.Data()
+	switch dtCode {
+	case NIFTI_TYPE_UINT8:
+		copy(data.DataMriUchar, bs)
+	case NIFTI_TYPE_INT16:
+		for idx := range data.DataMriShort {
+			data.DataMriShort[idx] = int16(order.Uint16(bs[idx*2:]))
+		}
+	case NIFTI_TYPE_INT32:
+		for idx := range data.DataMriInt {
+			data.DataMriInt[idx] = int32(order.Uint32(bs[idx*4:]))
+		}
+	case NIFTI_TYPE_FLOAT32:
+		for idx := range data.DataMriFloat {
+			data.DataMriFloat[idx] = math.Float32frombits(order.Uint32(bs[idx*4:]))
+		}
+	case NIFTI_TYPE_FLOAT64:
+		for idx := range data.DataMriFloat {
+			data.DataMriFloat[idx] = float32(math.Float64frombits(order.Uint64(bs[idx*8:])))
+		}
+	case NIFTI_TYPE_INT8:
+		for idx := range data.DataMriShort {
+			data.DataMriShort[idx] = int16(int8(bs[idx]))
+		}
+	case NIFTI_TYPE_UINT16:
+		for idx := range data.DataMriUshort {
+			data.DataMriUshort[idx] = order.Uint16(bs[idx*2:])
+		}
+	case NIFTI_TYPE_UINT32:
+		for idx := range data.DataMriLong {
+			data.DataMriLong[idx] = int64(order.Uint32(bs[idx*4:]))
+		}
+	case NIFTI_TYPE_INT64:
+		for idx := range data.DataMriLong {
+			data.DataMriLong[idx] = int64(order.Uint64(bs[idx*8:]))
+		}
+	case NIFTI_TYPE_UINT64:
+		for idx := range data.DataMriLong {
+			val := order.Uint64(bs[idx*8:])
+			if val > math.MaxInt64 {
+				val = math.MaxInt64
+			}
+			data.DataMriLong[idx] = int64(val)
+		}
+	}
+
+	if sclSlope != 0 && !(sclSlope == 1 && sclInter == 0) {
+		scaled, err := NewVolume(shape, MRI_FLOAT)
+		if err != nil {
+			return volume, err
+		}
+		scaledData := scaled.Data().DataMriFloat
+		for idx := range scaledData {
+			scaledData[idx] = float32(float64(sclSlope)*volume.at(idx) + float64(sclInter))
+		}
+		return scaled, nil
+	}
+	return volume, nil
+}
+
+// getNiftiQformMatrix computes the qform affine matrix from the quaternion parameters of a NIfTI header (method 2 in the NIfTI standard).
+//
+// Parameters:
+//   - quatern: the quaternion parameters b, c and d
+//   - qoffset: the offsets x, y and z
+//   - pixdim: the pixdim field of the header. pixdim[0] is qfac, the sign of the third axis, and pixdim[1:4] are the voxel sizes.
+//
+// Returns:
+//   - [4][4]float64: the affine matrix that maps voxel indices to world coordinates
+func getNiftiQformMatrix(quatern [3]float64, qoffset [3]float64, pixdim [4]float64) [4][4]float64 {
+	b, c, d := quatern[0], quatern[1], quatern[2]
+	a := 1.0 - (b*b + c*c + d*d)
+	if a < 1.0e-7 {
+		// The quaternion is not normalized, which means a 180 degree rotation. Normalize b, c and d.
+		norm := 1.0 / math.Sqrt(b*b+c*c+d*d)
+		b, c, d = b*norm, c*norm, d*norm
+		a = 0.0
+	} else {
+		a = math.Sqrt(a)
+	}
+
+	qfac := 1.0
+	if pixdim[0] < 0 {
+		qfac = -1.0
+	}
+	dx, dy, dz := pixdim[1], pixdim[2], qfac*pixdim[3]
+
+	return [4][4]float64{
+		{(a*a + b*b - c*c - d*d) * dx, 2 * (b*c - a*d) * dy, 2 * (b*d + a*c) * dz, qoffset[0]},
+		{2 * (b*c + a*d) * dx, (a*a + c*c - b*b - d*d) * dy, 2 * (c*d - a*b) * dz, qoffset[1]},
+		{2 * (b*d - a*c) * dx, 2 * (c*d + a*b) * dy, (a*a + d*d - c*c - b*b) * dz, qoffset[2]},
+		{0, 0, 0, 1},
+	}
+}
+
+// getNiftiQuaternion computes the quaternion representation of the qform from an affine matrix (the inverse of getNiftiQformMatrix).
+//
+// The columns of the rotation part are normalized to get the voxel sizes. If the determinant is negative, the third column is negated
+// and qfac is -1. The rotation part must be orthogonal, shearing cannot be represented by a qform.
+//
+// Parameters:
+//   - m: the affine matrix that maps voxel indices to world coordinates
+//
+// Returns:
+//   - [3]float64: the quaternion parameters b, c and d
+//   - [3]float64: the offsets x, y and z
+//   - [4]float64: qfac and the voxel sizes, to be stored in pixdim[0:4]
+//   - error: an error if a column of the rotation part is zero
+func getNiftiQuaternion(m [4][4]float64) ([3]float64, [3]float64, [4]float64, error) {
+	var quatern [3]float64
+	qoffset := [3]float64{m[0][3], m[1][3], m[2][3]}
+	var pixdim [4]float64
+
+	var r [3][3]float64
+	for col := 0; col < 3; col++ {
+		norm := math.Sqrt(m[0][col]*m[0][col] + m[1][col]*m[1][col] + m[2][col]*m[2][col])
+		if norm == 0 {
+			return quatern, qoffset, pixdim, fmt.Errorf("column %d of the affine matrix is zero, cannot compute a qform.", col)
+		}
+		pixdim[col+1] = norm
+		for row := 0; row < 3; row++ {
+			r[row][col] = m[row][col] / norm
+		}
+	}
+
+	det := r[0][0]*(r[1][1]*r[2][2]-r[1][2]*r[2][1]) - r[0][1]*(r[1][0]*r[2][2]-r[1][2]*r[2][0]) + r[0][2]*(r[1][0]*r[2][1]-r[1][1]*r[2][0])
+	pixdim[0] = 1.0
+	if det < 0 {
+		pixdim[0] = -1.0
+		r[0][2], r[1][2], r[2][2] = -r[0][2], -r[1][2], -r[2][2]
+	}
+
+	// The algorithm of nifti_mat44_to_quatern from the NIfTI reference implementation.
+	var a, b, c, d float64
+	a = r[0][0] + r[1][1] + r[2][2] + 1.0
+	if a > 0.5 {
+		a = 0.5 * math.Sqrt(a)
+		b = 0.25 * (r[2][1] - r[1][2]) / a
+		c = 0.25 * (r[0][2] - r[2][0]) / a
+		d = 0.25 * (r[1][0] - r[0][1]) / a
+	} else {
+		xd := 1.0 + r[0][0] - (r[1][1] + r[2][2])
+		yd := 1.0 + r[1][1] - (r[0][0] + r[2][2])
+		zd := 1.0 + r[2][2] - (r[0][0] + r[1][1])
+		if xd > 1.0 {
+			b = 0.5 * math.Sqrt(xd)
+			c = 0.25 * (r[0][1] + r[1][0]) / b
+			d = 0.25 * (r[0][2] + r[2][0]) / b
+			a = 0.25 * (r[2][1] - r[1][2]) / b
+		} else if yd > 1.0 {
+			c = 0.5 * math.Sqrt(yd)
+			b = 0.25 * (r[0][1] + r[1][0]) / c
+			d = 0.25 * (r[1][2] + r[2][1]) / c
+			a = 0.25 * (r[0][2] - r[2][0]) / c
+		} else {
+			d = 0.5 * math.Sqrt(zd)
+			b = 0.25 * (r[0][2] + r[2][0]) / d
+			c = 0.25 * (r[1][2] + r[2][1]) / d
+			a = 0.25 * (r[1][0] - r[0][1]) / d
+		}
+		if a < 0.0 {
+			b, c, d = -b, -c, -d
+		}
+	}
+	quatern = [3]float64{b, c, d}
+	return quatern, qoffset, pixdim, nil
+}
+
+// maxNiftiNumValues is the maximal number of voxel values read from a NIfTI file. It is checked before the data is allocated,
+// so that invalid dimensions in a header cannot overflow the number of values. The limit fits into an int on all platforms.
+const maxNiftiNumValues = math.MaxInt32
+
+// getNiftiVolumeShape computes the 4D volume shape from the dim field of a NIfTI header.
+//
+// Dimensions 5 to 7 are merged into the 4th dimension, so the volume has Dim4Length dim[4]*dim[5]*dim[6]*dim[7].
+//
+// Parameters:
+//   - dim: the dim field of the header, dim[0] is the number of dimensions
+//
+// Returns:
+//   - [4]int: the shape
+//   - error: an error if the number of dimensions is not in range 1 to 7, a used dimension length is less than 1, or the number of values exceeds maxNiftiNumValues
+func getNiftiVolumeShape(dim [8]int64) ([4]int, error) {
+	shape := [4]int{1, 1, 1, 1}
+	numDims := dim[0]
+	if numDims < 1 || numDims > 7 {
+		return shape, fmt.Errorf("invalid number of dimensions %d in NIfTI header, must be in range 1 to 7.", numDims)
+	}
+	numValues := int64(1)
+	for d := int64(1); d <= numDims; d++ {
+		if dim[d] < 1 {
+			return shape, fmt.Errorf("invalid length %d of dimension %d in NIfTI header.", dim[d], d)
+		}
+		if dim[d] > maxNiftiNumValues/numValues {
+			return shape, fmt.Errorf("the dimensions %v in NIfTI header describe more than %d values.", dim[1:numDims+1], int64(maxNiftiNumValues))
+		}
+		numValues *= dim[d]
+		if d <= 4 {
+			shape[d-1] = int(dim[d])
+		} else {
+			shape[3] *= int(dim[d])
+		}
+	}
+	return shape, nil
+}
+
+// trimNiftiString converts a fixed-size, zero-padded string field of a NIfTI header to a string.
+func trimNiftiString(bs []byte) string {
+	for idx, b := range bs {
+		if b == 0 {
+			return string(bs[:idx])
+		}
+	}
+	return string(bs)
+}
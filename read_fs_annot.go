@@ -0,0 +1,354 @@
+package neuro
+
+// Related software: libfs for C++, see:
+// https://github.com/dfsp-spirit/libfs/blob/main/include/libfs.h for the fs annot file format
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// FsColortable models a FreeSurfer colortable, which assigns names and colors to brain structures or regions.
+//
+// The colortable is stored as a struct of slices, one entry per structure. All slices have the same length.
+type FsColortable struct {
+	StructureId []int32  // The ID of the structure. For annotations, this is typically the index of the entry.
+	Name        []string // The name of the structure, e.g., 'precentral'.
+	R           []int32  // The red channel of the color, in range 0 to 255.
+	G           []int32  // The green channel of the color, in range 0 to 255.
+	B           []int32  // The blue channel of the color, in range 0 to 255.
+	A           []int32  // The fourth color channel, in range 0 to 255. FreeSurfer treats it as transparency, i.e., 0 means opaque, and it is typically 0.
+	Filename    string   // The name of the file the colortable was created from, e.g., the path to 'FreeSurferColorLUT.txt'. May be empty.
+}
+
+// FsAnnot models a FreeSurfer annotation, i.e., a parcellation of a brain surface into regions, like the Desikan atlas in '<subject>/label/lh.aparc.annot'.
+//
+// Each vertex is assigned a label code, which identifies its region in the colortable, see FsColortable.LabelCodes.
+type FsAnnot struct {
+	VertexLabels []int32      // The label code of each vertex. Vertices which were not listed in the file have label code 0.
+	Colortable   FsColortable // The colortable with the names and colors of the regions.
+}
+
+// NumEntries returns the number of entries (structures) in the colortable.
+func (ct FsColortable) NumEntries() int {
+	return len(ct.Name)
+}
+
+// LabelCodes computes the annotation label code of each colortable entry from its color.
+//
+// The label code used in annotations is R + G * 2^8 + B * 2^16, so each region of an annotation must have a unique color.
+//
+// Returns:
+//   - []int32: the label code of each entry
+func (ct FsColortable) LabelCodes() []int32 {
+	codes := make([]int32, ct.NumEntries())
+	for idx := range codes {
+		codes[idx] = getFsAnnotLabelCode(ct.R[idx], ct.G[idx], ct.B[idx])
+	}
+	return codes
+}
+
+// getFsAnnotLabelCode computes the annotation label code for a color.
+func getFsAnnotLabelCode(r int32, g int32, b int32) int32 {
+	return r + g*256 + b*65536
+}
+
+// VertexRegionIndices computes the index of the colortable entry for each vertex.
+//
+// Returns:
+//   - []int: the index into the colortable for each vertex, or -1 if the label code of the vertex is not in the colortable
+func (annot FsAnnot) VertexRegionIndices() []int {
+	codeToIndex := make(map[int32]int, annot.Colortable.NumEntries())
+	for idx, code := range annot.Colortable.LabelCodes() {
+		if _, ok := codeToIndex[code]; !ok {
+			codeToIndex[code] = idx
+		}
+	}
+	indices := make([]int, len(annot.VertexLabels))
+	for vertexIndex, code := range annot.VertexLabels {
+		idx, ok := codeToIndex[code]
+		if !ok {
+			idx = -1
+		}
+		indices[vertexIndex] = idx
+	}
+	return indices
+}
+
+// VertexRegionNames computes the name of the region for each vertex.
+//
+// Returns:
+//   - []string: the region name of each vertex, or the empty string if the label code of the vertex is not in the colortable
+func (annot FsAnnot) VertexRegionNames() []string {
+	names := make([]string, len(annot.VertexLabels))
+	for vertexIndex, idx := range annot.VertexRegionIndices() {
+		if idx >= 0 {
+			names[vertexIndex] = annot.Colortable.Name[idx]
+		}
+	}
+	return names
+}
+
+// RegionLabel returns the vertices of a region as a label, like the labels read with ReadFsLabel.
+//
+// The label contains the indices of all vertices of the region. The coordinates and the values of the label are 0.
+//
+// Parameters:
+//   - regionName: the name of the region in the colortable, e.g., 'precentral'
+//
+// Returns:
+//   - FsLabel: the label, which is empty if no vertex belongs to the region
+//   - error: an error if the colortable contains no region with that name
+func (annot FsAnnot) RegionLabel(regionName string) (FsLabel, error) {
+	regionIndex := -1
+	for idx, name := range annot.Colortable.Name {
+		if name == regionName {
+			regionIndex = idx
+			break
+		}
+	}
+	if regionIndex < 0 {
+		return FsLabel{}, fmt.Errorf("RegionLabel: the colortable contains no region named '%s'.", regionName)
+	}
+
+	var vertices []int32
+	for vertexIndex, idx := range annot.VertexRegionIndices() {
+		if idx == regionIndex {
+			vertices = append(vertices, int32(vertexIndex))
+		}
+	}
+	return newFsLabelForElements(vertices), nil
+}
+
+// RegionLabels returns the vertices of each region as a label, see RegionLabel.
+//
+// Returns:
+//   - map[string]FsLabel: the label of each region in the colortable, by region name. Regions without vertices have an empty label.
+func (annot FsAnnot) RegionLabels() map[string]FsLabel {
+	vertices := make([][]int32, annot.Colortable.NumEntries())
+	for vertexIndex, idx := range annot.VertexRegionIndices() {
+		if idx >= 0 {
+			vertices[idx] = append(vertices[idx], int32(vertexIndex))
+		}
+	}
+	labels := make(map[string]FsLabel, len(vertices))
+	for idx, name := range annot.Colortable.Name {
+		if _, ok := labels[name]; !ok {
+			labels[name] = newFsLabelForElements(vertices[idx])
+		}
+	}
+	return labels
+}
+
+// newFsLabelForElements creates a label for the given element indices, with all coordinates and values 0.
+func newFsLabelForElements(elements []int32) FsLabel {
+	n := len(elements)
+	return FsLabel{
+		ElementIndex: append(make([]int32, 0, n), elements...),
+		CoordX:       make([]float32, n),
+		CoordY:       make([]float32, n),
+		CoordZ:       make([]float32, n),
+		Value:        make([]float32, n),
+	}
+}
+
+// readFsAnnotString reads a string that is preceded by its length as an int32, like the names in an annotation colortable. A trailing zero byte is removed.
+func readFsAnnotString(r io.Reader) (string, error) {
+	var length int32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	if length < 0 || length > 1<<20 {
+		return "", fmt.Errorf("invalid string length %d.", length)
+	}
+	bs := make([]byte, length)
+	if _, err := io.ReadFull(r, bs); err != nil {
+		return "", err
+	}
+	for len(bs) > 0 && bs[len(bs)-1] == 0 {
+		bs = bs[:len(bs)-1]
+	}
+	return string(bs), nil
+}
+
+// readFsAnnotColortable reads the colortable of an annotation file, in the old format or in the new format (version 2).
+//
+// Parameters:
+//   - r: the reader, positioned after the flag that indicates whether the file contains a colortable
+//
+// Returns:
+//   - FsColortable: the colortable
+//   - error: an error if one occurred
+func readFsAnnotColortable(r io.Reader) (FsColortable, error) {
+	var ct FsColortable
+	endian := binary.BigEndian
+
+	var numEntriesOrVersion int32
+	if err := binary.Read(r, endian, &numEntriesOrVersion); err != nil {
+		return ct, fmt.Errorf("failed to read colortable format: %w", err)
+	}
+
+	isOldFormat := numEntriesOrVersion > 0
+	if !isOldFormat && numEntriesOrVersion != -2 {
+		return ct, fmt.Errorf("unsupported colortable version %d, only version 2 and the old format are supported.", -numEntriesOrVersion)
+	}
+
+	var numEntries int32 = numEntriesOrVersion
+	if !isOldFormat {
+		// The number of entries is the maximal structure ID plus one, the number of entries stored in the file follows the file name.
+		if err := binary.Read(r, endian, &numEntries); err != nil {
+			return ct, fmt.Errorf("failed to read number of colortable entries: %w", err)
+		}
+	}
+
+	filename, err := readFsAnnotString(r)
+	if err != nil {
+		return ct, fmt.Errorf("failed to read colortable file name: %w", err)
+	}
+	ct.Filename = filename
+
+	if !isOldFormat {
+		if err := binary.Read(r, endian, &numEntries); err != nil {
+			return ct, fmt.Errorf("failed to read number of colortable entries to read: %w", err)
+		}
+	}
+	if numEntries < 0 || numEntries > 1<<20 {
+		return ct, fmt.Errorf("invalid number of colortable entries %d.", numEntries)
+	}
+
+	for idx := int32(0); idx < numEntries; idx++ {
+		structureId := idx
+		if !isOldFormat {
+			if err := binary.Read(r, endian, &structureId); err != nil {
+				return ct, fmt.Errorf("failed to read structure ID of colortable entry %d: %w", idx, err)
+			}
+		}
+		name, err := readFsAnnotString(r)
+		if err != nil {
+			return ct, fmt.Errorf("failed to read name of colortable entry %d: %w", idx, err)
+		}
+		var rgba [4]int32
+		if err := binary.Read(r, endian, &rgba); err != nil {
+			return ct, fmt.Errorf("failed to read color of colortable entry %d: %w", idx, err)
+		}
+		ct.StructureId = append(ct.StructureId, structureId)
+		ct.Name = append(ct.Name, name)
+		ct.R = append(ct.R, rgba[0])
+		ct.G = append(ct.G, rgba[1])
+		ct.B = append(ct.B, rgba[2])
+		ct.A = append(ct.A, rgba[3])
+	}
+	return ct, nil
+}
+
+// ReadFsAnnot reads a file in FreeSurfer annotation format, e.g., '<subject>/label/lh.aparc.annot'.
+//
+// An annotation is a parcellation of a brain surface into regions. It contains a label code for each vertex, and a colortable
+// with the names and colors of the regions. Both the old colortable format and the new format (version 2) are supported.
+//
+// Parameters:
+//   - filepath: path to the annotation file
+//
+// Returns:
+//   - FsAnnot: the per-vertex label codes and the colortable
+//   - error: an error if one occurred
+func ReadFsAnnot(filepath string) (FsAnnot, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return FsAnnot{}, fmt.Errorf("ReadFsAnnot: could not open annotation file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	annot, err := ReadFsAnnotFrom(file)
+	if err != nil {
+		return annot, fmt.Errorf("ReadFsAnnot: failed to read annotation file '%s': %w", filepath, err)
+	}
+	return annot, nil
+}
+
+// ReadFsAnnotFS reads a file in FreeSurfer annotation format from the file system fsys, see ReadFsAnnot.
+//
+// Parameters:
+//   - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//   - name: the name of the annotation file in fsys, e.g. '<subject>/label/lh.aparc.annot'
+//
+// Returns:
+//   - FsAnnot: the per-vertex label codes and the colortable
+//   - error: an error if one occurred
+func ReadFsAnnotFS(fsys fs.FS, name string) (FsAnnot, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return FsAnnot{}, fmt.Errorf("ReadFsAnnotFS: could not open annotation file '%s': %w", name, err)
+	}
+	defer file.Close()
+
+	annot, err := ReadFsAnnotFrom(file)
+	if err != nil {
+		return annot, fmt.Errorf("ReadFsAnnotFS: failed to read annotation file '%s': %w", name, err)
+	}
+	return annot, nil
+}
+
+// ReadFsAnnotFrom reads data in FreeSurfer annotation format from r, see ReadFsAnnot.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File or a *bytes.Reader
+//
+// Returns:
+//   - FsAnnot: the per-vertex label codes and the colortable
+//   - error: an error if one occurred
+func ReadFsAnnotFrom(r io.Reader) (FsAnnot, error) {
+	var annot FsAnnot
+	endian := binary.BigEndian
+	r = bufio.NewReader(r)
+
+	var numVertices int32
+	if err := binary.Read(r, endian, &numVertices); err != nil {
+		return annot, fmt.Errorf("ReadFsAnnotFrom: failed to read number of vertices: %w", err)
+	}
+	if numVertices < 0 {
+		return annot, fmt.Errorf("ReadFsAnnotFrom: invalid number of vertices %d.", numVertices)
+	}
+
+	// The vertex data is stored as pairs of vertex index and label code. Do not trust the vertex count for the allocation,
+	// it may be garbage in broken files, so the pairs are read one by one before the labels are allocated.
+	var vertexData []int32
+	for idx := int32(0); idx < numVertices; idx++ {
+		var pair [2]int32
+		if err := binary.Read(r, endian, &pair); err != nil {
+			return annot, fmt.Errorf("ReadFsAnnotFrom: failed to read label of vertex %d: %w", idx, err)
+		}
+		if pair[0] < 0 || pair[0] >= numVertices {
+			return annot, fmt.Errorf("ReadFsAnnotFrom: invalid vertex index %d, the annotation has %d vertices.", pair[0], numVertices)
+		}
+		vertexData = append(vertexData, pair[0], pair[1])
+	}
+	annot.VertexLabels = make([]int32, numVertices)
+	for idx := 0; idx < len(vertexData); idx += 2 {
+		annot.VertexLabels[vertexData[idx]] = vertexData[idx+1]
+	}
+
+	var hasColortable int32
+	if err := binary.Read(r, endian, &hasColortable); err != nil {
+		if err == io.EOF {
+			return annot, nil // Some old files end without the flag.
+		}
+		return annot, fmt.Errorf("ReadFsAnnotFrom: failed to read colortable flag: %w", err)
+	}
+	if hasColortable == 1 {
+		ct, err := readFsAnnotColortable(r)
+		if err != nil {
+			return annot, fmt.Errorf("ReadFsAnnotFrom: %w", err)
+		}
+		annot.Colortable = ct
+	}
+
+	if Verbosity > 0 {
+		fmt.Printf("ReadFsAnnotFrom: annotation for %d vertices with %d colortable entries.\n", numVertices, annot.Colortable.NumEntries())
+	}
+	return annot, nil
+}
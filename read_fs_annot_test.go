@@ -0,0 +1,150 @@
+package neuro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// getTestFsColortable returns a colortable with 3 regions. The label codes are 0, 1 + 2*256 + 3*65536 = 197121 and 20 + 30*256 + 40*65536 = 2629140.
+func getTestFsColortable() FsColortable {
+	return FsColortable{
+		StructureId: []int32{0, 1, 2},
+		Name:        []string{"unknown", "bankssts", "precentral"},
+		R:           []int32{0, 1, 20},
+		G:           []int32{0, 2, 30},
+		B:           []int32{0, 3, 40},
+		A:           []int32{0, 0, 0},
+		Filename:    "/opt/freesurfer/FreeSurferColorLUT.txt",
+	}
+}
+
+func TestReadFsAnnotBothFormats(t *testing.T) {
+	annot := FsAnnot{VertexLabels: []int32{0, 197121, 197121, 2629140, 12345}, Colortable: getTestFsColortable()}
+
+	for _, format := range []string{"old", "new"} {
+		var buf bytes.Buffer
+		if err := WriteFsAnnotTo(&buf, annot, format); err != nil {
+			t.Fatalf("%s: WriteFsAnnotTo failed: %v", format, err)
+		}
+		// Swap the entries of the first and the last vertex, the reader must use the vertex indices, not the order.
+		data := buf.Bytes()
+		first, last := append([]byte{}, data[4:12]...), append([]byte{}, data[36:44]...)
+		copy(data[4:12], last)
+		copy(data[36:44], first)
+
+		reread, err := ReadFsAnnotFrom(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: ReadFsAnnotFrom failed: %v", format, err)
+		}
+		if diff := cmp.Diff(annot, reread); diff != "" {
+			t.Errorf("%s: %s", format, diff)
+		}
+	}
+}
+
+func TestFsAnnotRegions(t *testing.T) {
+	annot := FsAnnot{VertexLabels: []int32{0, 197121, 197121, 2629140, 12345}, Colortable: getTestFsColortable()}
+
+	if diff := cmp.Diff([]int32{0, 197121, 2629140}, annot.Colortable.LabelCodes()); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]int{0, 1, 1, 2, -1}, annot.VertexRegionIndices()); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]string{"unknown", "bankssts", "bankssts", "precentral", ""}, annot.VertexRegionNames()); diff != "" {
+		t.Error(diff)
+	}
+
+	label, err := annot.RegionLabel("bankssts")
+	if err != nil {
+		t.Fatalf("RegionLabel failed: %v", err)
+	}
+	if diff := cmp.Diff([]int32{1, 2}, label.ElementIndex); diff != "" {
+		t.Error(diff)
+	}
+	if len(label.CoordX) != 2 || len(label.Value) != 2 {
+		t.Errorf("got %d coordinates and %d values, wanted 2 each", len(label.CoordX), len(label.Value))
+	}
+	if _, err := annot.RegionLabel("no such region"); err == nil {
+		t.Errorf("expected error for unknown region, got nil")
+	}
+
+	labels := annot.RegionLabels()
+	if len(labels) != 3 {
+		t.Errorf("got %d region labels, wanted 3", len(labels))
+	}
+	if diff := cmp.Diff([]int32{3}, labels["precentral"].ElementIndex); diff != "" {
+		t.Error(diff)
+	}
+
+	// The label can be used like the labels from ReadFsLabel.
+	isPart, err := VertexIsPartOfLabel(labels["bankssts"], 5)
+	if err != nil {
+		t.Fatalf("VertexIsPartOfLabel failed: %v", err)
+	}
+	if diff := cmp.Diff([]bool{false, true, true, false, false}, isPart); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReadFsAnnotFileAndFS(t *testing.T) {
+	vertexLabels := []int32{197121, 0}
+	dir := t.TempDir()
+	if err := WriteFsAnnot(filepath.Join(dir, "lh.test.annot"), FsAnnot{VertexLabels: vertexLabels, Colortable: getTestFsColortable()}, "new"); err != nil {
+		t.Fatalf("WriteFsAnnot failed: %v", err)
+	}
+
+	annot, err := ReadFsAnnot(filepath.Join(dir, "lh.test.annot"))
+	if err != nil {
+		t.Fatalf("ReadFsAnnot failed: %v", err)
+	}
+	if diff := cmp.Diff(vertexLabels, annot.VertexLabels); diff != "" {
+		t.Error(diff)
+	}
+
+	annot, err = ReadFsAnnotFS(os.DirFS(dir), "lh.test.annot")
+	if err != nil {
+		t.Fatalf("ReadFsAnnotFS failed: %v", err)
+	}
+	if annot.Colortable.NumEntries() != 3 {
+		t.Errorf("got %d colortable entries, wanted 3", annot.Colortable.NumEntries())
+	}
+
+	if _, err := ReadFsAnnot(filepath.Join(dir, "no_such_file.annot")); err == nil {
+		t.Errorf("expected error for missing file, got nil")
+	}
+}
+
+func TestReadFsAnnotInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFsAnnotTo(&buf, FsAnnot{VertexLabels: []int32{0, 197121}, Colortable: getTestFsColortable()}, "new"); err != nil {
+		t.Fatalf("WriteFsAnnotTo failed: %v", err)
+	}
+	valid := buf.Bytes()
+
+	invalidVertex := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(invalidVertex[4:], 7) // vertex index 7 of 2
+	unsupportedVersion := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(unsupportedVersion[24:], uint32(0xFFFFFFFF)) // version 1
+	hugeCount := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(hugeCount[0:], math.MaxInt32) // must not be allocated before the labels are read
+
+	invalid := map[string][]byte{
+		"empty":               {},
+		"truncated":           valid[:len(valid)-5],
+		"invalid vertex":      invalidVertex,
+		"unsupported version": unsupportedVersion,
+		"huge vertex count":   hugeCount,
+	}
+	for name, data := range invalid {
+		if _, err := ReadFsAnnotFrom(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
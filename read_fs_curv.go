@@ -2,16 +2,17 @@ package neuro
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 )
 
 // Read a binary file in FreeSurfer curv format.
 //
 // Curv files are used to store per-vertex descriptors like cortical thickness in native space (i.e., for a single subject, not mapped to a group template).
+// Files in the old curv format without magic bytes, which stores the values as int16 scaled by 100, are detected and read as well.
 //
 // Parameters:
 //  - filepath: the path to the file, must be a FreeSurfer curv file from recon-all output, like subject/surf/lh.thickness.
@@ -20,40 +21,58 @@ import (
 //  - pervertex_data: float32 array of per-vertex descriptor values (e.g. cortical thickness)
 //  - error: an error if one occurred
 func ReadFsCurv(filepath string) ([]float32, error) {
-	//b := []byte{0x18, 0x2d, 0x44, 0x54, 0xfb, 0x21, 0x09, 0x40, 0xff, 0x01, 0x02, 0x03, 0xbe, 0xef}
-	//r := bytes.NewReader(b)
-
-	endian := binary.BigEndian
-	pervertex_data := []float32{}
 
 	if _, err := os.Stat(filepath); err != nil {
 		fmt.Printf("Could not stat file '%s'\n.", filepath)
-		return pervertex_data, err
+		return []float32{}, err
 	}
 
 	file, err := os.Open(filepath)
 	if err != nil {
-   		panic(err)
+		err := fmt.Errorf("ReadFsCurv: could not open curv file '%s': %s", filepath, err)
+		return []float32{}, err
 	}
 	defer file.Close()
 
-	// Get the file size
-	stat, err := file.Stat()
+	return ReadFsCurvFrom(file)
+}
+
+// ReadFsCurvFS reads a binary file in FreeSurfer curv format from the file system fsys.
+//
+// This allows reading curv files from zip archives (see archive/zip), embedded files (see embed.FS) and other fs.FS implementations.
+//
+// Parameters:
+//  - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//  - name: the name of the curv file in fsys, e.g. '<subject>/surf/lh.thickness'
+//
+// Returns:
+//  - pervertex_data: float32 array of per-vertex descriptor values (e.g. cortical thickness)
+//  - error: an error if one occurred
+func ReadFsCurvFS(fsys fs.FS, name string) ([]float32, error) {
+	file, err := fsys.Open(name)
 	if err != nil {
-	   fmt.Println(err)
-	   return pervertex_data, err
+		err := fmt.Errorf("ReadFsCurvFS: could not open curv file '%s': %s", name, err)
+		return []float32{}, err
 	}
+	defer file.Close()
 
-	// Read the file into a byte slice
-	bs := make([]byte, stat.Size())
-	_, err = bufio.NewReader(file).Read(bs)
-	if err != nil && err != io.EOF {
-	   fmt.Println(err)
-	   return pervertex_data, err
-	}
+	return ReadFsCurvFrom(file)
+}
+
+// ReadFsCurvFrom reads per-vertex data in FreeSurfer curv format from r.
+//
+// Parameters:
+//  - r: the reader, e.g., an *os.File, a *bytes.Reader or an http.Response body
+//
+// Returns:
+//  - pervertex_data: float32 array of per-vertex descriptor values (e.g. cortical thickness)
+//  - error: an error if one occurred
+func ReadFsCurvFrom(r io.Reader) ([]float32, error) {
+
+	endian := binary.BigEndian
+	pervertex_data := []float32{}
 
-	// Read the byte slice
-	r := bytes.NewReader(bs)
+	r = bufio.NewReader(r)
 
 	type curvHeaderPart1 struct {
 		MagicB1 uint8
@@ -63,7 +82,6 @@ func ReadFsCurv(filepath string) ([]float32, error) {
 
 	hdr1 := curvHeaderPart1{}
 
-
 	if err := binary.Read(r, endian, &hdr1); err != nil {
 		fmt.Println("ReadFsCurv: binary.Read failed on curv header part 1:", err)
 		return pervertex_data, err
@@ -73,9 +91,13 @@ func ReadFsCurv(filepath string) ([]float32, error) {
 		fmt.Printf("ReadFsCurv: Curv header magic bytes: %d %d %d.\n", hdr1.MagicB1, hdr1.MagicB2, hdr1.MagicB3)
 	}
 
-
-	if ! (hdr1.MagicB1 == 255 && hdr1.MagicB2 == 255 && hdr1.MagicB3 == 255) {
-		fmt.Println("ReadFsCurv: Error: Curv magic bytes are not 255 255 255, this is not a FreeSurfer curv file. Provide a recon-all output file like '<subject>/surf/lh.thickness'.")
+	if !(hdr1.MagicB1 == 255 && hdr1.MagicB2 == 255 && hdr1.MagicB3 == 255) {
+		// Files in the old curv format have no magic bytes, they start with the number of vertices.
+		numVertices := int32(hdr1.MagicB1)<<16 | int32(hdr1.MagicB2)<<8 | int32(hdr1.MagicB3)
+		pervertex_data, err := readFsCurvOld(r, numVertices)
+		if err != nil {
+			err = fmt.Errorf("ReadFsCurv: Curv magic bytes are %d %d %d instead of 255 255 255, and the data is not in the old curv format either, this is not a FreeSurfer curv file. Provide a recon-all output file like '<subject>/surf/lh.thickness'. Reading as old curv format failed: %w", hdr1.MagicB1, hdr1.MagicB2, hdr1.MagicB3, err)
+		}
 		return pervertex_data, err
 	}
 
@@ -87,7 +109,6 @@ func ReadFsCurv(filepath string) ([]float32, error) {
 
 	hdr2 := curvHeaderPart2{}
 
-
 	if err := binary.Read(r, endian, &hdr2); err != nil {
 		fmt.Println("ReadFsCurv: binary.Read failed on curv header part 2:", err)
 		return pervertex_data, err
@@ -109,3 +130,47 @@ func ReadFsCurv(filepath string) ([]float32, error) {
 	return pervertex_data, nil
 }
 
+// readFsCurvOld reads per-vertex data in the old FreeSurfer curv format, which has no magic bytes, from r.
+//
+// The old format starts with the number of vertices and the number of faces as 3-byte integers, followed by one int16 value per vertex, scaled by 100.
+//
+// Parameters:
+//  - r: the reader, positioned after the number of vertices
+//  - numVertices: the number of vertices, from the first 3 bytes of the file
+//
+// Returns:
+//  - pervertex_data: float32 array of per-vertex descriptor values
+//  - error: an error if one occurred
+func readFsCurvOld(r io.Reader, numVertices int32) ([]float32, error) {
+	numFaces, err := readInt3(r)
+	if err != nil {
+		return []float32{}, fmt.Errorf("failed to read number of faces: %w", err)
+	}
+
+	if Verbosity > 0 {
+		fmt.Println("ReadFsCurv: Old curv format, NumVertices:", numVertices)
+		fmt.Println("ReadFsCurv: Old curv format, NumFaces:", numFaces)
+	}
+
+	// Without magic bytes, any file could be mistaken for an old curv file, e.g., MGH files start with 0 0 0.
+	if numVertices <= 0 || numFaces <= 0 {
+		return []float32{}, fmt.Errorf("implausible number of vertices %d or faces %d", numVertices, numFaces)
+	}
+
+	values := make([]int16, numVertices)
+	if err := binary.Read(r, binary.BigEndian, &values); err != nil {
+		return []float32{}, fmt.Errorf("failed to read %d per-vertex values: %w", numVertices, err)
+	}
+
+	// The old format has no footer, so the file must end right after the values.
+	var extra [1]byte
+	if n, _ := io.ReadFull(r, extra[:]); n != 0 {
+		return []float32{}, fmt.Errorf("unexpected data after %d per-vertex values", numVertices)
+	}
+
+	pervertex_data := make([]float32, numVertices)
+	for idx, v := range values {
+		pervertex_data[idx] = float32(v) / 100.0
+	}
+	return pervertex_data, nil
+}
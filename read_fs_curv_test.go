@@ -3,8 +3,13 @@ package neuro
 // https://pkg.go.dev/testing
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"os"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestReadFsCurv(t *testing.T){
@@ -47,3 +52,79 @@ func ExampleReadFsCurv() {
 	fmt.Printf("Read %d values from curv file '%s'.\n", len(pvdata), curvFile)
 	// Output: Read 149244 values from curv file 'testdata/lh.thickness'.
 }
+
+func TestReadFsCurvFrom(t *testing.T) {
+	file, err := os.Open("testdata/lh.thickness")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+
+	pvdata, err := ReadFsCurvFrom(file)
+	if err != nil {
+		t.Errorf("ReadFsCurvFrom failed: %v", err)
+	}
+
+	got := len(pvdata)
+	want := 149244
+	if got != want {
+		t.Errorf("got data for %d vertices from curv reader, wanted %d", got, want)
+	}
+}
+
+func TestReadFsCurvFromInvalidMagic(t *testing.T) {
+	_, err := ReadFsCurvFrom(bytes.NewReader([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}))
+	if err == nil {
+		t.Errorf("expected error for invalid curv magic bytes, got nil")
+	}
+}
+
+// getFsCurvOldBytes encodes values in the old curv format: 3-byte vertex and face counts, followed by int16 values scaled by 100.
+func getFsCurvOldBytes(values []int16, numFaces int) []byte {
+	var buf bytes.Buffer
+	n := len(values)
+	buf.Write([]byte{byte(n >> 16), byte(n >> 8), byte(n), byte(numFaces >> 16), byte(numFaces >> 8), byte(numFaces)})
+	binary.Write(&buf, binary.BigEndian, values)
+	return buf.Bytes()
+}
+
+func TestReadFsCurvFromOldFormat(t *testing.T) {
+	pvdata, err := ReadFsCurvFrom(bytes.NewReader(getFsCurvOldBytes([]int16{256, -125, 0, 32767}, 4)))
+	if err != nil {
+		t.Fatalf("ReadFsCurvFrom failed for old curv format: %v", err)
+	}
+	if diff := cmp.Diff([]float32{2.56, -1.25, 0.0, 327.67}, pvdata); diff != "" {
+		t.Error(diff)
+	}
+
+	// A file with more than 65535 vertices uses all 3 bytes of the vertex count.
+	values := make([]int16, 70000)
+	values[69999] = 42
+	pvdata, err = ReadFsCurvFrom(bytes.NewReader(getFsCurvOldBytes(values, 139996)))
+	if err != nil {
+		t.Fatalf("ReadFsCurvFrom failed for old curv format with many vertices: %v", err)
+	}
+	if len(pvdata) != 70000 || pvdata[69999] != 0.42 {
+		t.Errorf("got %d values with last value %f, wanted 70000 values with last value 0.42", len(pvdata), pvdata[len(pvdata)-1])
+	}
+}
+
+func TestReadFsCurvFromOldFormatTruncated(t *testing.T) {
+	data := getFsCurvOldBytes([]int16{256, -125, 0}, 2)
+	if _, err := ReadFsCurvFrom(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Errorf("expected error for truncated old curv data, got nil")
+	}
+}
+
+func TestReadFsCurvRejectsMgh(t *testing.T) {
+	if _, err := ReadFsCurv("testdata/lh.thickness.fwhm5.fsaverage.mgh"); err == nil {
+		t.Errorf("expected error when reading an MGH file as curv file, got nil")
+	}
+}
+
+func TestReadFsCurvFromOldFormatTrailingData(t *testing.T) {
+	data := append(getFsCurvOldBytes([]int16{256, -125, 0}, 2), 0, 0)
+	if _, err := ReadFsCurvFrom(bytes.NewReader(data)); err == nil {
+		t.Errorf("expected error for old curv data followed by extra bytes, got nil")
+	}
+}
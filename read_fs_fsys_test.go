@@ -0,0 +1,122 @@
+package neuro
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// getZippedSubject creates an in-memory zip archive that mimics a zipped FreeSurfer subject directory.
+func getZippedSubject(t *testing.T) *zip.Reader {
+	t.Helper()
+
+	thickness, err := os.ReadFile("testdata/lh.thickness")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	brain, err := os.ReadFile("testdata/brain.mgz")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	files := map[string][]byte{
+		"subject1/surf/lh.thickness":     thickness,
+		"subject1/surf/lh.white":         getFsSurfaceBytes(GenerateCube()),
+		"subject1/mri/brain.mgz":         brain,
+		"subject1/label/lh.region.label": []byte("#!ascii label  , from subject subject1 vox2ras=TkReg\n2\n5  -1.5  2.0  3.25 0.0000000000\n17  4.0  5.0  6.0 1.5000000000\n"),
+	}
+
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatalf("zip Create failed: %v", err)
+		}
+		w.Write(data)
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatalf("zip Close failed: %v", err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip NewReader failed: %v", err)
+	}
+	return zipReader
+}
+
+func TestReadFromZippedSubject(t *testing.T) {
+	fsys := getZippedSubject(t)
+
+	pvdata, err := ReadFsCurvFS(fsys, "subject1/surf/lh.thickness")
+	if err != nil {
+		t.Errorf("ReadFsCurvFS failed: %v", err)
+	}
+	if len(pvdata) != 149244 {
+		t.Errorf("got data for %d vertices in curv file, wanted %d", len(pvdata), 149244)
+	}
+
+	mesh, err := ReadFsSurfaceFS(fsys, "subject1/surf/lh.white")
+	if err != nil {
+		t.Errorf("ReadFsSurfaceFS failed: %v", err)
+	}
+	if diff := cmp.Diff(GenerateCube(), mesh); diff != "" {
+		t.Error(diff)
+	}
+
+	label, err := ReadFsLabelFS(fsys, "subject1/label/lh.region.label")
+	if err != nil {
+		t.Errorf("ReadFsLabelFS failed: %v", err)
+	}
+	if diff := cmp.Diff([]int32{5, 17}, label.ElementIndex); diff != "" {
+		t.Error(diff)
+	}
+
+	hdr, err := ReadFsMghHeaderFS(fsys, "subject1/mri/brain.mgz", "auto")
+	if err != nil {
+		t.Errorf("ReadFsMghHeaderFS failed: %v", err)
+	}
+	if hdr.Dim1Length != 256 || hdr.RasGoodFlag != 1 {
+		t.Errorf("got MGH header with Dim1Length=%d and RasGoodFlag=%d, wanted 256 and 1", hdr.Dim1Length, hdr.RasGoodFlag)
+	}
+
+	mgh, err := ReadFsMghFS(fsys, "subject1/mri/brain.mgz", "auto")
+	if err != nil {
+		t.Errorf("ReadFsMghFS failed: %v", err)
+	}
+	var sum int = 0
+	for _, voxel_val := range mgh.Data.DataMriUchar {
+		sum += int(voxel_val)
+	}
+	var want int = 121035479 // known from external tests with standard software.
+	if sum != want {
+		t.Errorf("got MGH data sum=%d, wanted %d", sum, want)
+	}
+}
+
+func TestReadFromZippedSubjectMissingFile(t *testing.T) {
+	fsys := getZippedSubject(t)
+
+	if _, err := ReadFsCurvFS(fsys, "subject1/surf/rh.thickness"); err == nil {
+		t.Errorf("expected error for missing curv file in zip archive, got nil")
+	}
+	if _, err := ReadFsMghFS(fsys, "subject1/mri/T1.mgz", "auto"); err == nil {
+		t.Errorf("expected error for missing MGZ file in zip archive, got nil")
+	}
+}
+
+func TestReadFromDirFS(t *testing.T) {
+	fsys := os.DirFS("testdata")
+
+	mgh, err := ReadFsMghFS(fsys, "lh.thickness.fwhm5.fsaverage.mgh", "auto")
+	if err != nil {
+		t.Errorf("ReadFsMghFS failed: %v", err)
+	}
+	if mgh.Header.Dim1Length != 163842 {
+		t.Errorf("got Dim1Length=%d, wanted %d", mgh.Header.Dim1Length, 163842)
+	}
+}
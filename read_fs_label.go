@@ -3,6 +3,9 @@ package neuro
 import (
 	"encoding/csv"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -21,6 +24,8 @@ type FsLabel struct {
 	CoordY   []float32   // The first coordinate of the vertex or voxel in the volume or mesh.
 	CoordZ   []float32   // The first coordinate of the vertex or voxel in the volume or mesh.
 	Value []float32 // The per-element data.
+	SubjectName string // The subject name from the header line, e.g., 'bert'. Empty if the header does not contain it.
+	Vox2Ras string // The coordinate system of the coordinates from the 'vox2ras' field of the header line: 'TkReg' for tkregister (surface RAS) coordinates, or 'scanner' for scanner RAS coordinates. Empty if the header does not contain it.
 }
 
 
@@ -54,9 +59,77 @@ func VertexIsPartOfLabel(label FsLabel, meshNumVertices int32) ([]bool, error) {
 //  - filepath: the path to the file, must be a FreeSurfer label file from recon-all output, like subject/label/lh.cortex.label.
 //
 // Returns:
-//  - pervertex_data: float32 array of per-vertex descriptor values (e.g. cortical thickness)
+//  - FsLabel: the label
 //  - error: an error if one occurred
 func ReadFsLabel(filepath string) (FsLabel, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return FsLabel{}, err
+	}
+	defer file.Close()
+
+	return readFsLabelFrom(file, filepath)
+}
+
+// ReadFsLabelFS reads a file in FreeSurfer label format from the file system fsys.
+//
+// This allows reading labels from zip archives (see archive/zip), embedded files (see embed.FS) and other fs.FS implementations.
+//
+// Parameters:
+//  - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//  - name: the name of the label file in fsys, e.g. '<subject>/label/lh.cortex.label'
+//
+// Returns:
+//  - FsLabel: the label
+//  - error: an error if one occurred
+func ReadFsLabelFS(fsys fs.FS, name string) (FsLabel, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return FsLabel{}, err
+	}
+	defer file.Close()
+
+	return readFsLabelFrom(file, name)
+}
+
+// ReadFsLabelFrom reads data in FreeSurfer label format from r.
+//
+// Parameters:
+//  - r: the reader, e.g., an *os.File, a *strings.Reader or an http.Response body
+//
+// Returns:
+//  - FsLabel: the label
+//  - error: an error if one occurred
+func ReadFsLabelFrom(r io.Reader) (FsLabel, error) {
+	return readFsLabelFrom(r, "<io.Reader>")
+}
+
+// parseFsLabelHeaderLine extracts the subject name and the coordinate system from the first line of a label file.
+//
+// FreeSurfer writes this line as '#!ascii label  , from subject <subject> vox2ras=<TkReg|scanner>'. Older files may lack the fields, in which case empty strings are returned.
+func parseFsLabelHeaderLine(line string) (subjectName string, vox2ras string) {
+	fields := strings.Fields(line)
+	for idx, field := range fields {
+		if field == "subject" && idx > 0 && fields[idx-1] == "from" && idx+1 < len(fields) && !strings.HasPrefix(fields[idx+1], "vox2ras=") {
+			subjectName = fields[idx+1]
+		}
+		if strings.HasPrefix(field, "vox2ras=") {
+			vox2ras = strings.TrimPrefix(field, "vox2ras=")
+		}
+	}
+	return subjectName, vox2ras
+}
+
+// readFsLabelFrom reads data in FreeSurfer label format from r.
+//
+// Parameters:
+//  - r: the reader
+//  - source: description of the data source for error messages, e.g., the file path
+//
+// Returns:
+//  - FsLabel: the label
+//  - error: an error if one occurred
+func readFsLabelFrom(r io.Reader, source string) (FsLabel, error) {
 
 	var label FsLabel
 
@@ -66,23 +139,26 @@ func ReadFsLabel(filepath string) (FsLabel, error) {
 
 	// It seems the current CSVReader package is not flexible enough to allow this, so for now,
 	// we have to manually skip the first two lines.
-	lines, err := readLines(filepath)
+	lines, err := readLinesFrom(r)
 	if err != nil {
 		return label, err
 	}
-	if len(lines) <= 2 {
-		err = fmt.Errorf("readFsLabel: label file '%s' contains %d lines, but at least 3 required. ", filepath, len(lines))
+	// Labels without elements, e.g., for unused regions of an annotation, consist of the 2 header lines only.
+	if len(lines) < 2 {
+		err = fmt.Errorf("readFsLabel: label file '%s' contains %d lines, but at least 2 required. ", source, len(lines))
 		return label, err
 	}
 
+	label.SubjectName, label.Vox2Ras = parseFsLabelHeaderLine(lines[0])
+
 	// Get header field for number of elements in label and check it versus data in file.
 	num_rows, err := strconv.Atoi(strings.TrimSpace(lines[1]))
     if err != nil {
-		err = fmt.Errorf("readFsLabel: could not convert number of rows (from line 2) in label file '%s' to integer: '%s'", filepath, err)
+		err = fmt.Errorf("readFsLabel: could not convert number of rows (from line 2) in label file '%s' to integer: '%s'", source, err)
 		return label, err
     }
 	if num_rows != len(lines) -2 {
-		err = fmt.Errorf("readFsLabel: number of rows (from line 2) in label file '%s' is %d, but number of lines is %d. ", filepath, num_rows, len(lines))
+		err = fmt.Errorf("readFsLabel: number of rows (from line 2) in label file '%s' is %d, but number of lines is %d. ", source, num_rows, len(lines))
 		return label, err
 	}
 
@@ -92,18 +168,18 @@ func ReadFsLabel(filepath string) (FsLabel, error) {
 		linesStartingAtThird.WriteString("\n")
 	}
 
-	r := csv.NewReader(strings.NewReader(linesStartingAtThird.String()))
-	r.Comma = ' '
-	r.Comment = '#'
+	csvReader := csv.NewReader(strings.NewReader(linesStartingAtThird.String()))
+	csvReader.Comma = ' '
+	csvReader.Comment = '#'
 
-	records, err := r.ReadAll()
+	records, err := csvReader.ReadAll()
 	if err != nil {
 		return label, err
 	}
 
 	// Make sure the actual number of records is correct (no comments or empty lines in combination with less records).
 	if len(records) != num_rows {
-		err = fmt.Errorf("readFsLabel: number of rows (from line 2) in label file '%s' is %d, but number of records is %d. ", filepath, num_rows, len(records))
+		err = fmt.Errorf("readFsLabel: number of rows (from line 2) in label file '%s' is %d, but number of records is %d. ", source, num_rows, len(records))
 		return label, err
 	}
 
@@ -130,7 +206,7 @@ func ReadFsLabel(filepath string) (FsLabel, error) {
 		}
 
 		if len(record_no_whitespace) != 5 {
-			err = fmt.Errorf("readFsLabel: number of columns in label file '%s' record %d is %d, but should be 5: %s", filepath, idx, len(record_no_whitespace), record_no_whitespace)
+			err = fmt.Errorf("readFsLabel: number of columns in label file '%s' record %d is %d, but should be 5: %s", source, idx, len(record_no_whitespace), record_no_whitespace)
 			return label, err
 		}
 
@@ -138,7 +214,7 @@ func ReadFsLabel(filepath string) (FsLabel, error) {
 		tmpElementIndex, err = strconv.ParseInt(record_no_whitespace[0], 10, 32)
 
 		if err != nil {
-			err = fmt.Errorf("readFsLabel: could not convert element index in label file '%s' record %d to integer: '%s'", filepath, idx, err)
+			err = fmt.Errorf("readFsLabel: could not convert element index in label file '%s' record %d to integer: '%s'", source, idx, err)
 			return label, err
 		} else {
 			label.ElementIndex[idx] = int32(tmpElementIndex)
@@ -146,7 +222,7 @@ func ReadFsLabel(filepath string) (FsLabel, error) {
 
 		tmpfloat, err = strconv.ParseFloat(record_no_whitespace[1], 32)
 		if err != nil {
-			err = fmt.Errorf("readFsLabel: could not convert X coordinate in label file '%s' record %d to float32: '%s'", filepath, idx, err)
+			err = fmt.Errorf("readFsLabel: could not convert X coordinate in label file '%s' record %d to float32: '%s'", source, idx, err)
 			return label, err
 		} else {
 			label.CoordX[idx] = float32(tmpfloat)
@@ -154,7 +230,7 @@ func ReadFsLabel(filepath string) (FsLabel, error) {
 
 		tmpfloat, err = strconv.ParseFloat(record_no_whitespace[2], 32)
 		if err != nil {
-			err = fmt.Errorf("readFsLabel: could not convert Y coordinate in label file '%s' record %d to float32: '%s'", filepath, idx, err)
+			err = fmt.Errorf("readFsLabel: could not convert Y coordinate in label file '%s' record %d to float32: '%s'", source, idx, err)
 			return label, err
 		} else {
 			label.CoordY[idx] = float32(tmpfloat)
@@ -162,7 +238,7 @@ func ReadFsLabel(filepath string) (FsLabel, error) {
 
 		tmpfloat, err = strconv.ParseFloat(record_no_whitespace[3], 32)
 		if err != nil {
-			err = fmt.Errorf("readFsLabel: could not convert Z coordinate in label file '%s' record %d to float32: '%s'", filepath, idx, err)
+			err = fmt.Errorf("readFsLabel: could not convert Z coordinate in label file '%s' record %d to float32: '%s'", source, idx, err)
 			return label, err
 		} else {
 			label.CoordZ[idx] = float32(tmpfloat)
@@ -170,7 +246,7 @@ func ReadFsLabel(filepath string) (FsLabel, error) {
 
 		tmpfloat, err = strconv.ParseFloat(record_no_whitespace[4], 32)
 		if err != nil {
-			err = fmt.Errorf("readFsLabel: could not convert value in label file '%s' record %d to float32: '%s'", filepath, idx, err)
+			err = fmt.Errorf("readFsLabel: could not convert value in label file '%s' record %d to float32: '%s'", source, idx, err)
 			return label, err
 		} else {
 			label.Value[idx] = float32(tmpfloat)
@@ -4,7 +4,10 @@ package neuro
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestReadFsLabel(t *testing.T){
@@ -47,3 +50,25 @@ func ExampleReadFsLabel() {
 	fmt.Printf("Read label containing %d vertices from label file '%s'.\n", len(label.ElementIndex), labelFile)
 	// Output: Read label containing 140891 vertices from label file 'testdata/lh.cortex.label'.
 }
+
+func TestReadFsLabelFrom(t *testing.T) {
+	labelData := "#!ascii label  , from subject bert vox2ras=TkReg\n2\n5  -1.5  2.0  3.25 0.0000000000\n17  4.0  5.0  6.0 1.5000000000\n"
+
+	label, err := ReadFsLabelFrom(strings.NewReader(labelData))
+	if err != nil {
+		t.Errorf("ReadFsLabelFrom failed: %v", err)
+	}
+
+	want := FsLabel{
+		ElementIndex: []int32{5, 17},
+		CoordX:       []float32{-1.5, 4.0},
+		CoordY:       []float32{2.0, 5.0},
+		CoordZ:       []float32{3.25, 6.0},
+		Value:        []float32{0.0, 1.5},
+		SubjectName:  "bert",
+		Vox2Ras:      "TkReg",
+	}
+	if diff := cmp.Diff(want, label); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -2,11 +2,12 @@ package neuro
 
 import (
 	"bufio"
-	"bytes"
 	"compress/gzip"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"io/fs"
+	"math"
 	"os"
 	"strings"
 )
@@ -17,12 +18,69 @@ const MRI_UCHAR int32 = 0
 // MRI data type representing a 32 bit signed integer. Used by MGH format, see MghHeader struct.
 const MRI_INT int32 = 1
 
+// MRI data type representing a signed integer of the size of a C long, which is 64 bit on the platforms supported by FreeSurfer. Used by MGH format, see MghHeader struct.
+const MRI_LONG int32 = 2
+
 // MRI data type representing a 32 bit float. Used by MGH format, see MghHeader struct.
 const MRI_FLOAT int32 = 3
 
 // MRI data type representing a 16 bit signed integer. Used by MGH format, see MghHeader struct.
 const MRI_SHORT int32 = 4
 
+// MRI data type representing a bitmap. Defined by FreeSurfer, but not supported in MGH files by this package.
+const MRI_BITMAP int32 = 5
+
+// MRI data type representing a tensor. Defined by FreeSurfer, but not supported in MGH files by this package.
+const MRI_TENSOR int32 = 6
+
+// MRI data type representing a complex number made of two 32 bit floats. Defined by FreeSurfer, but not supported in MGH files by this package.
+const MRI_FLOAT_COMPLEX int32 = 7
+
+// MRI data type representing a complex number made of two 64 bit floats. Defined by FreeSurfer, but not supported in MGH files by this package.
+const MRI_DOUBLE_COMPLEX int32 = 8
+
+// MRI data type representing an RGB color. Defined by FreeSurfer, but not supported in MGH files by this package.
+const MRI_RGB int32 = 9
+
+// MRI data type representing a 16 bit unsigned integer. Used by MGH format, see MghHeader struct.
+const MRI_USHRT int32 = 10
+
+// mriDataTypeNames maps all MRI data type codes defined by FreeSurfer to their names, including the ones that are not supported by this package.
+var mriDataTypeNames = map[int32]string{
+	MRI_UCHAR:          "MRI_UCHAR",
+	MRI_INT:            "MRI_INT",
+	MRI_LONG:           "MRI_LONG",
+	MRI_FLOAT:          "MRI_FLOAT",
+	MRI_SHORT:          "MRI_SHORT",
+	MRI_BITMAP:         "MRI_BITMAP",
+	MRI_TENSOR:         "MRI_TENSOR",
+	MRI_FLOAT_COMPLEX:  "MRI_FLOAT_COMPLEX",
+	MRI_DOUBLE_COMPLEX: "MRI_DOUBLE_COMPLEX",
+	MRI_RGB:            "MRI_RGB",
+	MRI_USHRT:          "MRI_USHRT",
+}
+
+// UnsupportedMghDataTypeError is the error returned when MGH data has a data type code that this package cannot read or write.
+//
+// Use errors.As to check for it, e.g., to sort out files with legacy data types when processing an archive:
+//
+//	var dtErr *UnsupportedMghDataTypeError
+//	if errors.As(err, &dtErr) {
+//		fmt.Printf("Skipping file with data type code %d.\n", dtErr.Code)
+//	}
+type UnsupportedMghDataTypeError struct {
+	Code int32 // The MRI data type code from the MGH header.
+}
+
+// Error returns the error message, which includes the code and, if the code is defined by FreeSurfer, the name of the data type.
+func (e *UnsupportedMghDataTypeError) Error() string {
+	supported := "Supported are 0=MRI_UCHAR (uint8), 1=MRI_INT (int32), 2=MRI_LONG (int64), 3=MRI_FLOAT (float32), 4=MRI_SHORT (int16), 10=MRI_USHRT (uint16)."
+	if name, ok := mriDataTypeNames[e.Code]; ok {
+		return fmt.Sprintf("Unsupported MGH data type code %d (%s). %s", e.Code, name, supported)
+	}
+	return fmt.Sprintf("Invalid MGH data type code %d. %s", e.Code, supported)
+}
+
 // MghHeader models the header section of an MGH file.
 // MGH stands for Massachusetts General Hospital, and the MGH format is a binary format for
 // storing 3-dimensional or 4-dimensional structural MRI images of the human brain. The MGZ
@@ -33,7 +91,7 @@ type MghHeader struct {
 	Dim2Length  int32 // number of voxels in y direction
 	Dim3Length  int32 // number of voxels in z direction
 	Dim4Length  int32 // number of voxels in 4th dimension (typically time or subject index)
-	MghDataType int32 // MRI data type code. See MRI_UCHAR, MRI_INT, MRI_FLOAT, MRI_SHORT constants in this package.
+	MghDataType int32 // MRI data type code. See MRI_UCHAR, MRI_INT, MRI_LONG, MRI_FLOAT, MRI_SHORT, MRI_USHRT constants in this package.
 	DoF         int32
 	RasGoodFlag int16 // flag (1=yes, everything else=no) indicating whether the file contains valid RAS info.
 
@@ -42,7 +100,7 @@ type MghHeader struct {
 	YSize float32 // size of voxels in y direction (mm)
 	ZSize float32 // size of voxels in z direction (mm)
 
-	Mdc    [9]float32 // 9 float values, the 3x3 Mdc matrix that contains image orientation information. The interpretation order is row wise (row1-column1, row1-column2, row1-column3, row2-column1, ...)
+	Mdc    [9]float32 // 9 float values, the 3x3 Mdc matrix that contains image orientation information. The order is x_r, x_a, x_s, y_r, y_a, y_s, z_r, z_a, z_s, i.e., each triple is the direction of one voxel axis. See Vox2Ras for the full affine matrix.
 	Pxyz_c [3]float32 // 3 float values, the xyz coordinates of the central voxel. Think of the name 'Pxyz_c' as 'Point (x,y,z) coordinates of the center'.
 
 	// There are 194 more (currently unused) bytes reserved for the header before the data part starts.
@@ -51,11 +109,13 @@ type MghHeader struct {
 
 // Struct modelling the data part of an MGH file. Only the data in the field identified by MghDataType is valid.
 type MghData struct {
-	DataMriUchar []uint8   // The data, if MghDataType is MRI_UCHAR. Otherwise this field contains random data.
-	DataMriInt   []int32   // The data, if MghDataType is MRI_INT. Otherwise this field contains random data.
-	DataMriFloat []float32 // The data, if MghDataType is MRI_FLOAT. Otherwise this field contains random data.
-	DataMriShort []int16   // The data, if MghDataType is MRI_SHORT. Otherwise this field contains random data.
-	MghDataType  int32     // The MRI data type code. See MRI_UCHAR, MRI_INT, MRI_FLOAT, MRI_SHORT. Use this to determine which of the data fields above is valid.
+	DataMriUchar  []uint8   // The data, if MghDataType is MRI_UCHAR. Otherwise this field contains random data.
+	DataMriInt    []int32   // The data, if MghDataType is MRI_INT. Otherwise this field contains random data.
+	DataMriFloat  []float32 // The data, if MghDataType is MRI_FLOAT. Otherwise this field contains random data.
+	DataMriShort  []int16   // The data, if MghDataType is MRI_SHORT. Otherwise this field contains random data.
+	DataMriLong   []int64   // The data, if MghDataType is MRI_LONG. Otherwise this field contains random data.
+	DataMriUshort []uint16  // The data, if MghDataType is MRI_USHRT. Otherwise this field contains random data.
+	MghDataType   int32     // The MRI data type code. See MRI_UCHAR, MRI_INT, MRI_LONG, MRI_FLOAT, MRI_SHORT, MRI_USHRT. Use this to determine which of the data fields above is valid.
 }
 
 // getMghDataTypeName translates an MRI data type code (int32) into the respective name (string).
@@ -65,21 +125,39 @@ type MghData struct {
 //
 // Returns:
 //   - string: The MRI data type name, e.g., "MRI_UCHAR", "MRI_INT", "MRI_FLOAT", "MRI_SHORT".
-//   - error: Error if any, e.g., if the data type code is invalid or unsupported.
+//   - error: An *UnsupportedMghDataTypeError if the data type code is invalid or unsupported.
 func getMghDataTypeName(dtCode int32) (string, error) {
+	if _, err := getMghDataTypeSize(dtCode); err != nil {
+		return "", err
+	}
+	return mriDataTypeNames[dtCode], nil
+}
+
+// getMghDataTypeSize returns the size of a single value of an MRI data type, in bytes.
+//
+// Parameters:
+//   - dtCode: The MRI data type code, e.g., integer constants MRI_UCHAR, MRI_INT, MRI_FLOAT, MRI_SHORT.
+//
+// Returns:
+//   - int: The size of one value in bytes, e.g., 4 for MRI_FLOAT.
+//   - error: An *UnsupportedMghDataTypeError if the data type code is invalid or unsupported.
+func getMghDataTypeSize(dtCode int32) (int, error) {
 
 	switch dt := dtCode; dt {
 	case MRI_UCHAR:
-		return "MRI_UCHAR", nil
+		return 1, nil
 	case MRI_INT:
-		return "MRI_INT", nil
+		return 4, nil
+	case MRI_LONG:
+		return 8, nil
 	case MRI_FLOAT:
-		return "MRI_FLOAT", nil
+		return 4, nil
 	case MRI_SHORT:
-		return "MRI_SHORT", nil
+		return 2, nil
+	case MRI_USHRT:
+		return 2, nil
 	default:
-		err := fmt.Errorf("Invalid or unsupported MGH data type code '%d'. Supported are 0=uchar/uint8, 1=int32, 3=float32, 4=uint16.\n", dtCode)
-		return "", err
+		return 0, &UnsupportedMghDataTypeError{Code: dtCode}
 	}
 }
 
@@ -90,22 +168,15 @@ func getMghDataTypeName(dtCode int32) (string, error) {
 //
 // Returns:
 //   - int32: The MRI data type code, e.g., integer constants MRI_UCHAR, MRI_INT, MRI_FLOAT, MRI_SHORT.
-//   - error: Error if any, e.g., on invalid dtName.
+//   - error: Error if any, e.g., on invalid dtName or a name of a data type that is not supported.
 func getMghDataTypeCode(dtName string) (int32, error) {
-
-	switch dt := dtName; dt {
-	case "MRI_UCHAR":
-		return MRI_UCHAR, nil
-	case "MRI_INT":
-		return MRI_INT, nil
-	case "MRI_FLOAT":
-		return MRI_FLOAT, nil
-	case "MRI_SHORT":
-		return MRI_SHORT, nil
-	default:
-		err := fmt.Errorf("Invalid or unsupported MGH data type name '%s'. Supported are 'MRI_UCHAR' (code 0,uchar/uint8), 'MRI_INT'(code 1=int32), 'MRI_FLOAT' (code 3, float32), 'MRI_SHORT' (code 4, uint16).\n", dtName)
-		return -1, err
+	for dtCode, name := range mriDataTypeNames {
+		if name == dtName {
+			_, err := getMghDataTypeSize(dtCode)
+			return dtCode, err
+		}
 	}
+	return -1, fmt.Errorf("Invalid MGH data type name '%s'.", dtName)
 }
 
 // Determine or guess from filepath and is_gzipped whether the file at filepath is in gzip format.
@@ -151,88 +222,206 @@ func getIsGzippedMgh(isGzipped string) string {
 	return isGzipped
 }
 
-// readFileIntoByteSlice reads a file from disk into a byte slice. Supports uncompressed or gzip format.
+// getIsGzippedFlag translates a boolean into the isGzipped string values "yes" and "no".
 //
 // Parameters:
-// filepath: Path to the file to be read.
-// treatGzipped: Whether to treat the file as gzip-compressed.
+//   - treatGzipped: Whether to treat the file as gzip-compressed.
 //
 // Returns:
-// bs: The file contents as a byte slice.
-// err: An error, if any.
-func readFileIntoByteSlice(filepath string, treatGzipped bool) ([]byte, error) {
+//   - string: "yes" if treatGzipped is true, "no" otherwise.
+func getIsGzippedFlag(treatGzipped bool) string {
+	if treatGzipped {
+		return "yes"
+	}
+	return "no"
+}
 
-	bs := make([]byte, 0)
+// mghStream provides buffered read access to the uncompressed contents of an MGH or MGZ data stream.
+type mghStream struct {
+	file       io.Closer // The underlying file, if the stream was opened from a file path. Nil otherwise.
+	gzipReader *gzip.Reader
+	reader     *bufio.Reader
+}
 
-	if _, err := os.Stat(filepath); err != nil {
-		fmt.Printf("Could not stat file '%s'\n.", filepath)
-		return bs, err
-	}
+// Read reads uncompressed data from the stream, it implements io.Reader.
+func (s *mghStream) Read(p []byte) (int, error) {
+	return s.reader.Read(p)
+}
 
-	file, err := os.Open(filepath)
-	if err != nil {
-		err := fmt.Errorf("Could not open file file '%s' for reading: %s\n", filepath, err)
-		return bs, err
+// Close closes the stream, and the underlying file if the stream was opened from a file path.
+func (s *mghStream) Close() error {
+	if s.gzipReader != nil {
+		s.gzipReader.Close()
 	}
-	defer file.Close()
+	if s.file != nil {
+		return s.file.Close()
+	}
+	return nil
+}
 
-	// Get the file size
-	stat, err := file.Stat()
-	if err != nil {
-		fmt.Println(err)
-		return bs, err
+// isGzipStream checks whether the data in br starts with the gzip magic bytes, without consuming them.
+//
+// Parameters:
+//   - br: the buffered reader
+//
+// Returns:
+//   - bool: whether the data is gzip-compressed
+func isGzipStream(br *bufio.Reader) bool {
+	magic, err := br.Peek(2)
+	return err == nil && magic[0] == 0x1f && magic[1] == 0x8b
+}
+
+// newMghStream wraps an io.Reader that provides data in MGH or MGZ format into an mghStream. Nothing is read beyond what the caller consumes, so reading the header only decompresses the first few kilobytes of MGZ data.
+//
+// Parameters:
+//   - r: the reader
+//   - isGzipped: Whether to treat the data as gzip-compressed. If "auto", the data is checked for the gzip magic bytes. If not "auto", it has to be "yes"/"mgz" or "no"/"mgh" to force MGZ or MGH format, respectively.
+//
+// Returns:
+//   - *mghStream: the stream. The caller has to close it.
+//   - error: An error, if any.
+func newMghStream(r io.Reader, isGzipped string) (*mghStream, error) {
+	br := bufio.NewReader(r)
+
+	var treatGzipped bool
+	isGzipped = getIsGzippedMgh(isGzipped)
+	if !(isGzipped == "yes" || isGzipped == "no" || isGzipped == "auto") {
+		return nil, fmt.Errorf("invalid value '%s' for parameter isGzipped, must be one of 'yes'/'mgz', 'no'/'mgh' or 'auto'.", isGzipped)
+	}
+	if isGzipped == "auto" {
+		treatGzipped = isGzipStream(br)
+	} else {
+		treatGzipped = getIsGzipped("", isGzipped)
 	}
 
-	// Read the file into a byte slice, whether gzipped or not.
-	bs = make([]byte, stat.Size())
+	s := &mghStream{reader: br}
 	if treatGzipped {
-		gzipReader, err := gzip.NewReader(file)
+		gzipReader, err := gzip.NewReader(br)
 		if err != nil {
-			return bs, err
-		}
-		defer gzipReader.Close()
-		//gzipReader.Read(bs)
-		bs, err = io.ReadAll(gzipReader)
-		if err != nil {
-			return bs, err
-		}
-	} else {
-		_, err := bufio.NewReader(file).Read(bs)
-		if err != nil && err != io.EOF {
-			fmt.Println(err)
-			return bs, err
+			return nil, fmt.Errorf("Could not read gzip-compressed MGZ data: %w", err)
 		}
+		s.gzipReader = gzipReader
+		s.reader = bufio.NewReader(gzipReader)
+	}
+	return s, nil
+}
+
+// openFsMghFile opens an MGH or MGZ file for streaming reads, see newMghStream.
+//
+// Parameters:
+//   - filepath: Path to the file to be read.
+//   - treatGzipped: Whether to treat the file as gzip-compressed.
+//
+// Returns:
+//   - *mghStream: the stream. The caller has to close it.
+//   - error: An error, if any.
+func openFsMghFile(filepath string, treatGzipped bool) (*mghStream, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		err := fmt.Errorf("Could not open file file '%s' for reading: %w\n", filepath, err)
+		return nil, err
+	}
+
+	s, err := newMghStream(file, getIsGzippedFlag(treatGzipped))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Could not read file '%s': %w", filepath, err)
 	}
-	return bs, nil
+	s.file = file
+	return s, nil
 }
 
 // ReadFsMghHeader reads a FreeSurfer MGH file and returns the header as an MghHeader struct.
 //
-// See the documentation of the MghHeader struct for details on the header fields.
+// See the documentation of the MghHeader struct for details on the header fields. Only the header is read
+// from the file, so this is fast even for large MGZ files.
 //
 // Parameters:
-//   - filepath: path to the FreeSurfer MGH file, e.g. '<subject>/mri/brain.mgh'. Note that gzipped MGH files (file extension .mgz) are currently not supported.
+//   - filepath: path to the FreeSurfer MGH or MGZ file, e.g. '<subject>/mri/brain.mgz'.
 //   - isGzipped: Whether to treat the file as gzip-compressed. If "auto", the file extension is used to determine whether the file is gzip-compressed. If not "auto", it has to be "yes"/"mgz" or "no"/"mgh" to force MGZ or MGH format, respectively.
 //
 // Returns:
 //   - MghHeader: an MghHeader struct containing the header data
 //   - error: an error if one occurred
 func ReadFsMghHeader(filepath string, isGzipped string) (MghHeader, error) {
-	endian := binary.BigEndian
+	isGzipped = getIsGzippedMgh(isGzipped)
+	treatGzipped := getIsGzipped(filepath, isGzipped)
+	f, err := openFsMghFile(filepath, treatGzipped)
+	if err != nil {
+		return MghHeader{}, err
+	}
+	defer f.Close()
 
-	hdr := MghHeader{}
+	hdr, err := readFsMghHeader(f)
+	if err != nil {
+		return hdr, fmt.Errorf("Failed to read header of MGH file '%s': %w", filepath, err)
+	}
+	return hdr, nil
+}
+
+// ReadFsMghHeaderFS reads the header of an MGH or MGZ file from the file system fsys.
+//
+// This allows reading from zip archives (see archive/zip), embedded files (see embed.FS) and other fs.FS implementations. Only the header is read.
+//
+// Parameters:
+//   - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//   - name: the name of the MGH or MGZ file in fsys, e.g. '<subject>/mri/brain.mgz'
+//   - isGzipped: Whether to treat the file as gzip-compressed. If "auto", the file extension is used to determine whether the file is gzip-compressed. If not "auto", it has to be "yes"/"mgz" or "no"/"mgh" to force MGZ or MGH format, respectively.
+//
+// Returns:
+//   - MghHeader: an MghHeader struct containing the header data
+//   - error: an error if one occurred
+func ReadFsMghHeaderFS(fsys fs.FS, name string, isGzipped string) (MghHeader, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		err := fmt.Errorf("Could not open file file '%s' for reading: %w\n", name, err)
+		return MghHeader{}, err
+	}
+	defer file.Close()
 
 	isGzipped = getIsGzippedMgh(isGzipped)
-	treatGzipped := getIsGzipped(filepath, isGzipped)
-	bs, err := readFileIntoByteSlice(filepath, treatGzipped)
+	hdr, err := ReadFsMghHeaderFrom(file, getIsGzippedFlag(getIsGzipped(name, isGzipped)))
 	if err != nil {
-		err = fmt.Errorf("Could not read file '%s' into byte slice: %s", filepath, err)
-		return hdr, err
+		return hdr, fmt.Errorf("Failed to read header of MGH file '%s': %w", name, err)
 	}
-	r := bytes.NewReader(bs)
+	return hdr, nil
+}
+
+// ReadFsMghHeaderFrom reads the header of data in MGH or MGZ format from r.
+//
+// This is the io.Reader version of ReadFsMghHeader. Only the header is read and decompressed, but note that r may be read beyond the header due to buffering.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File, a *bytes.Reader or an http.Response body
+//   - isGzipped: Whether to treat the data as gzip-compressed. If "auto", the data is checked for the gzip magic bytes. If not "auto", it has to be "yes"/"mgz" or "no"/"mgh" to force MGZ or MGH format, respectively.
+//
+// Returns:
+//   - MghHeader: an MghHeader struct containing the header data
+//   - error: an error if one occurred
+func ReadFsMghHeaderFrom(r io.Reader, isGzipped string) (MghHeader, error) {
+	s, err := newMghStream(r, isGzipped)
+	if err != nil {
+		return MghHeader{}, err
+	}
+	defer s.Close()
+	return readFsMghHeader(s)
+}
+
+// readFsMghHeader reads and checks the header of an MGH file from r. Exactly the 284 header bytes are consumed from r.
+//
+// Parameters:
+//   - r: reader positioned at the start of the uncompressed MGH data
+//
+// Returns:
+//   - MghHeader: an MghHeader struct containing the header data
+//   - error: an error if one occurred
+func readFsMghHeader(r io.Reader) (MghHeader, error) {
+	endian := binary.BigEndian
+
+	hdr := MghHeader{}
 
 	if err := binary.Read(r, endian, &hdr); err != nil {
-		fmt.Println("ReadFsMghHeader: Read failed on MGH header:", err)
+		err := fmt.Errorf("readFsMghHeader: Read failed on MGH header: %w", err)
 		return hdr, err
 	}
 
@@ -247,7 +436,7 @@ func ReadFsMghHeader(filepath string, isGzipped string) (MghHeader, error) {
 	}
 
 	if hdr.MghVersion != 1 {
-		err := fmt.Errorf("MGH file '%s' is not a valid MGH file or has unsupported file format version (%d), while only version 1 is supported.\n", filepath, hdr.MghVersion)
+		err := fmt.Errorf("Not a valid MGH file or unsupported file format version (%d), while only version 1 is supported.\n", hdr.MghVersion)
 		return hdr, err
 	}
 
@@ -260,278 +449,312 @@ func ReadFsMghHeader(filepath string, isGzipped string) (MghHeader, error) {
 	return hdr, nil
 }
 
-// Mgh models a full MGH format file, including the MghHeader and the MghData.
-// See the separate documention for MghHeader and MghData for details on accessing fields.
+// MghFooter models the optional footer of an MGH file, which follows the data part.
+//
+// The footer contains the MRI scan parameters and a list of tagged data blocks, e.g., the command lines
+// of the FreeSurfer tools that created the file, or the path to the talairach transform of the subject.
+// All fields are optional, files written by tools other than FreeSurfer often do not contain a footer.
+type MghFooter struct {
+	HasScanParameters bool    // Whether the file contains the scan parameters below. If false, all of them are 0.
+	TR                float32 // The repetition time in ms.
+	FlipAngle         float32 // The flip angle in radians.
+	TE                float32 // The echo time in ms.
+	TI                float32 // The inversion time in ms.
+	FoV               float32 // The field of view in mm.
+	Tags              []FsTag // The tagged data blocks. See the TAG_* constants for tag types.
+}
+
+// CommandLines returns the command lines stored in the footer, i.e., the data of all tags of type TAG_CMDLINE.
+//
+// FreeSurfer tools add a command line tag to the files they write, so the command lines document the provenance of the file.
+//
+// Returns:
+//   - []string: the command lines, in the order in which they appear in the file
+func (footer MghFooter) CommandLines() []string {
+	cmdLines := make([]string, 0)
+	for _, tag := range footer.Tags {
+		if tag.TagType == TAG_CMDLINE {
+			cmdLines = append(cmdLines, tag.String())
+		}
+	}
+	return cmdLines
+}
+
+// TransformFilename returns the path to the talairach transform file stored in the footer, i.e., the data of the first tag of type TAG_MGH_XFORM or TAG_OLD_MGH_XFORM.
+//
+// Returns:
+//   - string: the path to the transform file, or the empty string if the footer contains no transform tag
+func (footer MghFooter) TransformFilename() string {
+	for _, tag := range footer.Tags {
+		if tag.TagType == TAG_MGH_XFORM || tag.TagType == TAG_OLD_MGH_XFORM {
+			return tag.String()
+		}
+	}
+	return ""
+}
+
+// readFsMghFooter reads the MGH footer from r.
+//
+// Like FreeSurfer, this function is lenient: the footer is optional, a footer that is truncated after the scan parameters results in a footer without tags,
+// and a truncated tag is ignored together with everything after it.
+//
+// Parameters:
+//   - r: reader positioned at the first byte after the data part of an MGH file
+//
+// Returns:
+//   - MghFooter: the footer
+//   - error: an error if one occurred, e.g., if the length of a tag is invalid
+func readFsMghFooter(r io.Reader) (MghFooter, error) {
+	endian := binary.BigEndian
+	footer := MghFooter{}
+
+	var scanParams [5]float32
+	if err := binary.Read(r, endian, &scanParams); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if Verbosity >= 1 {
+				fmt.Printf("readFsMghFooter: No scan parameters found in MGH footer.\n")
+			}
+			return footer, nil
+		}
+		return footer, err
+	}
+	footer.HasScanParameters = true
+	footer.TR, footer.FlipAngle, footer.TE, footer.TI, footer.FoV = scanParams[0], scanParams[1], scanParams[2], scanParams[3], scanParams[4]
+
+	tags, err := readFsTags(r)
+	if err != nil {
+		return footer, err
+	}
+	footer.Tags = tags
+
+	if Verbosity >= 1 {
+		fmt.Printf("readFsMghFooter: TR=%f, flip angle=%f, TE=%f, TI=%f, FoV=%f, %d tags.\n", footer.TR, footer.FlipAngle, footer.TE, footer.TI, footer.FoV, len(footer.Tags))
+	}
+	return footer, nil
+}
+
+// Mgh models a full MGH format file, including the MghHeader, the MghData and the MghFooter.
+// See the separate documention for MghHeader, MghData and MghFooter for details on accessing fields.
 type Mgh struct {
 	Header MghHeader
 	Data   MghData
+	Footer MghFooter
 }
 
-// ReadFsMgh reads a FreeSurfer MGH file and returns it as an Mgh struct. The Mgh struct contains the MghHeader and MghData.
+// ReadFsMgh reads a FreeSurfer MGH file and returns it as an Mgh struct. The Mgh struct contains the MghHeader, MghData and MghFooter.
 //
-// See the documentation for Mgh, MghHeader and MghData for details on accessing fields.
+// See the documentation for Mgh, MghHeader, MghData and MghFooter for details on accessing fields. The file is read
+// (and decompressed, for MGZ files) in a single pass.
 //
 // Parameters:
-//   - filepath: path to the FreeSurfer MGH file, e.g. '<subject>/mri/brain.mgh'. Note that gzipped MGH files (file extension .mgz) are currently not supported.
+//   - filepath: path to the FreeSurfer MGH or MGZ file, e.g. '<subject>/mri/brain.mgz'.
 //   - isGzipped: Whether to treat the file as gzip-compressed. If "auto", the file extension is used to determine whether the file is gzip-compressed. If not "auto", it has to be "yes"/"mgz" or "no"/"mgh" to force MGZ or MGH format, respectively.
 //
 // Returns:
-//   - Mgh: an Mgh struct containing the MghHeader and MghData
+//   - Mgh: an Mgh struct containing the MghHeader, MghData and MghFooter
 func ReadFsMgh(filepath string, isGzipped string) (Mgh, error) {
-	var mgh Mgh
-	hdr, err := ReadFsMghHeader(filepath, isGzipped)
+	isGzipped = getIsGzippedMgh(isGzipped)
+	treatGzipped := getIsGzipped(filepath, isGzipped)
+	f, err := openFsMghFile(filepath, treatGzipped)
 	if err != nil {
-		err := fmt.Errorf("Failed to read MGH header: %s.", err)
-		return mgh, err
+		return Mgh{}, err
 	}
-	mgh.Header = hdr
-	data, err := ReadFsMghData(filepath, hdr, isGzipped)
+	defer f.Close()
+
+	mgh, err := readFsMgh(f)
 	if err != nil {
-		err := fmt.Errorf("Failed to read MGH data: %s.", err)
-		return mgh, err
+		return mgh, fmt.Errorf("Failed to read MGH file '%s': %w", filepath, err)
 	}
-	mgh.Data = data
 	return mgh, nil
 }
 
-// ReadFsMghData reads the data part of an MGH or MGZ format file into an MghData struct.
+// ReadFsMghFS reads an MGH or MGZ file from the file system fsys and returns it as an Mgh struct.
 //
-// See the documentation for MghData for details on accessing fields.
+// This allows reading from zip archives (see archive/zip), embedded files (see embed.FS) and other fs.FS implementations.
 //
 // Parameters:
-//   - filepath: path to readable input file in MGH or MGZ format
-//   - hdr: MghHeader struct containing the header data
-//   - isGzipped: string indicating whether the input file is gzipped or not. If set to 'auto', the function will try to determine this automatically.
+//   - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//   - name: the name of the MGH or MGZ file in fsys, e.g. '<subject>/mri/brain.mgz'
+//   - isGzipped: Whether to treat the file as gzip-compressed. If "auto", the file extension is used to determine whether the file is gzip-compressed. If not "auto", it has to be "yes"/"mgz" or "no"/"mgh" to force MGZ or MGH format, respectively.
 //
 // Returns:
-//   - MghData: an MghData struct containing the data
-//   - error: an error if one occurred, nil otherwise
-func ReadFsMghData(filepath string, hdr MghHeader, isGzipped string) (MghData, error) {
+//   - Mgh: an Mgh struct containing the MghHeader, MghData and MghFooter
+//   - error: an error if one occurred
+func ReadFsMghFS(fsys fs.FS, name string, isGzipped string) (Mgh, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		err := fmt.Errorf("Could not open file file '%s' for reading: %w\n", name, err)
+		return Mgh{}, err
+	}
+	defer file.Close()
 
-	readMghData := MghData{}
-	readMghData.MghDataType = -1
 	isGzipped = getIsGzippedMgh(isGzipped)
-	treatGzipped := getIsGzipped(filepath, isGzipped)
-
-	switch dt := hdr.MghDataType; dt {
-	case MRI_INT:
-		arr, err := readFsMghDataMriInt(filepath, hdr, treatGzipped)
-		if err != nil {
-			err := fmt.Errorf("Failed to read MRI_INT data from MGH file '%s': %s.\n", filepath, err)
-			return readMghData, err
-		}
-		readMghData.DataMriInt = arr
-	case MRI_FLOAT:
-		arr, err := readFsMghDataMriFloat(filepath, hdr, treatGzipped)
-		if err != nil {
-			err := fmt.Errorf("Failed to read MRI_FLOAT data from MGH file '%s': %s.\n", filepath, err)
-			return readMghData, err
-		}
-		readMghData.DataMriFloat = arr
-	case MRI_UCHAR:
-		arr, err := readFsMghDataMriUchar(filepath, hdr, treatGzipped)
-		if err != nil {
-			err := fmt.Errorf("Failed to read MRI_UCHAR data from MGH file '%s': %s.\n", filepath, err)
-			return readMghData, err
-		}
-		readMghData.DataMriUchar = arr
-	case MRI_SHORT:
-		arr, err := readFsMghDataMriShort(filepath, hdr, treatGzipped)
-		if err != nil {
-			err := fmt.Errorf("Failed to read MRI_SHORT data from MGH file '%s': %s.\n", filepath, err)
-			return readMghData, err
-		}
-		readMghData.DataMriShort = arr
-	default:
-		return readMghData, fmt.Errorf("Header of MGH file '%s' declares unsupported MGH data type code: %d.\n", filepath, dt)
+	mgh, err := ReadFsMghFrom(file, getIsGzippedFlag(getIsGzipped(name, isGzipped)))
+	if err != nil {
+		return mgh, fmt.Errorf("Failed to read MGH file '%s': %w", name, err)
 	}
-	readMghData.MghDataType = hdr.MghDataType
-	return readMghData, nil
+	return mgh, nil
 }
 
-// readFsMghDataMriInt reads the MRI_INT data array part of an MGH format file.
+// ReadFsMghFrom reads data in MGH or MGZ format from r and returns it as an Mgh struct.
+//
+// This is the io.Reader version of ReadFsMgh, it can be used to read from in-memory buffers, network streams or archives.
 //
 // Parameters:
-//   - filepath: path to readable input file in MGH or MGZ format
-//   - hdr: MghHeader struct containing the header data
-//   - treatGzipped: boolean indicating whether the input file is gzipped or not
+//   - r: the reader, e.g., an *os.File, a *bytes.Reader or an http.Response body
+//   - isGzipped: Whether to treat the data as gzip-compressed. If "auto", the data is checked for the gzip magic bytes. If not "auto", it has to be "yes"/"mgz" or "no"/"mgh" to force MGZ or MGH format, respectively.
 //
 // Returns:
-//   - []int32: an array of int32 values. You will have to reshape this 1D array to a 4D array with the dimensions given in the MghHeader.
-//   - error: an error if one occurred, nil otherwise
-func readFsMghDataMriInt(filepath string, hdr MghHeader, treatGzipped bool) ([]int32, error) {
-
-	var numValues int64 = (int64)(hdr.Dim1Length * hdr.Dim2Length * hdr.Dim3Length * hdr.Dim4Length)
-	dataArr := make([]int32, numValues)
-	var mghDataType string = "MRI_INT"
-
-	if Verbosity >= 1 {
-		fmt.Printf("Reading %d values of type %s from MGH file '%s', treatGzipped=%t\n", numValues, mghDataType, filepath, treatGzipped)
+//   - Mgh: an Mgh struct containing the MghHeader, MghData and MghFooter
+//   - error: an error if one occurred
+func ReadFsMghFrom(r io.Reader, isGzipped string) (Mgh, error) {
+	s, err := newMghStream(r, isGzipped)
+	if err != nil {
+		return Mgh{}, err
 	}
+	defer s.Close()
+	return readFsMgh(s)
+}
 
-	endian := binary.BigEndian
+// readFsMgh reads header, data and footer of an MGH file from the uncompressed data in r.
+//
+// Parameters:
+//   - r: the reader, positioned at the start of the uncompressed MGH data
+//
+// Returns:
+//   - Mgh: an Mgh struct containing the MghHeader, MghData and MghFooter
+//   - error: an error if one occurred
+func readFsMgh(r io.Reader) (Mgh, error) {
+	var mgh Mgh
 
-	bs, err := readFileIntoByteSlice(filepath, treatGzipped)
+	hdr, err := readFsMghHeader(r)
 	if err != nil {
-		err = fmt.Errorf("Could not read file '%s' into byte slice: %s", filepath, err)
-		return dataArr, err
+		err := fmt.Errorf("Failed to read MGH header: %w.", err)
+		return mgh, err
 	}
-
-	// Read the byte slice
-	r := bytes.NewReader(bs)
-
-	// Skip the header
-	numBytesHeader := 284
-	skippedHeaderData := make([]uint8, numBytesHeader)
-	if err := binary.Read(r, endian, &skippedHeaderData); err != nil {
-		err := fmt.Errorf("ReadFsMghData: binary.Read failed on header part of MGH file %s: %s", filepath, err)
-		return dataArr, err
+	mgh.Header = hdr
+	data, err := readFsMghData(r, hdr)
+	if err != nil {
+		err := fmt.Errorf("Failed to read MGH data: %w.", err)
+		return mgh, err
 	}
-
-	// Read the data
-	if err := binary.Read(r, endian, &dataArr); err != nil {
-		err := fmt.Errorf("ReadFsMghData: binary.Read failed on %d values of %s data: %s", numValues, mghDataType, err)
-		return dataArr, err
+	mgh.Data = data
+	footer, err := readFsMghFooter(r)
+	if err != nil {
+		err := fmt.Errorf("Failed to read MGH footer: %w.", err)
+		return mgh, err
 	}
-
-	return dataArr, nil
+	mgh.Footer = footer
+	return mgh, nil
 }
 
-// readFsMghDataMriFloat reads the MRI_FLOAT data array part of an MGH format file.
+// ReadFsMghData reads the data part of an MGH or MGZ format file into an MghData struct.
+//
+// See the documentation for MghData for details on accessing fields.
 //
 // Parameters:
 //   - filepath: path to readable input file in MGH or MGZ format
 //   - hdr: MghHeader struct containing the header data
-//   - treatGzipped: boolean indicating whether the input file is gzipped or not
+//   - isGzipped: string indicating whether the input file is gzipped or not. If set to 'auto', the function will try to determine this automatically.
 //
 // Returns:
-//   - []float32: an array of float32 values. You will have to reshape this 1D array to a 4D array with the dimensions given in the MghHeader.
+//   - MghData: an MghData struct containing the data
 //   - error: an error if one occurred, nil otherwise
-func readFsMghDataMriFloat(filepath string, hdr MghHeader, treatGzipped bool) ([]float32, error) {
-
-	var numValues int64 = (int64)(hdr.Dim1Length * hdr.Dim2Length * hdr.Dim3Length * hdr.Dim4Length)
-	dataArr := make([]float32, numValues)
-	var mghDataType string = "MRI_FLOAT"
-
-	if Verbosity >= 1 {
-		fmt.Printf("Reading %d values of type %s from MGH file '%s', treatGzipped=%t\n", numValues, mghDataType, filepath, treatGzipped)
-	}
+func ReadFsMghData(filepath string, hdr MghHeader, isGzipped string) (MghData, error) {
 
-	endian := binary.BigEndian
-	bs, err := readFileIntoByteSlice(filepath, treatGzipped)
+	isGzipped = getIsGzippedMgh(isGzipped)
+	treatGzipped := getIsGzipped(filepath, isGzipped)
+	f, err := openFsMghFile(filepath, treatGzipped)
 	if err != nil {
-		err = fmt.Errorf("Could not read file '%s' into byte slice: %s", filepath, err)
-		return dataArr, err
+		return MghData{MghDataType: -1}, err
 	}
-	// Read the byte slice
-	r := bytes.NewReader(bs)
+	defer f.Close()
 
 	// Skip the header
-	numBytesHeader := 284
-	skippedHeaderData := make([]uint8, numBytesHeader)
-	if err := binary.Read(r, endian, &skippedHeaderData); err != nil {
-		err := fmt.Errorf("ReadFsMghData: binary.Read failed on header part of MGH file %s: %s", filepath, err)
-		return dataArr, err
+	numBytesHeader := int64(284)
+	if _, err := io.CopyN(io.Discard, f, numBytesHeader); err != nil {
+		err := fmt.Errorf("ReadFsMghData: failed to skip header part of MGH file %s: %w", filepath, err)
+		return MghData{MghDataType: -1}, err
 	}
 
-	// Read the data
-	if err := binary.Read(r, endian, &dataArr); err != nil {
-		err := fmt.Errorf("ReadFsMghData: binary.Read failed on %d values of %s data: %s", numValues, mghDataType, err)
-		return dataArr, err
+	data, err := readFsMghData(f, hdr)
+	if err != nil {
+		err := fmt.Errorf("Failed to read data from MGH file '%s': %w.\n", filepath, err)
+		return data, err
 	}
-	return dataArr, nil
+	return data, nil
 }
 
-// readFsMghDataMriuchar reads the MRI_UCHAR data array part of an MGH format file.
+// maxMghNumValues is the maximal number of voxel values read from an MGH file, see maxNiftiNumValues.
+const maxMghNumValues = math.MaxInt32
+
+// getMghCheckedNumValues computes the number of voxel values described by the dimensions in an MghHeader, like getMghNumValues.
+//
+// Unlike getMghNumValues, it checks the dimensions, so the result can be used to allocate the data of files with broken headers.
 //
 // Parameters:
-//   - filepath: path to readable input file in MGH or MGZ format
 //   - hdr: MghHeader struct containing the header data
-//   - treatGzipped: boolean indicating whether the input file is gzipped or not
 //
 // Returns:
-//   - []uint8: an array of uint8 values. You will have to reshape this 1D array to a 4D array with the dimensions given in the MghHeader.
-//   - error: an error if one occurred, nil otherwise
-func readFsMghDataMriUchar(filepath string, hdr MghHeader, treatGzipped bool) ([]uint8, error) {
-
-	var numValues int64 = (int64)(hdr.Dim1Length * hdr.Dim2Length * hdr.Dim3Length * hdr.Dim4Length)
-	dataArr := make([]uint8, numValues)
-	var mghDataType string = "MRI_UCHAR"
-
-	if Verbosity >= 1 {
-		fmt.Printf("Reading %d values of type %s from MGH file '%s', treatGzipped=%t\n", numValues, mghDataType, filepath, treatGzipped)
-	}
-
-	endian := binary.BigEndian
-	bs, err := readFileIntoByteSlice(filepath, treatGzipped)
-	if err != nil {
-		err = fmt.Errorf("Could not read file '%s' into byte slice: %s", filepath, err)
-		return dataArr, err
-	}
-	// Read the byte slice
-	r := bytes.NewReader(bs)
-
-	// Skip the header
-	numBytesHeader := 284
-	skippedHeaderData := make([]uint8, numBytesHeader)
-	if err := binary.Read(r, endian, &skippedHeaderData); err != nil {
-		err := fmt.Errorf("ReadFsMghData: binary.Read failed on header part of MGH file %s: %s", filepath, err)
-		return dataArr, err
-	}
-
-	// Read the data
-	if err := binary.Read(r, endian, &dataArr); err != nil {
-		err := fmt.Errorf("ReadFsMghData: binary.Read failed on %d values of %s data: %s", numValues, mghDataType, err)
-		return dataArr, err
+//   - int: the number of values, i.e., the product of the 4 dimension lengths
+//   - error: an error if a dimension length is negative, or the number of values exceeds maxMghNumValues
+func getMghCheckedNumValues(hdr MghHeader) (int, error) {
+	dims := [4]int32{hdr.Dim1Length, hdr.Dim2Length, hdr.Dim3Length, hdr.Dim4Length}
+	numValues := int64(1)
+	for idx, d := range dims {
+		if d < 0 {
+			return 0, fmt.Errorf("invalid negative length %d of dimension %d in MGH header.", d, idx+1)
+		}
+		if d > 0 && numValues > maxMghNumValues/int64(d) {
+			return 0, fmt.Errorf("the dimensions %v in MGH header describe more than %d values.", dims, int64(maxMghNumValues))
+		}
+		numValues *= int64(d)
 	}
-
-	return dataArr, nil
+	return int(numValues), nil
 }
 
-// readFsMghDataMriShort reads the MRI_SHORT data array part of an MGH format file.
+// readFsMghData reads the data part of an MGH file from r.
 //
 // Parameters:
-//   - filepath: path to readable input file in MGH or MGZ format
-//   - hdr: MghHeader struct containing the header data
-//   - treatGzipped: boolean indicating whether the input file is gzipped or not
+//   - r: reader positioned at the first byte after the MGH header
+//   - hdr: MghHeader struct containing the header data, used to determine the data type and the number of values
 //
 // Returns:
-//   - []int16: an array of int16 values. You will have to reshape this 1D array to a 4D array with the dimensions given in the MghHeader.
+//   - MghData: an MghData struct containing the data. The data slice is a 1D array, you will have to reshape it to a 4D array with the dimensions given in the MghHeader.
 //   - error: an error if one occurred, nil otherwise
-func readFsMghDataMriShort(filepath string, hdr MghHeader, treatGzipped bool) ([]int16, error) {
+func readFsMghData(r io.Reader, hdr MghHeader) (MghData, error) {
 
-	var numValues int64 = (int64)(hdr.Dim1Length * hdr.Dim2Length * hdr.Dim3Length * hdr.Dim4Length)
-	dataArr := make([]int16, numValues)
-	var mghDataType string = "MRI_SHORT"
+	readMghData := MghData{}
+	readMghData.MghDataType = -1
 
-	if Verbosity >= 1 {
-		fmt.Printf("Reading %d values of type %s from MGH file '%s', treatGzipped=%t\n", numValues, mghDataType, filepath, treatGzipped)
+	numValues, err := getMghCheckedNumValues(hdr)
+	if err != nil {
+		return readMghData, fmt.Errorf("readFsMghData: %w", err)
 	}
-
-	endian := binary.BigEndian
-
-	bs, err := readFileIntoByteSlice(filepath, treatGzipped)
+	dtSize, err := getMghDataTypeSize(hdr.MghDataType)
 	if err != nil {
-		err = fmt.Errorf("Could not read file '%s' into byte slice: %s", filepath, err)
-		return dataArr, err
+		return readMghData, err
 	}
 
-	// Read the byte slice
-	r := bytes.NewReader(bs)
-
-	// Skip the header
-	numBytesHeader := 284
-	skippedHeaderData := make([]uint8, numBytesHeader)
-	if err := binary.Read(r, endian, &skippedHeaderData); err != nil {
-		err := fmt.Errorf("ReadFsMghDataMriShort: binary.Read failed on header part of MGH file %s: %s", filepath, err)
-		return dataArr, err
+	mghDataType, _ := getMghDataTypeName(hdr.MghDataType)
+	if Verbosity >= 1 {
+		fmt.Printf("Reading %d values of type %s from MGH data.\n", numValues, mghDataType)
 	}
 
-	// Read the data
-	if err := binary.Read(r, endian, &dataArr); err != nil {
-		err := fmt.Errorf("ReadFsMghDataMriShort: binary.Read failed on %d values of %s data: %s", numValues, mghDataType, err)
-		return dataArr, err
+	// Do not trust the dimensions for the allocation, read the data first so truncated files fail before the data slice is allocated.
+	numBytes := int64(numValues) * int64(dtSize)
+	bs, err := io.ReadAll(io.LimitReader(r, numBytes))
+	if err == nil && int64(len(bs)) != numBytes {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return readMghData, fmt.Errorf("readFsMghData: failed to read %d values of %s data: %w", numValues, mghDataType, err)
 	}
 
-	return dataArr, nil
+	readMghData, err = newMghData(hdr.MghDataType, numValues)
+	if err != nil {
+		return readMghData, err
+	}
+	decodeMghValues(bs, readMghData, 0)
+	return readMghData, nil
 }
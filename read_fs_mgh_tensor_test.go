@@ -4,20 +4,21 @@ package neuro
 
 import (
 	"fmt"
+	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"gorgonia.org/tensor"
 )
 
 func ExampleReadFsMgh_tensor() {
-	// Illustrates how to read the 4D MRI image returned by ReadFsMgh into a tensor data structure
+	// Illustrates how to convert the 4D MRI image returned by ReadFsMgh into a tensor data structure
 	// from the "gorgonia.org/tensor" package for convenient access to voxel values.
 	// This example requires 'import "gorgonia.org/tensor"'.
 	var mgzFile string = "testdata/brain.mgz"
 
 	mgh, _ := ReadFsMgh(mgzFile, "yes")
-	var h MghHeader = mgh.Header
 
-	data := tensor.New(tensor.WithShape(int(h.Dim1Length), int(h.Dim2Length), int(h.Dim3Length), int(h.Dim4Length)), tensor.WithBacking(mgh.Data.DataMriUchar))
+	data, _ := mgh.ToTensor()
 	val1, _ := data.At(99, 99, 99, 0)    // 77
 	val2, _ := data.At(109, 109, 109, 0) // 71
 	val3, _ := data.At(0, 0, 0, 0)       // 0
@@ -27,3 +28,107 @@ func ExampleReadFsMgh_tensor() {
 	fmt.Printf("Voxel values=%d, %d, %d", val1, val2, val3)
 	// Output: Voxel values=77, 71, 0
 }
+
+func TestMghToTensorAxisOrder(t *testing.T) {
+	mgh := getTestMgh4D()
+
+	data, err := mgh.ToTensor()
+	if err != nil {
+		t.Fatalf("ToTensor failed: %v", err)
+	}
+	if diff := cmp.Diff(tensor.Shape{4, 3, 2, 5}, data.Shape()); diff != "" {
+		t.Error(diff)
+	}
+	if data.Dtype() != tensor.Float32 {
+		t.Errorf("got dtype %s, wanted %s", data.Dtype(), tensor.Float32)
+	}
+
+	// The test volume encodes the voxel indices in the values: i + 10*j + 100*k + 1000*t.
+	val, err := data.At(3, 2, 1, 4)
+	if err != nil {
+		t.Fatalf("At failed: %v", err)
+	}
+	if val.(float32) != 4123 {
+		t.Errorf("got value %v at (3, 2, 1, 4), wanted %v", val, 4123)
+	}
+}
+
+func TestMghTensorRoundTripAllDataTypes(t *testing.T) {
+	for _, dtCode := range []int32{MRI_UCHAR, MRI_INT, MRI_LONG, MRI_FLOAT, MRI_SHORT, MRI_USHRT} {
+		volume, err := NewVolumeFromMgh(getTestMgh4D())
+		if err != nil {
+			t.Fatalf("NewVolumeFromMgh failed: %v", err)
+		}
+		converted, err := volume.ConvertTo(dtCode)
+		if err != nil {
+			t.Fatalf("ConvertTo failed: %v", err)
+		}
+		mgh := getTestMgh4D()
+		mgh.Header.MghDataType = dtCode
+		mgh.Header.RasGoodFlag = 1
+		mgh.Header.XSize = 2.0
+		mgh.Data = converted.Data()
+
+		data, err := mgh.ToTensor()
+		if err != nil {
+			t.Fatalf("ToTensor failed for data type %d: %v", dtCode, err)
+		}
+		reread, err := MghFromTensor(data, mgh.Header)
+		if err != nil {
+			t.Fatalf("MghFromTensor failed for data type %d: %v", dtCode, err)
+		}
+		if diff := cmp.Diff(mgh, reread); diff != "" {
+			t.Errorf("data type %d: %s", dtCode, diff)
+		}
+	}
+}
+
+func TestMghFromTensorViewsAndColumnMajor(t *testing.T) {
+	mgh := getTestMgh4D()
+
+	// A transposed view with shape (5, 2, 3, 4), i.e., the axes in reverse order.
+	data, err := mgh.ToTensor()
+	if err != nil {
+		t.Fatalf("ToTensor failed: %v", err)
+	}
+	if err := data.T(3, 2, 1, 0); err != nil {
+		t.Fatalf("T failed: %v", err)
+	}
+	transposed, err := MghFromTensor(data, mgh.Header)
+	if err != nil {
+		t.Fatalf("MghFromTensor failed: %v", err)
+	}
+	if transposed.Header.Dim1Length != 5 || transposed.Header.Dim4Length != 4 {
+		t.Errorf("got dimensions (%d, %d, %d, %d), wanted (5, 2, 3, 4)", transposed.Header.Dim1Length, transposed.Header.Dim2Length, transposed.Header.Dim3Length, transposed.Header.Dim4Length)
+	}
+	volume, _ := NewVolumeFromMgh(transposed)
+	val, _ := volume.At(4, 1, 2, 3)
+	if val != 4123 {
+		t.Errorf("got value %f at (4, 1, 2, 3) of transposed volume, wanted %f", val, 4123.0)
+	}
+
+	// A column-major tensor already has the MGH layout.
+	colMajor := tensor.New(tensor.WithShape(2, 3), tensor.AsFortran([]int16{1, 2, 3, 4, 5, 6}))
+	fromColMajor, err := MghFromTensor(colMajor, MghHeader{MghVersion: 1})
+	if err != nil {
+		t.Fatalf("MghFromTensor failed: %v", err)
+	}
+	val11, _ := colMajor.At(1, 1)
+	volume, _ = NewVolumeFromMgh(fromColMajor)
+	mghVal11, _ := volume.At(1, 1, 0, 0)
+	if float64(val11.(int16)) != mghVal11 || fromColMajor.Header.Dim3Length != 1 || fromColMajor.Header.Dim4Length != 1 {
+		t.Errorf("got value %f at (1, 1) and dimensions (%d, %d, %d, %d), wanted %d and (2, 3, 1, 1)", mghVal11, fromColMajor.Header.Dim1Length, fromColMajor.Header.Dim2Length, fromColMajor.Header.Dim3Length, fromColMajor.Header.Dim4Length, val11)
+	}
+}
+
+func TestMghFromTensorUnsupportedDtype(t *testing.T) {
+	data := tensor.New(tensor.WithShape(2, 2), tensor.WithBacking([]float64{1, 2, 3, 4}))
+	if _, err := MghFromTensor(data, MghHeader{}); err == nil {
+		t.Errorf("expected error for unsupported dtype float64, got nil")
+	}
+
+	data = tensor.New(tensor.WithShape(1, 1, 1, 1, 2), tensor.WithBacking([]float32{1, 2}))
+	if _, err := MghFromTensor(data, MghHeader{}); err == nil {
+		t.Errorf("expected error for 5D tensor, got nil")
+	}
+}
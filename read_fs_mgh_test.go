@@ -3,7 +3,16 @@ package neuro
 // https://pkg.go.dev/testing
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestReadFsMghHeader(t *testing.T) {
@@ -122,3 +131,236 @@ func TestReadFsMghPervertex(t *testing.T) {
 		t.Errorf("got mean thickness=%f, wanted between %f and %f", mean_thickness, lower_border, upper_border)
 	}
 }
+
+func TestReadFsMgzFooter(t *testing.T) {
+	var mgzFile string = "testdata/brain.mgz"
+
+	mgh, err := ReadFsMgh(mgzFile, "auto")
+	if err != nil {
+		t.Errorf("ReadFsMgh failed: %v", err)
+	}
+	footer := mgh.Footer
+
+	if !footer.HasScanParameters {
+		t.Errorf("got HasScanParameters=false, wanted true")
+	}
+	// known from external tests with standard software, try on command line: mri_info testdata/brain.mgz
+	if !almostEqualF32(footer.TR, 2300.0, 1e-3) || !almostEqualF32(footer.TE, 2.01, 1e-3) || !almostEqualF32(footer.TI, 900.0, 1e-3) {
+		t.Errorf("got TR=%f, TE=%f, TI=%f, wanted 2300.0, 2.01, 900.0", footer.TR, footer.TE, footer.TI)
+	}
+	if !almostEqualF32(footer.FlipAngle, 0.15708, 1e-4) { // 9 degrees
+		t.Errorf("got flip angle=%f, wanted 0.15708", footer.FlipAngle)
+	}
+	if footer.FoV != 256.0 {
+		t.Errorf("got FoV=%f, wanted 256.0", footer.FoV)
+	}
+
+	cmdLines := footer.CommandLines()
+	if len(cmdLines) != 4 {
+		t.Errorf("got %d command lines, wanted 4", len(cmdLines))
+	} else if !strings.HasPrefix(cmdLines[3], "mri_normalize -mprage") {
+		t.Errorf("got last command line '%s', wanted it to start with 'mri_normalize -mprage'", cmdLines[3])
+	}
+
+	wantXfm := "/Users/timschaefer/data/tim/mri/transforms/talairach.xfm"
+	if got := footer.TransformFilename(); got != wantXfm {
+		t.Errorf("got transform file name '%s', wanted '%s'", got, wantXfm)
+	}
+}
+
+func TestReadFsMghFooterPervertex(t *testing.T) {
+	var mghFile string = "testdata/lh.thickness.fwhm5.fsaverage.mgh"
+
+	mgh, _ := ReadFsMgh(mghFile, "auto")
+
+	got := len(mgh.Footer.Tags)
+	want := 3
+	if got != want {
+		t.Errorf("got %d tags in MGH footer, wanted %d", got, want)
+	}
+}
+
+func TestReadFsMghFooterMissing(t *testing.T) {
+	footer, err := readFsMghFooter(bytes.NewReader([]byte{}))
+	if err != nil {
+		t.Errorf("readFsMghFooter failed on empty footer: %v", err)
+	}
+	if footer.HasScanParameters || len(footer.Tags) != 0 {
+		t.Errorf("got footer with scan parameters or tags from empty input")
+	}
+}
+
+func TestReadFsMghFooterTruncatedTag(t *testing.T) {
+	var buf bytes.Buffer
+	tags := []FsTag{{TagType: TAG_CMDLINE, Data: []byte("mri_convert a.mgz b.mgz")}, {TagType: TAG_CMDLINE, Data: []byte("mris_preproc")}}
+	if err := writeFsMghFooter(&buf, MghFooter{HasScanParameters: true, Tags: tags}); err != nil {
+		t.Fatalf("writeFsMghFooter failed: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Truncate inside the data, the length and the type of the last tag.
+	for _, cut := range []int{3, 14, 22} {
+		footer, err := readFsMghFooter(bytes.NewReader(data[:len(data)-cut]))
+		if err != nil {
+			t.Errorf("cut %d: readFsMghFooter failed on truncated tag: %v", cut, err)
+		}
+		if diff := cmp.Diff(tags[:1], footer.Tags); diff != "" {
+			t.Errorf("cut %d: %s", cut, diff)
+		}
+	}
+}
+
+func TestReadFsMghHeaderOnlyReadsHeader(t *testing.T) {
+	// Write an MGZ file that contains only the header of brain.mgz, followed by garbage instead of valid data.
+	// Reading the header must work, because it does not touch the data. Reading the full file must fail.
+	hdr, _ := ReadFsMghHeader("testdata/brain.mgz", "auto")
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	binary.Write(gzipWriter, binary.BigEndian, &hdr)
+	gzipWriter.Write([]byte{1, 2, 3})
+	gzipWriter.Close()
+
+	mgzFile := filepath.Join(t.TempDir(), "header_only.mgz")
+	os.WriteFile(mgzFile, buf.Bytes(), 0644)
+
+	hdr_reread, err := ReadFsMghHeader(mgzFile, "auto")
+	if err != nil {
+		t.Errorf("ReadFsMghHeader failed: %v", err)
+	}
+	if hdr_reread != hdr {
+		t.Errorf("got header %v, wanted %v", hdr_reread, hdr)
+	}
+
+	_, err = ReadFsMgh(mgzFile, "auto")
+	if err == nil {
+		t.Errorf("expected error when reading truncated MGZ file, got nil")
+	}
+}
+
+func TestReadFsMghFromDetectsGzip(t *testing.T) {
+	file, err := os.Open("testdata/brain.mgz")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+
+	mgh, err := ReadFsMghFrom(file, "auto")
+	if err != nil {
+		t.Errorf("ReadFsMghFrom failed: %v", err)
+	}
+
+	var sum int = 0
+	for _, voxel_val := range mgh.Data.DataMriUchar {
+		sum += int(voxel_val)
+	}
+	var want int = 121035479 // known from external tests with standard software.
+	if sum != want {
+		t.Errorf("got MGH data sum=%d, wanted %d", sum, want)
+	}
+}
+
+func TestReadFsMghFromInvalidIsGzipped(t *testing.T) {
+	if _, err := ReadFsMghFrom(bytes.NewReader(make([]byte, 284)), "maybe"); err == nil {
+		t.Errorf("ReadFsMghFrom: expected error for invalid isGzipped value, got nil")
+	}
+	if _, err := ReadFsMghHeaderFrom(bytes.NewReader(make([]byte, 284)), "maybe"); err == nil {
+		t.Errorf("ReadFsMghHeaderFrom: expected error for invalid isGzipped value, got nil")
+	}
+	if _, err := NewMghFrameIterator(bytes.NewReader(make([]byte, 284)), "maybe"); err == nil {
+		t.Errorf("NewMghFrameIterator: expected error for invalid isGzipped value, got nil")
+	}
+}
+
+func TestWriteRereadMghInMemory(t *testing.T) {
+	hdr := MghHeader{MghVersion: 1, Dim1Length: 3, Dim2Length: 1, Dim3Length: 1, Dim4Length: 2, MghDataType: MRI_FLOAT}
+	data := MghData{DataMriFloat: []float32{0.5, 1.5, 2.5, 3.5, 4.5, 5.5}, MghDataType: MRI_FLOAT}
+	mgh := Mgh{Header: hdr, Data: data, Footer: MghFooter{HasScanParameters: true, TR: 2.0,
+		Tags: []FsTag{{TagType: TAG_CMDLINE, Data: []byte("mri_convert a.mgz b.mgz")}}}}
+
+	for _, compress := range []bool{true, false} {
+		var buf bytes.Buffer
+		if err := WriteFsMghTo(&buf, mgh, compress); err != nil {
+			t.Errorf("WriteFsMghTo failed with compress=%t: %v", compress, err)
+		}
+
+		hdr_reread, err := ReadFsMghHeaderFrom(bytes.NewReader(buf.Bytes()), "auto")
+		if err != nil {
+			t.Errorf("ReadFsMghHeaderFrom failed with compress=%t: %v", compress, err)
+		}
+		if hdr_reread != hdr {
+			t.Errorf("got header %v, wanted %v", hdr_reread, hdr)
+		}
+
+		mgh_reread, err := ReadFsMghFrom(&buf, "auto")
+		if err != nil {
+			t.Errorf("ReadFsMghFrom failed with compress=%t: %v", compress, err)
+		}
+		if diff := cmp.Diff(mgh, mgh_reread); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestReadFsMghUnsupportedDataTypes(t *testing.T) {
+	testCases := []struct {
+		dtCode  int32
+		wantMsg string
+	}{
+		{MRI_BITMAP, "MRI_BITMAP"},
+		{MRI_TENSOR, "MRI_TENSOR"},
+		{MRI_RGB, "MRI_RGB"},
+		{42, "Invalid MGH data type code 42"},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		hdr := MghHeader{MghVersion: 1, Dim1Length: 2, Dim2Length: 1, Dim3Length: 1, Dim4Length: 1, MghDataType: tc.dtCode}
+		binary.Write(&buf, binary.BigEndian, &hdr)
+		buf.Write(make([]byte, 64))
+
+		_, err := ReadFsMghFrom(bytes.NewReader(buf.Bytes()), "no")
+		var dtErr *UnsupportedMghDataTypeError
+		if !errors.As(err, &dtErr) {
+			t.Fatalf("expected UnsupportedMghDataTypeError for data type code %d, got %v", tc.dtCode, err)
+		}
+		if dtErr.Code != tc.dtCode {
+			t.Errorf("got error with code %d, wanted %d", dtErr.Code, tc.dtCode)
+		}
+		if !strings.Contains(err.Error(), tc.wantMsg) {
+			t.Errorf("got error message '%s', wanted it to contain '%s'", err.Error(), tc.wantMsg)
+		}
+	}
+}
+
+func TestReadFsMghInvalidDimensions(t *testing.T) {
+	dims := [][4]int32{
+		{50000, 50000, 50000, 50000}, // overflows
+		{65536, 65536, 65536, 65536}, // wraps to 0
+		{50000, 50000, 1, 1},         // exceeds the maximal number of values
+		{1000, 1000, 1000, 1},        // valid, but the data is missing
+		{2, -1, 1, 1},
+	}
+	for _, d := range dims {
+		var buf bytes.Buffer
+		hdr := MghHeader{MghVersion: 1, Dim1Length: d[0], Dim2Length: d[1], Dim3Length: d[2], Dim4Length: d[3], MghDataType: MRI_FLOAT}
+		binary.Write(&buf, binary.BigEndian, &hdr)
+		buf.Write(make([]byte, 64))
+
+		if _, err := ReadFsMghFrom(bytes.NewReader(buf.Bytes()), "no"); err == nil {
+			t.Errorf("expected error for dimensions %v, got nil", d)
+		}
+	}
+}
+
+func TestGetMghDataTypeCode(t *testing.T) {
+	if dtCode, err := getMghDataTypeCode("MRI_USHRT"); err != nil || dtCode != MRI_USHRT {
+		t.Errorf("got code %d and error %v for MRI_USHRT, wanted %d and nil", dtCode, err, MRI_USHRT)
+	}
+	if _, err := getMghDataTypeCode("MRI_TENSOR"); err == nil {
+		t.Errorf("expected error for unsupported data type MRI_TENSOR, got nil")
+	}
+	if _, err := getMghDataTypeCode("MRI_NOPE"); err == nil {
+		t.Errorf("expected error for invalid data type name, got nil")
+	}
+}
@@ -0,0 +1,185 @@
+package neuro
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// FsPatch models a FreeSurfer patch, a part of a surface with modified vertex coordinates, e.g., a flattened piece of cortex like '<subject>/surf/lh.occip.patch.flat'.
+//
+// A patch file contains only the vertices of the patch, not the faces. The faces are taken from the surface the patch was cut from, see ToMesh.
+// For flattened patches, the z coordinates are 0, so the x and y coordinates can be used directly for 2D flatmaps.
+type FsPatch struct {
+	Vertices      []float32 // The coordinates of the patch vertices, as a flat array of 3D coordinates like in Mesh, i.e. [x1, y1, z1, x2, y2, z2, ...]
+	VertexIndices []int32   // The index of each patch vertex in the original surface. The first vertex is 0.
+	IsBorder      []bool    // Whether each patch vertex is on the border of the patch.
+}
+
+// NumVertices returns the number of vertices in the patch.
+func (patch FsPatch) NumVertices() int {
+	return len(patch.VertexIndices)
+}
+
+// ToMesh creates a mesh from the patch, with the vertex coordinates of the patch and the faces of the surface the patch was cut from.
+//
+// Only the faces whose vertices are all part of the patch are used, and their vertex indices are changed to the indices of the patch vertices.
+//
+// Parameters:
+//   - surface: the surface the patch was cut from, e.g., '<subject>/surf/lh.orig' for a patch of the left hemisphere
+//
+// Returns:
+//   - Mesh: the mesh of the patch. Vertex i of the mesh is vertex VertexIndices[i] of the surface.
+//   - error: an error if the patch contains vertices that do not exist in the surface
+func (patch FsPatch) ToMesh(surface Mesh) (Mesh, error) {
+	numSurfaceVertices := int32(NumVertices(surface))
+	patchIndex := make(map[int32]int32, len(patch.VertexIndices))
+	for idx, vertexIndex := range patch.VertexIndices {
+		if vertexIndex < 0 || vertexIndex >= numSurfaceVertices {
+			return Mesh{}, fmt.Errorf("ToMesh: patch vertex %d has index %d in the surface, but the surface has only %d vertices.", idx, vertexIndex, numSurfaceVertices)
+		}
+		patchIndex[vertexIndex] = int32(idx)
+	}
+
+	mesh := Mesh{Vertices: patch.Vertices, Faces: []int32{}}
+	for f := 0; f+2 < len(surface.Faces); f += 3 {
+		v0, ok0 := patchIndex[surface.Faces[f]]
+		v1, ok1 := patchIndex[surface.Faces[f+1]]
+		v2, ok2 := patchIndex[surface.Faces[f+2]]
+		if ok0 && ok1 && ok2 {
+			mesh.Faces = append(mesh.Faces, v0, v1, v2)
+		}
+	}
+	return mesh, nil
+}
+
+// ReadFsPatch reads a file in FreeSurfer patch format, e.g., a flattened surface patch like '<subject>/surf/lh.occip.patch.flat'.
+//
+// The patch file contains the index in the original surface, the border flag and the coordinates of each patch vertex. Both the
+// current format and the old format, which stores the coordinates as int16 scaled by 100, are supported.
+//
+// Parameters:
+//   - filepath: path to the patch file
+//
+// Returns:
+//   - FsPatch: the patch
+//   - error: an error if one occurred
+func ReadFsPatch(filepath string) (FsPatch, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return FsPatch{}, fmt.Errorf("ReadFsPatch: could not open patch file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	patch, err := readFsPatch(file)
+	if err != nil {
+		return patch, fmt.Errorf("ReadFsPatch: failed to read patch file '%s': %w", filepath, err)
+	}
+	return patch, nil
+}
+
+// ReadFsPatchFS reads a file in FreeSurfer patch format from the file system fsys, see ReadFsPatch.
+//
+// Parameters:
+//   - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//   - name: the name of the patch file in fsys
+//
+// Returns:
+//   - FsPatch: the patch
+//   - error: an error if one occurred
+func ReadFsPatchFS(fsys fs.FS, name string) (FsPatch, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return FsPatch{}, fmt.Errorf("ReadFsPatchFS: could not open patch file '%s': %w", name, err)
+	}
+	defer file.Close()
+
+	patch, err := readFsPatch(file)
+	if err != nil {
+		return patch, fmt.Errorf("ReadFsPatchFS: failed to read patch file '%s': %w", name, err)
+	}
+	return patch, nil
+}
+
+// ReadFsPatchFrom reads data in FreeSurfer patch format from r, see ReadFsPatch.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File or a *bytes.Reader
+//
+// Returns:
+//   - FsPatch: the patch
+//   - error: an error if one occurred
+func ReadFsPatchFrom(r io.Reader) (FsPatch, error) {
+	return readFsPatch(r)
+}
+
+// readFsPatch reads data in FreeSurfer patch format from r.
+//
+// The current format starts with the int32 value -1 and the number of vertices, the old format only with the number of vertices.
+// For each vertex, an int32 value and the coordinates follow. The int32 value is the vertex index plus 1, and it is negated for border vertices.
+//
+// Parameters:
+//   - r: the reader
+//
+// Returns:
+//   - FsPatch: the patch
+//   - error: an error if one occurred
+func readFsPatch(r io.Reader) (FsPatch, error) {
+	endian := binary.BigEndian
+	var patch FsPatch
+	r = bufio.NewReader(r)
+
+	var version, numVertices int32
+	if err := binary.Read(r, endian, &version); err != nil {
+		return patch, fmt.Errorf("failed to read patch header: %w", err)
+	}
+	oldFormat := version != -1
+	if oldFormat {
+		numVertices = version
+	} else if err := binary.Read(r, endian, &numVertices); err != nil {
+		return patch, fmt.Errorf("failed to read number of patch vertices: %w", err)
+	}
+	if numVertices < 0 {
+		return patch, fmt.Errorf("invalid number of patch vertices %d.", numVertices)
+	}
+
+	if Verbosity > 0 {
+		fmt.Printf("readFsPatch: Reading %d patch vertices, old format: %t.\n", numVertices, oldFormat)
+	}
+
+	// Do not trust the vertex count for the allocation, it may be garbage in broken files.
+	for idx := int32(0); idx < numVertices; idx++ {
+		var vertexId int32
+		if err := binary.Read(r, endian, &vertexId); err != nil {
+			return FsPatch{}, fmt.Errorf("failed to read index of patch vertex %d: %w", idx, err)
+		}
+		if vertexId == 0 {
+			return FsPatch{}, fmt.Errorf("invalid index 0 of patch vertex %d, indices are stored starting at 1.", idx)
+		}
+
+		var coords [3]float32
+		if oldFormat {
+			var scaledCoords [3]int16
+			if err := binary.Read(r, endian, &scaledCoords); err != nil {
+				return FsPatch{}, fmt.Errorf("failed to read coordinates of patch vertex %d: %w", idx, err)
+			}
+			for dim, c := range scaledCoords {
+				coords[dim] = float32(c) / 100.0
+			}
+		} else if err := binary.Read(r, endian, &coords); err != nil {
+			return FsPatch{}, fmt.Errorf("failed to read coordinates of patch vertex %d: %w", idx, err)
+		}
+
+		isBorder := vertexId < 0
+		if isBorder {
+			vertexId = -vertexId
+		}
+		patch.VertexIndices = append(patch.VertexIndices, vertexId-1)
+		patch.IsBorder = append(patch.IsBorder, isBorder)
+		patch.Vertices = append(patch.Vertices, coords[:]...)
+	}
+	return patch, nil
+}
@@ -0,0 +1,78 @@
+package neuro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// getTestFsPatch returns a flat patch of the cube face with x = 1 of GenerateCube, with vertex 3 on the border.
+func getTestFsPatch() FsPatch {
+	return FsPatch{
+		Vertices:      []float32{1.0, 1.0, 0.0, -1.0, 1.0, 0.0, -1.0, -1.0, 0.0, 1.0, -1.0, 0.0},
+		VertexIndices: []int32{0, 2, 3, 1},
+		IsBorder:      []bool{false, false, true, false},
+	}
+}
+
+func TestReadFsPatchFromBothFormats(t *testing.T) {
+	want := getTestFsPatch()
+
+	var current bytes.Buffer
+	if err := WriteFsPatchTo(&current, want); err != nil {
+		t.Fatalf("WriteFsPatchTo failed: %v", err)
+	}
+
+	// The old format has no leading -1, and stores the coordinates as int16 values scaled by 100. Vertex 3 is on the border.
+	var old bytes.Buffer
+	binary.Write(&old, binary.BigEndian, int32(4))
+	binary.Write(&old, binary.BigEndian, []int16{0, 1, 100, 100, 0})
+	binary.Write(&old, binary.BigEndian, []int16{0, 3, -100, 100, 0})
+	binary.Write(&old, binary.BigEndian, []int16{-1, -4, -100, -100, 0})
+	binary.Write(&old, binary.BigEndian, []int16{0, 2, 100, -100, 0})
+
+	for name, data := range map[string][]byte{"current": current.Bytes(), "old": old.Bytes()} {
+		patch, err := ReadFsPatchFrom(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s format: ReadFsPatchFrom failed: %v", name, err)
+		}
+		if diff := cmp.Diff(want, patch); diff != "" {
+			t.Errorf("%s format: %s", name, diff)
+		}
+	}
+}
+
+func TestReadFsPatchFromInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFsPatchTo(&buf, getTestFsPatch()); err != nil {
+		t.Fatalf("WriteFsPatchTo failed: %v", err)
+	}
+	data := buf.Bytes()
+	if _, err := ReadFsPatchFrom(bytes.NewReader(data[:len(data)-2])); err == nil {
+		t.Errorf("expected error for truncated patch, got nil")
+	}
+	if _, err := ReadFsPatchFrom(bytes.NewReader([]byte{255, 255, 255, 255, 255, 255, 255, 0})); err == nil {
+		t.Errorf("expected error for negative number of patch vertices, got nil")
+	}
+}
+
+func TestFsPatchToMesh(t *testing.T) {
+	patch := getTestFsPatch()
+	mesh, err := patch.ToMesh(GenerateCube())
+	if err != nil {
+		t.Fatalf("ToMesh failed: %v", err)
+	}
+
+	// Only the 2 faces of the cube side with x = 1 are part of the patch, re-indexed to the patch vertices.
+	want := Mesh{Vertices: patch.Vertices, Faces: []int32{0, 1, 2, 2, 3, 0}}
+	if diff := cmp.Diff(want, mesh); diff != "" {
+		t.Error(diff)
+	}
+
+	patch.VertexIndices[0] = 8
+	if _, err := patch.ToMesh(GenerateCube()); err == nil {
+		t.Errorf("expected error for patch vertex that is not part of the surface, got nil")
+	}
+}
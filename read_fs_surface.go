@@ -5,24 +5,27 @@ package neuro
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"io/fs"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 )
 
-// Read a newline-terminated string from a bytes.Reader.
+// Read a newline-terminated string from a reader.
 //
 // Parameters:
-//  - r: a bytes.Reader
+//  - r: the reader
 //  - endian: the byte order, e.g. binary.BigEndian
 //  - do_strip_newline: if true, strip the newline character from the end of the string
 //
 // Returns:
 //  - string: the string
 //  - error: an error if one occurred
-func readNewlineTerminatedString(r *bytes.Reader, endian binary.ByteOrder, do_strip_newline bool) (string, error) {
+func readNewlineTerminatedString(r io.Reader, endian binary.ByteOrder, do_strip_newline bool) (string, error) {
 
 	//endian = binary.BigEndian // TODO: make this a parameter
 
@@ -44,10 +47,211 @@ func readNewlineTerminatedString(r *bytes.Reader, endian binary.ByteOrder, do_st
 	return line, nil
 }
 
+// VolumeGeometry describes the volume a FreeSurfer surface was created from, as stored in the footer of surface files.
+//
+// The surface coordinates are in the tkregister RAS space of this volume. Use SurfaceRasToScannerRas to map them to scanner RAS coordinates.
+type VolumeGeometry struct {
+	Valid     bool       // Whether the geometry is valid. FreeSurfer writes the geometry block also if it is invalid.
+	Filename  string     // The path of the volume, e.g., '../mri/filled-pretess255.mgz'.
+	Dims      [3]int32   // The number of voxels in x, y and z direction.
+	VoxelSize [3]float64 // The size of the voxels in x, y and z direction (mm).
+	XRas      [3]float64 // The direction cosines of the x voxel axis (x_r, x_a, x_s), like the first triple of the Mdc field of MghHeader.
+	YRas      [3]float64 // The direction cosines of the y voxel axis.
+	ZRas      [3]float64 // The direction cosines of the z voxel axis.
+	CRas      [3]float64 // The scanner RAS coordinates of the volume center ('c_ras'), like the Pxyz_c field of MghHeader.
+}
+
+// MghHeader returns an MghHeader with the geometry of the volume, e.g., to compute its vox2ras matrices with the methods of MghHeader.
+//
+// Returns:
+//  - MghHeader: the header, with the data type MRI_UCHAR and one frame
+func (vg VolumeGeometry) MghHeader() MghHeader {
+	hdr := MghHeader{MghVersion: 1, Dim1Length: vg.Dims[0], Dim2Length: vg.Dims[1], Dim3Length: vg.Dims[2], Dim4Length: 1, MghDataType: MRI_UCHAR}
+	if vg.Valid {
+		hdr.RasGoodFlag = 1
+	}
+	hdr.XSize, hdr.YSize, hdr.ZSize = float32(vg.VoxelSize[0]), float32(vg.VoxelSize[1]), float32(vg.VoxelSize[2])
+	for idx, axis := range [][3]float64{vg.XRas, vg.YRas, vg.ZRas} {
+		for dim := 0; dim < 3; dim++ {
+			hdr.Mdc[idx*3+dim] = float32(axis[dim])
+		}
+	}
+	for dim := 0; dim < 3; dim++ {
+		hdr.Pxyz_c[dim] = float32(vg.CRas[dim])
+	}
+	return hdr
+}
+
+// SurfaceRasToScannerRas computes the matrix that maps surface (tkregister) RAS coordinates to scanner RAS coordinates.
+//
+// For conformed volumes, this is a translation by CRas. Apply it to the vertex coordinates of a surface to get them into the space of the
+// original scan, e.g., to overlay them on the raw MRI data in other software.
+//
+// Returns:
+//  - [4][4]float64: the 4x4 matrix, in row-major order (the first index is the row).
+//  - error: an error if the geometry is not valid, or its matrices are singular
+func (vg VolumeGeometry) SurfaceRasToScannerRas() ([4][4]float64, error) {
+	if !vg.Valid {
+		return [4][4]float64{}, fmt.Errorf("SurfaceRasToScannerRas: the volume geometry is not valid.")
+	}
+	hdr := vg.MghHeader()
+	tkrRas2Vox, err := hdr.TkrRas2Vox()
+	if err != nil {
+		return [4][4]float64{}, fmt.Errorf("SurfaceRasToScannerRas: %w", err)
+	}
+	vox2ras := hdr.Vox2Ras()
+
+	var m [4][4]float64
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			for k := 0; k < 4; k++ {
+				m[row][col] += vox2ras[row][k] * tkrRas2Vox[k][col]
+			}
+		}
+	}
+	return m, nil
+}
+
+// SurfaceMetadata holds the information of a FreeSurfer surface file that is not part of the mesh: the header lines and the footer.
+//
+// Read it with ReadFsSurfaceWithMetadata, and pass it to WriteFsSurfaceWithMetadata to write a surface with the same header and footer.
+type SurfaceMetadata struct {
+	CreatedLine       string         // The first line of the header, e.g., 'created by bert on Tue Mar  1 12:00:00 2022'. Without the newline.
+	CommentLine       string         // The second line of the header. FreeSurfer writes an empty line here. Without the newline.
+	UseRealRAS        bool           // Whether the vertex coordinates are in scanner RAS space instead of tkregister RAS space. FreeSurfer surfaces use tkregister space.
+	HasVolumeGeometry bool           // Whether the footer contains the volume geometry. If false, VolumeGeometry is empty.
+	VolumeGeometry    VolumeGeometry // The geometry of the volume the surface was created from.
+	Tags              []FsTag        // The other tagged data blocks of the footer, e.g., the command lines. See the TAG_* constants for tag types.
+}
+
+// CommandLines returns the command lines stored in the footer, i.e., the data of all tags of type TAG_CMDLINE.
+//
+// Returns:
+//  - []string: the command lines, in the order in which they appear in the file
+func (metadata SurfaceMetadata) CommandLines() []string {
+	cmdLines := make([]string, 0)
+	for _, tag := range metadata.Tags {
+		if tag.TagType == TAG_CMDLINE {
+			cmdLines = append(cmdLines, tag.String())
+		}
+	}
+	return cmdLines
+}
+
+// parseVolumeGeometryValues parses the whitespace-separated values of a line of the volume geometry block.
+func parseVolumeGeometryValues(key string, value string) ([3]float64, error) {
+	var values [3]float64
+	fields := strings.Fields(value)
+	if len(fields) != 3 {
+		return values, fmt.Errorf("volume geometry field '%s' has %d values, expected 3.", key, len(fields))
+	}
+	for idx, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return values, fmt.Errorf("invalid value '%s' of volume geometry field '%s': %w", field, key, err)
+		}
+		values[idx] = v
+	}
+	return values, nil
+}
+
+// readVolumeGeometry reads the text block with the volume geometry from the footer of a surface file, which consists of 8 lines like 'cras   = 5.4 18.0 0.0'.
+func readVolumeGeometry(r *bufio.Reader) (VolumeGeometry, error) {
+	var vg VolumeGeometry
+	for lineIdx := 0; lineIdx < 8; lineIdx++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return vg, fmt.Errorf("failed to read line %d of volume geometry: %w", lineIdx+1, err)
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return vg, fmt.Errorf("invalid line %d of volume geometry: '%s'.", lineIdx+1, strings.TrimSpace(line))
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		var values [3]float64
+		switch key {
+		case "valid":
+			value, _, _ = strings.Cut(value, "#")
+			vg.Valid = strings.TrimSpace(value) == "1"
+			continue
+		case "filename":
+			vg.Filename = value
+			continue
+		}
+		values, err = parseVolumeGeometryValues(key, value)
+		if err != nil {
+			return vg, err
+		}
+		switch key {
+		case "volume":
+			vg.Dims = [3]int32{int32(values[0]), int32(values[1]), int32(values[2])}
+		case "voxelsize":
+			vg.VoxelSize = values
+		case "xras":
+			vg.XRas = values
+		case "yras":
+			vg.YRas = values
+		case "zras":
+			vg.ZRas = values
+		case "cras":
+			vg.CRas = values
+		default:
+			return vg, fmt.Errorf("unknown volume geometry field '%s'.", key)
+		}
+	}
+	return vg, nil
+}
+
+// readFsSurfaceFooter reads the optional footer of a surface file, which follows the faces, into metadata.
+//
+// The footer consists of tags. The old tags TAG_OLD_USEREALRAS and TAG_OLD_SURF_GEOM have no length field, they are followed by
+// an int32 flag and the volume geometry text block, respectively. All other tags are read like the tags of MGH files.
+func readFsSurfaceFooter(r *bufio.Reader, metadata *SurfaceMetadata) error {
+	endian := binary.BigEndian
+	for tagIndex := 0; ; tagIndex++ {
+		var tagType int32
+		if err := binary.Read(r, endian, &tagType); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read tag type of footer tag %d: %w", tagIndex, err)
+		}
+
+		switch tagType {
+		case 0:
+			return nil
+		case TAG_OLD_USEREALRAS:
+			var useRealRAS int32
+			if err := binary.Read(r, endian, &useRealRAS); err != nil {
+				return fmt.Errorf("failed to read useRealRAS flag: %w", err)
+			}
+			metadata.UseRealRAS = useRealRAS != 0
+		case TAG_OLD_SURF_GEOM:
+			vg, err := readVolumeGeometry(r)
+			if err != nil {
+				return err
+			}
+			metadata.HasVolumeGeometry = true
+			metadata.VolumeGeometry = vg
+		case TAG_OLD_COLORTABLE:
+			return fmt.Errorf("footer tag %d has type TAG_OLD_COLORTABLE, which is not supported in surface files.", tagIndex)
+		default:
+			tag, err := readFsTagData(r, tagType, tagIndex)
+			if err != nil {
+				return err
+			}
+			metadata.Tags = append(metadata.Tags, tag)
+		}
+	}
+}
 
 // ReadFsSurface reads a FreeSurfer surface file and returns a Mesh struct.
 //
-// A surface file is a binary file containing the reconstructed surface of a brain hemisphere.
+// A surface file is a binary file containing the reconstructed surface of a brain hemisphere. Legacy quadrangle surfaces are
+// also supported, their quadrangles are split into two triangles each. The oldest quadrangle format without magic bytes (version 0,
+// with per-vertex face lists) is not supported, because it cannot be told apart from invalid files.
 //
 // Parameters:
 //  - filepath: path to the FreeSurfer mesh file, e.g. '<subject>/surf/lh.white'
@@ -57,37 +261,202 @@ func readNewlineTerminatedString(r *bytes.Reader, endian binary.ByteOrder, do_st
 //  - error: an error if one occurred
 func ReadFsSurface(filepath string) (Mesh, error) {
 
-	endian := binary.BigEndian
-	surface := Mesh{}
-
 	if _, err := os.Stat(filepath); err != nil {
 		err := fmt.Errorf("Could not stat surface file '%s': %s\n", filepath, err)
-		return surface, err
+		return Mesh{}, err
+	}
+
+	file, err := os.Open(filepath)
+	if err != nil {
+		err := fmt.Errorf("Could not open surface file '%s': %s\n", filepath, err)
+		return Mesh{}, err
 	}
+	defer file.Close()
+
+	return ReadFsSurfaceFrom(file)
+}
+
+// ReadFsSurfaceFS reads a FreeSurfer surface file from the file system fsys and returns a Mesh struct.
+//
+// This allows reading surfaces from zip archives (see archive/zip), embedded files (see embed.FS) and other fs.FS implementations.
+//
+// Parameters:
+//  - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//  - name: the name of the surface file in fsys, e.g. '<subject>/surf/lh.white'
+//
+// Returns:
+//  - Mesh: a Mesh struct containing the mesh data
+//  - error: an error if one occurred
+func ReadFsSurfaceFS(fsys fs.FS, name string) (Mesh, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		err := fmt.Errorf("Could not open surface file '%s': %s\n", name, err)
+		return Mesh{}, err
+	}
+	defer file.Close()
+
+	return ReadFsSurfaceFrom(file)
+}
+
+// ReadFsSurfaceFrom reads a FreeSurfer surface from r and returns a Mesh struct.
+//
+// Parameters:
+//  - r: the reader, e.g., an *os.File, a *bytes.Reader or an http.Response body
+//
+// Returns:
+//  - Mesh: a Mesh struct containing the mesh data
+//  - error: an error if one occurred
+func ReadFsSurfaceFrom(r io.Reader) (Mesh, error) {
+	surface, _, err := readFsSurfaceFrom(r)
+	return surface, err
+}
 
+// ReadFsSurfaceWithMetadata reads a FreeSurfer surface file and returns a Mesh struct and the metadata from the file header.
+//
+// Use this instead of ReadFsSurface if you want to write the surface back with WriteFsSurfaceWithMetadata, e.g., after modifying the vertex coordinates.
+//
+// Parameters:
+//  - filepath: path to the FreeSurfer mesh file, e.g. '<subject>/surf/lh.white'
+//
+// Returns:
+//  - Mesh: a Mesh struct containing the mesh data
+//  - SurfaceMetadata: the metadata from the file header
+//  - error: an error if one occurred
+func ReadFsSurfaceWithMetadata(filepath string) (Mesh, SurfaceMetadata, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
-   		panic(err)
+		return Mesh{}, SurfaceMetadata{}, fmt.Errorf("ReadFsSurfaceWithMetadata: could not open surface file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	return readFsSurfaceFrom(file)
+}
+
+// ReadFsSurfaceWithMetadataFS reads a FreeSurfer surface file from the file system fsys, see ReadFsSurfaceWithMetadata.
+//
+// Parameters:
+//  - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//  - name: the name of the surface file in fsys, e.g. '<subject>/surf/lh.white'
+//
+// Returns:
+//  - Mesh: a Mesh struct containing the mesh data
+//  - SurfaceMetadata: the metadata from the file header
+//  - error: an error if one occurred
+func ReadFsSurfaceWithMetadataFS(fsys fs.FS, name string) (Mesh, SurfaceMetadata, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return Mesh{}, SurfaceMetadata{}, fmt.Errorf("ReadFsSurfaceWithMetadataFS: could not open surface file '%s': %w", name, err)
 	}
 	defer file.Close()
 
-	// Get the file size
-	stat, err := file.Stat()
+	return readFsSurfaceFrom(file)
+}
+
+// ReadFsSurfaceWithMetadataFrom reads a FreeSurfer surface from r, see ReadFsSurfaceWithMetadata.
+//
+// Parameters:
+//  - r: the reader, e.g., an *os.File or a *bytes.Reader
+//
+// Returns:
+//  - Mesh: a Mesh struct containing the mesh data
+//  - SurfaceMetadata: the metadata from the file header
+//  - error: an error if one occurred
+func ReadFsSurfaceWithMetadataFrom(r io.Reader) (Mesh, SurfaceMetadata, error) {
+	return readFsSurfaceFrom(r)
+}
+
+// readFsQuadSurface reads a legacy FreeSurfer quadrangle surface from r, after the magic bytes, and splits each quadrangle into two triangles.
+//
+// Quadrangle surfaces store the number of vertices and quadrangles as 3-byte integers, followed by the vertex coordinates and
+// 4 vertex indices per quadrangle, also as 3-byte integers. The old format (magic bytes 255 255 255) stores the coordinates as
+// int16 values scaled by 100, the new format (magic bytes 255 255 253) as float32 values. The quadrangles are split along one of their diagonals like
+// FreeSurfer's MRISread does it, see getFsQuadSplit.
+//
+// Parameters:
+//  - r: the reader, positioned after the magic bytes
+//  - int16Coords: whether the file is in the old format with int16 coordinates
+//
+// Returns:
+//  - Mesh: the triangulated mesh
+//  - error: an error if one occurred
+func readFsQuadSurface(r io.Reader, int16Coords bool) (Mesh, error) {
+	endian := binary.BigEndian
+	surface := Mesh{}
+
+	numVerts, err := readInt3(r)
 	if err != nil {
-	   fmt.Println(err)
-	   return surface, err
+		return surface, fmt.Errorf("failed to read number of vertices of quadrangle surface: %w", err)
+	}
+	numQuads, err := readInt3(r)
+	if err != nil {
+		return surface, fmt.Errorf("failed to read number of quadrangles of quadrangle surface: %w", err)
+	}
+
+	if Verbosity > 0 {
+		fmt.Printf("Quadrangle surface with %d vertices and %d quadrangles, int16 coordinates: %t.\n", numVerts, numQuads, int16Coords)
 	}
 
-	// Read the file into a byte slice
-	bs := make([]byte, stat.Size())
-	_, err = bufio.NewReader(file).Read(bs)
-	if err != nil && err != io.EOF {
-	   fmt.Println(err)
-	   return surface, err
+	surface.Vertices = make([]float32, numVerts*3)
+	if int16Coords {
+		coords := make([]int16, numVerts*3)
+		if err := binary.Read(r, endian, &coords); err != nil {
+			return surface, fmt.Errorf("failed to read vertices of quadrangle surface: %w", err)
+		}
+		for idx, c := range coords {
+			surface.Vertices[idx] = float32(c) / 100.0
+		}
+	} else {
+		if err := binary.Read(r, endian, &surface.Vertices); err != nil {
+			return surface, fmt.Errorf("failed to read vertices of quadrangle surface: %w", err)
+		}
+	}
+
+	quads, err := readInt3Slice(r, int(numQuads)*4)
+	if err != nil {
+		return surface, fmt.Errorf("failed to read quadrangles of quadrangle surface: %w", err)
 	}
 
-	// Read the byte slice
-	r := bytes.NewReader(bs)
+	surface.Faces = make([]int32, 0, numQuads*6)
+	for q := 0; q < int(numQuads); q++ {
+		quad := quads[q*4 : q*4+4]
+		for _, vertexIndex := range quad {
+			if vertexIndex >= numVerts {
+				return Mesh{}, fmt.Errorf("quadrangle %d references vertex %d, but the surface has only %d vertices.", q, vertexIndex, numVerts)
+			}
+		}
+		if getFsQuadSplit(quad[0], quad[1])%2 == 0 {
+			surface.Faces = append(surface.Faces, quad[0], quad[1], quad[3], quad[2], quad[3], quad[1])
+		} else {
+			surface.Faces = append(surface.Faces, quad[0], quad[1], quad[2], quad[0], quad[2], quad[3])
+		}
+	}
+	return surface, nil
+}
+
+// getFsQuadSplit computes the value used by FreeSurfer to choose the diagonal along which a quadrangle is split into two triangles.
+//
+// This is the WHICH_FACE_SPLIT macro of FreeSurfer, nint(sqrt(1.9*v0) + sqrt(3.5*v1)). If the value is even, the quadrangle (v0, v1, v2, v3)
+// is split into the triangles (v0, v1, v3) and (v2, v3, v1), otherwise into (v0, v1, v2) and (v0, v2, v3).
+//
+// Parameters:
+//  - v0: the index of the first vertex of the quadrangle
+//  - v1: the index of the second vertex of the quadrangle
+//
+// Returns:
+//  - int32: the split value
+func getFsQuadSplit(v0 int32, v1 int32) int32 {
+	return int32(math.Floor(math.Sqrt(1.9*float64(v0)) + math.Sqrt(3.5*float64(v1)) + 0.5))
+}
+
+// readFsSurfaceFrom reads a FreeSurfer surface from r and returns the mesh and the metadata from the file header.
+func readFsSurfaceFrom(r io.Reader) (Mesh, SurfaceMetadata, error) {
+
+	endian := binary.BigEndian
+	surface := Mesh{}
+	var metadata SurfaceMetadata
+
+	br := bufio.NewReader(r)
+	r = br
 
 	type header_part1 struct {
 		MagicB1 uint8
@@ -97,25 +466,37 @@ func ReadFsSurface(filepath string) (Mesh, error) {
 
 	hdr1 := header_part1{}
 
-
 	if err := binary.Read(r, endian, &hdr1); err != nil {
 		fmt.Println("binary.Read failed on first part of fs surface header:", err)
-		return surface, err
+		return surface, metadata, err
 	}
 
-
-	if ! (hdr1.MagicB1 == 255 && hdr1.MagicB2 == 255 && hdr1.MagicB3 == 254) {
-		fmt.Println("Error: surface magic bytes are not 255 255 254, this is not a FreeSurfer surface file. Provide a recon-all output file like '<subject>/surf/lh.white'.")
-		return surface, err
+	if hdr1.MagicB1 == 255 && hdr1.MagicB2 == 255 && (hdr1.MagicB3 == 255 || hdr1.MagicB3 == 253) {
+		// Legacy quadrangle surface. These files have no header lines and no footer.
+		surface, err := readFsQuadSurface(br, hdr1.MagicB3 == 255)
+		return surface, metadata, err
 	}
 
+	if !(hdr1.MagicB1 == 255 && hdr1.MagicB2 == 255 && hdr1.MagicB3 == 254) {
+		err := fmt.Errorf("surface magic bytes are %d %d %d instead of 255 255 254 (or 255 255 255 and 255 255 253 for quadrangle surfaces), this is not a FreeSurfer surface file. Provide a recon-all output file like '<subject>/surf/lh.white'.", hdr1.MagicB1, hdr1.MagicB2, hdr1.MagicB3)
+		return surface, metadata, err
+	}
 
 	if Verbosity > 0 {
 		fmt.Printf("Surface header magic bytes: %d %d %d.\n", hdr1.MagicB1, hdr1.MagicB2, hdr1.MagicB3)
 	}
 
-	createdLine, err := readNewlineTerminatedString(r, endian, true);
-    commentLine, err := readNewlineTerminatedString(r, endian, true);
+	createdLine, err := readNewlineTerminatedString(r, endian, true)
+	if err != nil {
+		return surface, metadata, err
+	}
+	commentLine, err := readNewlineTerminatedString(r, endian, true)
+	if err != nil {
+		return surface, metadata, err
+	}
+
+	metadata.CreatedLine = createdLine
+	metadata.CommentLine = commentLine
 
 	if Verbosity > 0 {
 		fmt.Printf("createdLine: '%s'\n", createdLine)
@@ -131,7 +512,7 @@ func ReadFsSurface(filepath string) (Mesh, error) {
 
 	if err := binary.Read(r, endian, &hdr2); err != nil {
 		fmt.Println("binary.Read failed on second part of fs surface header:", err)
-		return surface, err
+		return surface, metadata, err
 	}
 
 	if Verbosity > 0 {
@@ -140,19 +521,23 @@ func ReadFsSurface(filepath string) (Mesh, error) {
 	}
 
 	// read mesh data
-	surface.Vertices = make([]float32, hdr2.NumVerts * 3) // x,y,z coordinates for each vertex
-	surface.Faces = make([]int32, hdr2.NumFaces * 3)  // vertex 1, 2, 3 for each face
+	surface.Vertices = make([]float32, hdr2.NumVerts*3) // x,y,z coordinates for each vertex
+	surface.Faces = make([]int32, hdr2.NumFaces*3)      // vertex 1, 2, 3 for each face
 
 	// read vertices
 	if err := binary.Read(r, endian, &surface.Vertices); err != nil {
 		fmt.Println("binary.Read failed on mesh vertices array:", err)
-		return surface, err
+		return surface, metadata, err
 	}
 
 	// read faces
 	if err := binary.Read(r, endian, &surface.Faces); err != nil {
 		fmt.Println("binary.Read failed on mesh faces array:", err)
-		return surface, err
+		return surface, metadata, err
+	}
+
+	if err := readFsSurfaceFooter(br, &metadata); err != nil {
+		return surface, metadata, fmt.Errorf("failed to read surface footer: %w", err)
 	}
 
 	if Verbosity >= 2 {
@@ -175,5 +560,5 @@ func ReadFsSurface(filepath string) (Mesh, error) {
 		}
 	}
 
-	return surface, nil
-}
\ No newline at end of file
+	return surface, metadata, nil
+}
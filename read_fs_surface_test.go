@@ -3,8 +3,12 @@ package neuro
 // https://pkg.go.dev/testing
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestReadFsSurface(t *testing.T){
@@ -37,3 +41,181 @@ func ExampleReadFsSurface() {
 	fmt.Printf("Read mesh with %d vertices and %d faces from surface file '%s'.\n", len(mesh.Vertices)/3, len(mesh.Faces)/3, surfaceFile)
 	// Output: Read mesh with 149244 vertices and 298484 faces from surface file 'testdata/lh.white'.
 }
+
+// getFsSurfaceBytes encodes a mesh in FreeSurfer surface format, for testing the readers without surface files.
+func getFsSurfaceBytes(mesh Mesh) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{255, 255, 254})
+	buf.WriteString("created by neuro tests\n\n")
+	binary.Write(&buf, binary.BigEndian, int32(len(mesh.Vertices)/3))
+	binary.Write(&buf, binary.BigEndian, int32(len(mesh.Faces)/3))
+	binary.Write(&buf, binary.BigEndian, mesh.Vertices)
+	binary.Write(&buf, binary.BigEndian, mesh.Faces)
+	return buf.Bytes()
+}
+
+func TestReadFsSurfaceFrom(t *testing.T) {
+	cube := GenerateCube()
+
+	mesh, err := ReadFsSurfaceFrom(bytes.NewReader(getFsSurfaceBytes(cube)))
+	if err != nil {
+		t.Errorf("ReadFsSurfaceFrom failed: %v", err)
+	}
+
+	if diff := cmp.Diff(cube, mesh); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReadFsSurfaceFromInvalidMagic(t *testing.T) {
+	// The magic bytes 255 255 255 and 255 255 253 are used by quadrangle surfaces, so use others.
+	_, err := ReadFsSurfaceFrom(bytes.NewReader([]byte{255, 255, 252, 0, 0, 0, 0}))
+	if err == nil {
+		t.Errorf("expected error for invalid surface magic bytes, got nil")
+	}
+}
+
+// testFsSurfaceVolumeGeometry is the volume geometry block of a surface file, as written by FreeSurfer for a conformed volume.
+const testFsSurfaceVolumeGeometry = "valid = 1  # volume info valid\n" +
+	"filename = ../mri/filled-pretess255.mgz\n" +
+	"volume = 256 256 256\n" +
+	"voxelsize = 1.000000000000000e+00 1.000000000000000e+00 1.000000000000000e+00\n" +
+	"xras   = -1.000000000000000e+00 0.000000000000000e+00 0.000000000000000e+00\n" +
+	"yras   = 0.000000000000000e+00 0.000000000000000e+00 -1.000000000000000e+00\n" +
+	"zras   = 0.000000000000000e+00 1.000000000000000e+00 0.000000000000000e+00\n" +
+	"cras   = 5.400000000000000e+00 1.800000000000000e+01 -2.500000000000000e-01\n"
+
+// getFsSurfaceFooterBytes encodes a surface footer like FreeSurfer writes it: the useRealRAS flag, the volume geometry and a command line tag.
+func getFsSurfaceFooterBytes(cmdLine string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []int32{TAG_OLD_USEREALRAS, 0, TAG_OLD_SURF_GEOM})
+	buf.WriteString(testFsSurfaceVolumeGeometry)
+	binary.Write(&buf, binary.BigEndian, TAG_CMDLINE)
+	binary.Write(&buf, binary.BigEndian, int64(len(cmdLine)+1))
+	buf.WriteString(cmdLine + "\x00")
+	return buf.Bytes()
+}
+
+func TestReadFsSurfaceWithMetadataFooter(t *testing.T) {
+	cube := GenerateCube()
+	surfaceData := append(getFsSurfaceBytes(cube), getFsSurfaceFooterBytes("mris_make_surfaces bert lh")...)
+
+	mesh, metadata, err := ReadFsSurfaceWithMetadataFrom(bytes.NewReader(surfaceData))
+	if err != nil {
+		t.Fatalf("ReadFsSurfaceWithMetadataFrom failed: %v", err)
+	}
+	if diff := cmp.Diff(cube, mesh); diff != "" {
+		t.Error(diff)
+	}
+
+	wantGeometry := VolumeGeometry{
+		Valid:     true,
+		Filename:  "../mri/filled-pretess255.mgz",
+		Dims:      [3]int32{256, 256, 256},
+		VoxelSize: [3]float64{1, 1, 1},
+		XRas:      [3]float64{-1, 0, 0},
+		YRas:      [3]float64{0, 0, -1},
+		ZRas:      [3]float64{0, 1, 0},
+		CRas:      [3]float64{5.4, 18, -0.25},
+	}
+	if !metadata.HasVolumeGeometry || metadata.UseRealRAS {
+		t.Errorf("got HasVolumeGeometry=%t and UseRealRAS=%t, wanted true and false", metadata.HasVolumeGeometry, metadata.UseRealRAS)
+	}
+	if diff := cmp.Diff(wantGeometry, metadata.VolumeGeometry); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]string{"mris_make_surfaces bert lh"}, metadata.CommandLines()); diff != "" {
+		t.Error(diff)
+	}
+
+	// ReadFsSurfaceFrom ignores the footer.
+	if _, err := ReadFsSurfaceFrom(bytes.NewReader(surfaceData)); err != nil {
+		t.Errorf("ReadFsSurfaceFrom failed for surface with footer: %v", err)
+	}
+}
+
+func TestReadFsSurfaceWithMetadataInvalidFooter(t *testing.T) {
+	var footer bytes.Buffer
+	binary.Write(&footer, binary.BigEndian, TAG_OLD_SURF_GEOM)
+	footer.WriteString("valid = 1  # volume info valid\nfilename = lh.orig\nvolume = 256 256\n")
+
+	surfaceData := append(getFsSurfaceBytes(GenerateCube()), footer.Bytes()...)
+	if _, _, err := ReadFsSurfaceWithMetadataFrom(bytes.NewReader(surfaceData)); err == nil {
+		t.Errorf("expected error for invalid volume geometry, got nil")
+	}
+}
+
+func TestVolumeGeometrySurfaceRasToScannerRas(t *testing.T) {
+	cube := GenerateCube()
+	surfaceData := append(getFsSurfaceBytes(cube), getFsSurfaceFooterBytes("")...)
+	_, metadata, err := ReadFsSurfaceWithMetadataFrom(bytes.NewReader(surfaceData))
+	if err != nil {
+		t.Fatalf("ReadFsSurfaceWithMetadataFrom failed: %v", err)
+	}
+
+	// For a conformed volume, surface RAS and scanner RAS differ by c_ras.
+	got, err := metadata.VolumeGeometry.SurfaceRasToScannerRas()
+	if err != nil {
+		t.Fatalf("SurfaceRasToScannerRas failed: %v", err)
+	}
+	want := [4][4]float64{{1, 0, 0, 5.4}, {0, 1, 0, 18}, {0, 0, 1, -0.25}, {0, 0, 0, 1}}
+	checkMatrixAlmostEqual(t, "SurfaceRasToScannerRas", got, want)
+
+	if _, err := (VolumeGeometry{}).SurfaceRasToScannerRas(); err == nil {
+		t.Errorf("expected error for invalid volume geometry, got nil")
+	}
+}
+
+// getFsQuadSurfaceBytes encodes a planar grid of 2 by 3 vertices with 2 quadrangles in FreeSurfer quadrangle surface format.
+func getFsQuadSurfaceBytes(int16Coords bool) []byte {
+	var buf bytes.Buffer
+	magic := byte(253)
+	if int16Coords {
+		magic = 255
+	}
+	buf.Write([]byte{255, 255, magic})
+	buf.Write([]byte{0, 0, 6, 0, 0, 2})
+	coords := []float32{0, 0, 0, 1.5, 0, 0, 3, 0, 0, 0, 1.25, 0, 1.5, 1.25, 0, 3, 1.25, -0.5}
+	if int16Coords {
+		for _, c := range coords {
+			binary.Write(&buf, binary.BigEndian, int16(c*100))
+		}
+	} else {
+		binary.Write(&buf, binary.BigEndian, coords)
+	}
+	for _, vertexIndex := range []byte{1, 4, 3, 0, 1, 2, 5, 4} {
+		buf.Write([]byte{0, 0, vertexIndex})
+	}
+	return buf.Bytes()
+}
+
+func TestReadFsSurfaceFromQuad(t *testing.T) {
+	want := Mesh{
+		Vertices: []float32{0, 0, 0, 1.5, 0, 0, 3, 0, 0, 0, 1.25, 0, 1.5, 1.25, 0, 3, 1.25, -0.5},
+		// The split value of the first quadrangle is nint(sqrt(1.9*1) + sqrt(3.5*4)) = 5, the one of the second quadrangle
+		// is nint(sqrt(1.9*1) + sqrt(3.5*2)) = 4, so they are split along different diagonals.
+		Faces: []int32{1, 4, 3, 1, 3, 0, 1, 2, 4, 5, 4, 2},
+	}
+	for _, int16Coords := range []bool{true, false} {
+		mesh, err := ReadFsSurfaceFrom(bytes.NewReader(getFsQuadSurfaceBytes(int16Coords)))
+		if err != nil {
+			t.Fatalf("int16Coords=%t: ReadFsSurfaceFrom failed: %v", int16Coords, err)
+		}
+		if diff := cmp.Diff(want, mesh); diff != "" {
+			t.Errorf("int16Coords=%t: %s", int16Coords, diff)
+		}
+	}
+}
+
+func TestReadFsSurfaceFromQuadInvalid(t *testing.T) {
+	data := getFsQuadSurfaceBytes(false)
+	data[len(data)-1] = 6 // There is no vertex 6.
+	if _, err := ReadFsSurfaceFrom(bytes.NewReader(data)); err == nil {
+		t.Errorf("expected error for quadrangle with invalid vertex index, got nil")
+	}
+
+	data = getFsQuadSurfaceBytes(true)
+	if _, err := ReadFsSurfaceFrom(bytes.NewReader(data[:len(data)-4])); err == nil {
+		t.Errorf("expected error for truncated quadrangle surface, got nil")
+	}
+}
@@ -0,0 +1,123 @@
+package neuro
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// ReadFsWeight reads a file in FreeSurfer weight format ('.w' files) and returns dense per-vertex data.
+//
+// Weight files are sparse: they store pairs of vertex index and value, for a subset of the vertices only. They are written by
+// older FreeSurfer tools like paint. The values of vertices that are not in the file are set to 0.
+//
+// Parameters:
+//   - filepath: path to the weight file, e.g., 'lh.thickness.w'
+//   - numVertices: the number of vertices of the surface the data belongs to, i.e., the length of the returned slice
+//
+// Returns:
+//   - []float32: the per-vertex values, one value for each vertex of the surface
+//   - error: an error if one occurred, e.g., if the file contains a vertex index that is not less than numVertices
+func ReadFsWeight(filepath string, numVertices int) ([]float32, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFsWeight: could not open weight file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	data, err := readFsWeight(file, numVertices)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFsWeight: failed to read weight file '%s': %w", filepath, err)
+	}
+	return data, nil
+}
+
+// ReadFsWeightFS reads a file in FreeSurfer weight format from the file system fsys, see ReadFsWeight.
+//
+// Parameters:
+//   - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//   - name: the name of the weight file in fsys
+//   - numVertices: the number of vertices of the surface the data belongs to
+//
+// Returns:
+//   - []float32: the per-vertex values, one value for each vertex of the surface
+//   - error: an error if one occurred
+func ReadFsWeightFS(fsys fs.FS, name string, numVertices int) ([]float32, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFsWeightFS: could not open weight file '%s': %w", name, err)
+	}
+	defer file.Close()
+
+	data, err := readFsWeight(file, numVertices)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFsWeightFS: failed to read weight file '%s': %w", name, err)
+	}
+	return data, nil
+}
+
+// ReadFsWeightFrom reads data in FreeSurfer weight format from r, see ReadFsWeight.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File or a *bytes.Reader
+//   - numVertices: the number of vertices of the surface the data belongs to
+//
+// Returns:
+//   - []float32: the per-vertex values, one value for each vertex of the surface
+//   - error: an error if one occurred
+func ReadFsWeightFrom(r io.Reader, numVertices int) ([]float32, error) {
+	return readFsWeight(r, numVertices)
+}
+
+// readFsWeight reads data in FreeSurfer weight format from r.
+//
+// The format starts with an int16 latency value, which is ignored, and the number of entries as a 3-byte integer. For each entry,
+// the vertex index as a 3-byte integer and the value as a float32 follow.
+//
+// Parameters:
+//   - r: the reader
+//   - numVertices: the number of vertices of the surface the data belongs to
+//
+// Returns:
+//   - []float32: the per-vertex values, one value for each vertex of the surface
+//   - error: an error if one occurred
+func readFsWeight(r io.Reader, numVertices int) ([]float32, error) {
+	if numVertices < 0 {
+		return nil, fmt.Errorf("invalid number of vertices %d.", numVertices)
+	}
+	endian := binary.BigEndian
+	r = bufio.NewReader(r)
+
+	var latency int16
+	if err := binary.Read(r, endian, &latency); err != nil {
+		return nil, fmt.Errorf("failed to read latency: %w", err)
+	}
+	numEntries, err := readInt3(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read number of entries: %w", err)
+	}
+
+	if Verbosity > 0 {
+		fmt.Printf("readFsWeight: Reading %d entries for %d vertices, latency %d.\n", numEntries, numVertices, latency)
+	}
+
+	data := make([]float32, numVertices)
+	for idx := int32(0); idx < numEntries; idx++ {
+		vertexIndex, err := readInt3(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vertex index of entry %d: %w", idx, err)
+		}
+		var value float32
+		if err := binary.Read(r, endian, &value); err != nil {
+			return nil, fmt.Errorf("failed to read value of entry %d: %w", idx, err)
+		}
+		if int(vertexIndex) >= numVertices {
+			return nil, fmt.Errorf("entry %d has vertex index %d, but the surface has only %d vertices.", idx, vertexIndex, numVertices)
+		}
+		data[vertexIndex] = value
+	}
+	return data, nil
+}
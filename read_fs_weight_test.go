@@ -0,0 +1,46 @@
+package neuro
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// getFsWeightTestData returns the weight format encoding of dense data with 3 non-zero values for 70001 vertices, the last index needs all 3 bytes.
+func getFsWeightTestData(t *testing.T) []byte {
+	t.Helper()
+	data := make([]float32, 70001)
+	data[1], data[4], data[70000] = -1.0, 2.5, 0.75
+	var buf bytes.Buffer
+	if err := WriteFsWeightTo(&buf, data); err != nil {
+		t.Fatalf("WriteFsWeightTo failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadFsWeightFrom(t *testing.T) {
+	data, err := ReadFsWeightFrom(bytes.NewReader(getFsWeightTestData(t)), 70001)
+	if err != nil {
+		t.Fatalf("ReadFsWeightFrom failed: %v", err)
+	}
+	if len(data) != 70001 {
+		t.Fatalf("got %d values, wanted 70001", len(data))
+	}
+	if diff := cmp.Diff([]float32{0, -1.0, 0, 0, 2.5}, data[:5]); diff != "" {
+		t.Error(diff)
+	}
+	if data[70000] != 0.75 {
+		t.Errorf("got value %f for vertex 70000, wanted 0.75", data[70000])
+	}
+}
+
+func TestReadFsWeightFromInvalid(t *testing.T) {
+	weightData := getFsWeightTestData(t)
+	if _, err := ReadFsWeightFrom(bytes.NewReader(weightData), 70000); err == nil {
+		t.Errorf("expected error for vertex index out of range, got nil")
+	}
+	if _, err := ReadFsWeightFrom(bytes.NewReader(weightData[:len(weightData)-1]), 70001); err == nil {
+		t.Errorf("expected error for truncated weight data, got nil")
+	}
+}
@@ -0,0 +1,297 @@
+package neuro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Nifti1Header models the 348 byte header of a NIfTI-1 file.
+//
+// NIfTI is the most common file format for volumes in neuroimaging outside of FreeSurfer. The field names follow
+// the C struct nifti_1_header from the NIfTI-1 standard, see https://nifti.nimh.nih.gov/nifti-1/.
+type Nifti1Header struct {
+	SizeofHdr     int32      // size of the header, must be 348
+	DataTypeStr   [10]byte   // unused, from the ANALYZE 7.5 format
+	DbName        [18]byte   // unused, from the ANALYZE 7.5 format
+	Extents       int32      // unused, from the ANALYZE 7.5 format
+	SessionError  int16      // unused, from the ANALYZE 7.5 format
+	Regular       uint8      // unused, from the ANALYZE 7.5 format
+	DimInfo       uint8      // the frequency, phase and slice encoding directions
+	Dim           [8]int16   // the data dimensions. Dim[0] is the number of dimensions, Dim[1] to Dim[7] are the lengths of the dimensions.
+	IntentP1      float32    // first parameter of the intent
+	IntentP2      float32    // second parameter of the intent
+	IntentP3      float32    // third parameter of the intent
+	IntentCode    int16      // the NIFTI_INTENT_* code, describes what the data means
+	Datatype      int16      // the data type code of the voxel values, see NIFTI_TYPE_UINT8 and the other NIFTI_TYPE_* constants
+	Bitpix        int16      // number of bits per voxel value
+	SliceStart    int16      // first slice index
+	Pixdim        [8]float32 // the grid spacings. Pixdim[0] is qfac, the sign of the third axis for the qform, and Pixdim[1] to Pixdim[3] are the voxel sizes.
+	VoxOffset     float32    // offset of the voxel data in the file, in bytes
+	SclSlope      float32    // data scaling slope. If not 0, voxel values are SclSlope * stored value + SclInter.
+	SclInter      float32    // data scaling offset
+	SliceEnd      int16      // last slice index
+	SliceCode     uint8      // slice timing order
+	XyztUnits     uint8      // units of Pixdim[1] to Pixdim[4]
+	CalMax        float32    // maximum display intensity
+	CalMin        float32    // minimum display intensity
+	SliceDuration float32    // time for one slice
+	Toffset       float32    // time axis shift
+	Glmax         int32      // unused, from the ANALYZE 7.5 format
+	Glmin         int32      // unused, from the ANALYZE 7.5 format
+	Descrip       [80]byte   // free text description, zero-padded
+	AuxFile       [24]byte   // name of an auxiliary file, zero-padded
+	QformCode     int16      // the NIFTI_XFORM_* code of the qform. 0 means the qform is not set.
+	SformCode     int16      // the NIFTI_XFORM_* code of the sform. 0 means the sform is not set.
+	QuaternB      float32    // quaternion b parameter of the qform rotation
+	QuaternC      float32    // quaternion c parameter of the qform rotation
+	QuaternD      float32    // quaternion d parameter of the qform rotation
+	QoffsetX      float32    // x offset of the qform
+	QoffsetY      float32    // y offset of the qform
+	QoffsetZ      float32    // z offset of the qform
+	SrowX         [4]float32 // first row of the sform affine matrix
+	SrowY         [4]float32 // second row of the sform affine matrix
+	SrowZ         [4]float32 // third row of the sform affine matrix
+	IntentName    [16]byte   // name or meaning of the data, zero-padded
+	Magic         [4]byte    // "n+1\0" for single file NIfTI-1 (.nii), "ni1\0" for a header file with separate data file (.hdr/.img)
+}
+
+// Nifti1 models a NIfTI-1 file, with the header and the voxel data.
+type Nifti1 struct {
+	Header     Nifti1Header     // The header.
+	Extensions []NiftiExtension // The extensions stored between the header and the voxel data, nil if there are none.
+	Volume     Volume           // The voxel data, in the same volume model as MGH data. See getNiftiDataTypeInfo for how the NIfTI data types are mapped to MRI data types.
+}
+
+// Description returns the free text description from the header.
+func (hdr Nifti1Header) Description() string {
+	return trimNiftiString(hdr.Descrip[:])
+}
+
+// QformMatrix computes the qform affine matrix that maps voxel indices to world coordinates, from the quaternion representation in the header.
+//
+// Returns:
+//   - [4][4]float64: the affine matrix, row-major. It is only meaningful if QformCode is greater than 0.
+func (hdr Nifti1Header) QformMatrix() [4][4]float64 {
+	quatern := [3]float64{float64(hdr.QuaternB), float64(hdr.QuaternC), float64(hdr.QuaternD)}
+	qoffset := [3]float64{float64(hdr.QoffsetX), float64(hdr.QoffsetY), float64(hdr.QoffsetZ)}
+	pixdim := [4]float64{float64(hdr.Pixdim[0]), float64(hdr.Pixdim[1]), float64(hdr.Pixdim[2]), float64(hdr.Pixdim[3])}
+	return getNiftiQformMatrix(quatern, qoffset, pixdim)
+}
+
+// SformMatrix returns the sform affine matrix that maps voxel indices to world coordinates, from the SrowX, SrowY and SrowZ fields of the header.
+//
+// Returns:
+//   - [4][4]float64: the affine matrix, row-major. It is only meaningful if SformCode is greater than 0.
+func (hdr Nifti1Header) SformMatrix() [4][4]float64 {
+	var m [4][4]float64
+	for col := 0; col < 4; col++ {
+		m[0][col] = float64(hdr.SrowX[col])
+		m[1][col] = float64(hdr.SrowY[col])
+		m[2][col] = float64(hdr.SrowZ[col])
+	}
+	m[3][3] = 1
+	return m
+}
+
+// Vox2Ras returns the affine matrix that maps voxel indices to world (RAS) coordinates, like MghHeader.Vox2Ras for MGH files.
+//
+// Like most neuroimaging software, this uses the sform if SformCode is greater than 0, otherwise the qform if QformCode is greater than 0,
+// and otherwise a matrix that only scales by the voxel sizes.
+//
+// Returns:
+//   - [4][4]float64: the affine matrix, row-major
+func (hdr Nifti1Header) Vox2Ras() [4][4]float64 {
+	if hdr.SformCode > 0 {
+		return hdr.SformMatrix()
+	}
+	if hdr.QformCode > 0 {
+		return hdr.QformMatrix()
+	}
+	return [4][4]float64{{float64(hdr.Pixdim[1]), 0, 0, 0}, {0, float64(hdr.Pixdim[2]), 0, 0}, {0, 0, float64(hdr.Pixdim[3]), 0}, {0, 0, 0, 1}}
+}
+
+// readNifti1Header reads and checks a NIfTI-1 header from r. Exactly the 348 header bytes are consumed from r.
+//
+// The byte order is detected from the SizeofHdr field, which must be 348.
+//
+// Parameters:
+//   - r: reader positioned at the start of the uncompressed NIfTI-1 data
+//
+// Returns:
+//   - Nifti1Header: the header
+//   - binary.ByteOrder: the byte order of the file
+//   - error: an error if one occurred, e.g., if this is not a NIfTI-1 file
+func readNifti1Header(r io.Reader) (Nifti1Header, binary.ByteOrder, error) {
+	var hdr Nifti1Header
+	var order binary.ByteOrder = binary.LittleEndian
+
+	buf := make([]byte, 348)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return hdr, order, fmt.Errorf("readNifti1Header: failed to read NIfTI-1 header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(buf) != 348 {
+		order = binary.BigEndian
+		if binary.BigEndian.Uint32(buf) != 348 {
+			return hdr, order, fmt.Errorf("readNifti1Header: header size field is not 348 in either byte order, this is not a NIfTI-1 file.")
+		}
+	}
+	if err := binary.Read(bytes.NewReader(buf), order, &hdr); err != nil {
+		return hdr, order, fmt.Errorf("readNifti1Header: failed to decode NIfTI-1 header: %w", err)
+	}
+
+	magic := string(hdr.Magic[:])
+	if magic == "ni1\x00" {
+		return hdr, order, fmt.Errorf("readNifti1Header: the header declares a separate data file (.hdr/.img pair), which is not supported. Only single NIfTI-1 files (.nii) are supported.")
+	}
+	if magic != "n+1\x00" {
+		return hdr, order, fmt.Errorf("readNifti1Header: invalid magic %q, this is not a NIfTI-1 file.", magic)
+	}
+
+	if Verbosity > 0 {
+		fmt.Printf("readNifti1Header: NIfTI-1 dimensions: %v, data type=%d, byte order=%s.\n", hdr.Dim, hdr.Datatype, order)
+	}
+	return hdr, order, nil
+}
+
+// readNifti1 reads a NIfTI-1 file from r.
+//
+// Parameters:
+//   - r: the reader, positioned at the start of the NIfTI-1 data. Gzip-compressed data is detected and decompressed.
+//
+// Returns:
+//   - Nifti1: the NIfTI-1 header and voxel data
+//   - error: an error if one occurred
+func readNifti1(r io.Reader) (Nifti1, error) {
+	var nii Nifti1
+
+	s, err := newMghStream(r, "auto")
+	if err != nil {
+		return nii, err
+	}
+	defer s.Close()
+
+	hdr, order, err := readNifti1Header(s)
+	if err != nil {
+		return nii, err
+	}
+	nii.Header = hdr
+
+	var dim [8]int64
+	for idx, d := range hdr.Dim {
+		dim[idx] = int64(d)
+	}
+	shape, err := getNiftiVolumeShape(dim)
+	if err != nil {
+		return nii, fmt.Errorf("readNifti1: %w", err)
+	}
+
+	if hdr.VoxOffset < 348 {
+		return nii, fmt.Errorf("readNifti1: invalid data offset %f, must be at least 348.", hdr.VoxOffset)
+	}
+	extensions, err := readNiftiExtensions(s, order, int64(hdr.VoxOffset)-348)
+	if err != nil {
+		return nii, fmt.Errorf("readNifti1: %w", err)
+	}
+	nii.Extensions = extensions
+
+	volume, err := readNiftiData(s, order, hdr.Datatype, shape, hdr.SclSlope, hdr.SclInter)
+	if err != nil {
+		return nii, fmt.Errorf("readNifti1: %w", err)
+	}
+	nii.Volume = volume
+	return nii, nil
+}
+
+// ReadNifti1 reads a file in NIfTI-1 format, e.g., a '.nii' or '.nii.gz' file.
+//
+// Both byte orders are supported, and gzip-compressed files are detected automatically. The voxel data is returned as a Volume,
+// the same volume model used for MGH data, so downstream code does not depend on the file format. If the header contains
+// a data scaling (SclSlope not 0, and not the identity), the scaling is applied and the volume has data type MRI_FLOAT.
+// Use the Vox2Ras method of the header to get the affine matrix from the sform or qform.
+//
+// Parameters:
+//   - filepath: path to the NIfTI-1 file
+//
+// Returns:
+//   - Nifti1: the NIfTI-1 header and voxel data
+//   - error: an error if one occurred, e.g., an *UnsupportedNiftiDataTypeError if the data type is not supported
+func ReadNifti1(filepath string) (Nifti1, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return Nifti1{}, fmt.Errorf("ReadNifti1: could not open NIfTI-1 file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	nii, err := readNifti1(file)
+	if err != nil {
+		return nii, fmt.Errorf("ReadNifti1: failed to read NIfTI-1 file '%s': %w", filepath, err)
+	}
+	return nii, nil
+}
+
+// ReadNifti1FS reads a file in NIfTI-1 format from the file system fsys, see ReadNifti1.
+//
+// Parameters:
+//   - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//   - name: the name of the NIfTI-1 file in fsys
+//
+// Returns:
+//   - Nifti1: the NIfTI-1 header and voxel data
+//   - error: an error if one occurred
+func ReadNifti1FS(fsys fs.FS, name string) (Nifti1, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return Nifti1{}, fmt.Errorf("ReadNifti1FS: could not open NIfTI-1 file '%s': %w", name, err)
+	}
+	defer file.Close()
+
+	nii, err := readNifti1(file)
+	if err != nil {
+		return nii, fmt.Errorf("ReadNifti1FS: failed to read NIfTI-1 file '%s': %w", name, err)
+	}
+	return nii, nil
+}
+
+// ReadNifti1From reads data in NIfTI-1 format from r, see ReadNifti1.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File or a *bytes.Reader. Gzip-compressed data is detected automatically.
+//
+// Returns:
+//   - Nifti1: the NIfTI-1 header and voxel data
+//   - error: an error if one occurred
+func ReadNifti1From(r io.Reader) (Nifti1, error) {
+	return readNifti1(r)
+}
+
+// ReadNifti1Header reads only the header of a NIfTI-1 file, without reading the voxel data.
+//
+// Parameters:
+//   - filepath: path to the NIfTI-1 file, e.g., a '.nii' or '.nii.gz' file
+//
+// Returns:
+//   - Nifti1Header: the header
+//   - error: an error if one occurred
+func ReadNifti1Header(filepath string) (Nifti1Header, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return Nifti1Header{}, fmt.Errorf("ReadNifti1Header: could not open NIfTI-1 file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	s, err := newMghStream(file, "auto")
+	if err != nil {
+		return Nifti1Header{}, err
+	}
+	defer s.Close()
+
+	hdr, _, err := readNifti1Header(s)
+	if err != nil {
+		return hdr, fmt.Errorf("ReadNifti1Header: failed to read header of NIfTI-1 file '%s': %w", filepath, err)
+	}
+	return hdr, nil
+}
@@ -0,0 +1,273 @@
+package neuro
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// getTestNifti1Header returns a minimal valid NIfTI-1 header for single-file data with the given shape and data type.
+func getTestNifti1Header(dim []int16, datatype int16, bitpix int16) Nifti1Header {
+	hdr := Nifti1Header{SizeofHdr: 348, Datatype: datatype, Bitpix: bitpix, VoxOffset: 352}
+	hdr.Dim[0] = int16(len(dim))
+	copy(hdr.Dim[1:], dim)
+	hdr.Pixdim = [8]float32{1, 1, 1, 1, 1, 1, 1, 1}
+	copy(hdr.Magic[:], "n+1\x00")
+	return hdr
+}
+
+// getNiftiBytes encodes a NIfTI-1 or NIfTI-2 header, the extender, the raw extension bytes and the data in the given byte order.
+// The extender flags extensions if extensionBytes is not empty.
+func getNiftiBytes(t *testing.T, order binary.ByteOrder, hdr interface{}, extensionBytes []byte, data interface{}) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, order, hdr); err != nil {
+		t.Fatalf("binary.Write failed on header: %v", err)
+	}
+	extender := byte(0)
+	if len(extensionBytes) > 0 {
+		extender = 1
+	}
+	buf.Write([]byte{extender, 0, 0, 0})
+	buf.Write(extensionBytes)
+	if err := binary.Write(&buf, order, data); err != nil {
+		t.Fatalf("binary.Write failed on data: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNifti1HeaderSize(t *testing.T) {
+	if size := binary.Size(Nifti1Header{}); size != 348 {
+		t.Errorf("got NIfTI-1 header size %d, wanted %d", size, 348)
+	}
+}
+
+func TestReadNifti1FromBothByteOrders(t *testing.T) {
+	hdr := getTestNifti1Header([]int16{3, 2, 2}, NIFTI_TYPE_INT16, 16)
+	copy(hdr.Descrip[:], "test volume")
+	data := []int16{-300, -1, 0, 1, 2, 3, 4, 5, 6, 7, 8, 300}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		nii, err := ReadNifti1From(bytes.NewReader(getNiftiBytes(t, order, &hdr, nil, data)))
+		if err != nil {
+			t.Fatalf("ReadNifti1From failed for byte order %s: %v", order, err)
+		}
+		if diff := cmp.Diff(hdr, nii.Header); diff != "" {
+			t.Errorf("byte order %s: %s", order, diff)
+		}
+		if diff := cmp.Diff([4]int{3, 2, 2, 1}, nii.Volume.Shape()); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(data, nii.Volume.Data().DataMriShort); diff != "" {
+			t.Errorf("byte order %s: %s", order, diff)
+		}
+		if nii.Header.Description() != "test volume" {
+			t.Errorf("got description '%s', wanted '%s'", nii.Header.Description(), "test volume")
+		}
+	}
+}
+
+func TestReadNifti1FromGzipped(t *testing.T) {
+	hdr := getTestNifti1Header([]int16{2, 2}, NIFTI_TYPE_FLOAT32, 32)
+	data := []float32{1.5, -2.5, 3.5, 100.0}
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	gzipWriter.Write(getNiftiBytes(t, binary.LittleEndian, &hdr, nil, data))
+	gzipWriter.Close()
+
+	nii, err := ReadNifti1From(&buf)
+	if err != nil {
+		t.Fatalf("ReadNifti1From failed: %v", err)
+	}
+	if diff := cmp.Diff(data, nii.Volume.AsFloat32()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReadNifti1FromDataTypes(t *testing.T) {
+	testCases := []struct {
+		datatype int16
+		bitpix   int16
+		data     interface{}
+		wantType int32
+		want     []float64
+	}{
+		{NIFTI_TYPE_UINT8, 8, []uint8{0, 1, 255}, MRI_UCHAR, []float64{0, 1, 255}},
+		{NIFTI_TYPE_INT8, 8, []int8{-128, 0, 127}, MRI_SHORT, []float64{-128, 0, 127}},
+		{NIFTI_TYPE_UINT16, 16, []uint16{0, 1, 65535}, MRI_USHRT, []float64{0, 1, 65535}},
+		{NIFTI_TYPE_INT32, 32, []int32{-70000, 0, 70000}, MRI_INT, []float64{-70000, 0, 70000}},
+		{NIFTI_TYPE_UINT32, 32, []uint32{0, 1, 4000000000}, MRI_LONG, []float64{0, 1, 4000000000}},
+		{NIFTI_TYPE_INT64, 64, []int64{-5000000000, 0, 5000000000}, MRI_LONG, []float64{-5000000000, 0, 5000000000}},
+		{NIFTI_TYPE_UINT64, 64, []uint64{0, 1, math.MaxUint64}, MRI_LONG, []float64{0, 1, math.MaxInt64}},
+		{NIFTI_TYPE_FLOAT64, 64, []float64{-0.5, 0, 1e10}, MRI_FLOAT, []float64{-0.5, 0, 1e10}},
+	}
+
+	for _, tc := range testCases {
+		hdr := getTestNifti1Header([]int16{3}, tc.datatype, tc.bitpix)
+		nii, err := ReadNifti1From(bytes.NewReader(getNiftiBytes(t, binary.BigEndian, &hdr, nil, tc.data)))
+		if err != nil {
+			t.Fatalf("ReadNifti1From failed for NIfTI data type %d: %v", tc.datatype, err)
+		}
+		if nii.Volume.DataType() != tc.wantType {
+			t.Errorf("got MRI data type %d for NIfTI data type %d, wanted %d", nii.Volume.DataType(), tc.datatype, tc.wantType)
+		}
+		if diff := cmp.Diff(tc.want, nii.Volume.AsFloat64()); diff != "" {
+			t.Errorf("NIfTI data type %d: %s", tc.datatype, diff)
+		}
+	}
+}
+
+func TestReadNifti1FromScaling(t *testing.T) {
+	hdr := getTestNifti1Header([]int16{3}, NIFTI_TYPE_UINT8, 8)
+	hdr.SclSlope = 0.5
+	hdr.SclInter = -1.0
+
+	nii, err := ReadNifti1From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr, nil, []uint8{0, 2, 255})))
+	if err != nil {
+		t.Fatalf("ReadNifti1From failed: %v", err)
+	}
+	if nii.Volume.DataType() != MRI_FLOAT {
+		t.Errorf("got MRI data type %d for scaled data, wanted %d", nii.Volume.DataType(), MRI_FLOAT)
+	}
+	if diff := cmp.Diff([]float32{-1.0, 0.0, 126.5}, nii.Volume.AsFloat32()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReadNifti1FromMergesHigherDimensions(t *testing.T) {
+	hdr := getTestNifti1Header([]int16{2, 1, 1, 2, 3}, NIFTI_TYPE_UINT8, 8)
+	nii, err := ReadNifti1From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr, nil, make([]uint8, 12))))
+	if err != nil {
+		t.Fatalf("ReadNifti1From failed: %v", err)
+	}
+	if diff := cmp.Diff([4]int{2, 1, 1, 6}, nii.Volume.Shape()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReadNifti1FromInvalid(t *testing.T) {
+	hdr := getTestNifti1Header([]int16{3}, NIFTI_TYPE_RGB24, 24)
+	_, err := ReadNifti1From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr, nil, make([]uint8, 9))))
+	var dtErr *UnsupportedNiftiDataTypeError
+	if !errors.As(err, &dtErr) || dtErr.Code != NIFTI_TYPE_RGB24 {
+		t.Errorf("expected UnsupportedNiftiDataTypeError with code %d, got %v", NIFTI_TYPE_RGB24, err)
+	}
+
+	hdr = getTestNifti1Header([]int16{3}, NIFTI_TYPE_UINT8, 8)
+	copy(hdr.Magic[:], "ni1\x00")
+	if _, err := ReadNifti1From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr, nil, make([]uint8, 3)))); err == nil {
+		t.Errorf("expected error for NIfTI-1 header with separate data file, got nil")
+	}
+
+	hdr = getTestNifti1Header([]int16{3}, NIFTI_TYPE_UINT8, 8)
+	hdr.SizeofHdr = 540
+	if _, err := ReadNifti1From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr, nil, make([]uint8, 3)))); err == nil {
+		t.Errorf("expected error for invalid header size, got nil")
+	}
+
+	hdr = getTestNifti1Header([]int16{30}, NIFTI_TYPE_UINT8, 8)
+	if _, err := ReadNifti1From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr, nil, make([]uint8, 3)))); err == nil {
+		t.Errorf("expected error for truncated data, got nil")
+	}
+
+	// The header requests 16 GiB of data, which must not be allocated before the data is read.
+	hdr = getTestNifti1Header([]int16{32767, 32767, 2}, NIFTI_TYPE_FLOAT64, 64)
+	if _, err := ReadNifti1From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr, nil, make([]float64, 3)))); err == nil {
+		t.Errorf("expected error for truncated data of a huge volume, got nil")
+	}
+}
+
+func TestNifti1HeaderVox2Ras(t *testing.T) {
+	hdr := getTestNifti1Header([]int16{10, 10, 10}, NIFTI_TYPE_UINT8, 8)
+	hdr.Pixdim = [8]float32{-1, 2, 3, 4, 1, 1, 1, 1}
+
+	// Without sform and qform, only the voxel sizes are used.
+	want := [4][4]float64{{2, 0, 0, 0}, {0, 3, 0, 0}, {0, 0, 4, 0}, {0, 0, 0, 1}}
+	checkMatrixAlmostEqual(t, "Vox2Ras", hdr.Vox2Ras(), want)
+
+	// A qform with a rotation by 90 degrees around the z axis, and qfac -1 that flips the z axis.
+	hdr.QformCode = 1
+	hdr.QuaternD = float32(math.Sqrt(0.5))
+	hdr.QoffsetX, hdr.QoffsetY, hdr.QoffsetZ = 10, 20, 30
+	want = [4][4]float64{{0, -3, 0, 10}, {2, 0, 0, 20}, {0, 0, -4, 30}, {0, 0, 0, 1}}
+	checkMatrixAlmostEqual(t, "Vox2Ras", hdr.Vox2Ras(), want)
+	checkMatrixAlmostEqual(t, "QformMatrix", hdr.QformMatrix(), want)
+
+	// The sform takes precedence over the qform.
+	hdr.SformCode = 2
+	hdr.SrowX = [4]float32{-1, 0, 0, 90}
+	hdr.SrowY = [4]float32{0, 1, 0, -126}
+	hdr.SrowZ = [4]float32{0, 0, 1, -72}
+	want = [4][4]float64{{-1, 0, 0, 90}, {0, 1, 0, -126}, {0, 0, 1, -72}, {0, 0, 0, 1}}
+	checkMatrixAlmostEqual(t, "Vox2Ras", hdr.Vox2Ras(), want)
+}
+
+func TestReadNifti1File(t *testing.T) {
+	hdr := getTestNifti1Header([]int16{2, 2, 1, 2}, NIFTI_TYPE_INT32, 32)
+	data := []int32{1, 2, 3, 4, 5, 6, 7, 8}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "vol.nii"), getNiftiBytes(t, binary.LittleEndian, &hdr, nil, data), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	nii, err := ReadNifti1(filepath.Join(dir, "vol.nii"))
+	if err != nil {
+		t.Fatalf("ReadNifti1 failed: %v", err)
+	}
+	val, _ := nii.Volume.At(1, 1, 0, 1)
+	if val != 8 {
+		t.Errorf("got value %f at voxel (1, 1, 0, 1), wanted %f", val, 8.0)
+	}
+
+	niiFS, err := ReadNifti1FS(os.DirFS(dir), "vol.nii")
+	if err != nil {
+		t.Fatalf("ReadNifti1FS failed: %v", err)
+	}
+	if diff := cmp.Diff(data, niiFS.Volume.Data().DataMriInt); diff != "" {
+		t.Error(diff)
+	}
+
+	hdrOnly, err := ReadNifti1Header(filepath.Join(dir, "vol.nii"))
+	if err != nil {
+		t.Fatalf("ReadNifti1Header failed: %v", err)
+	}
+	if diff := cmp.Diff(hdr, hdrOnly); diff != "" {
+		t.Error(diff)
+	}
+
+	if _, err := ReadNifti1(filepath.Join(dir, "missing.nii")); err == nil {
+		t.Errorf("expected error for missing file, got nil")
+	}
+}
+
+func TestReadNifti1FromExtensions(t *testing.T) {
+	hdr := getTestNifti1Header([]int16{2}, NIFTI_TYPE_UINT8, 8)
+	hdr.VoxOffset = 352 + 16
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &hdr)
+	buf.Write([]byte{1, 0, 0, 0})
+	binary.Write(&buf, binary.LittleEndian, []int32{16, 6})
+	buf.WriteString("comment\x00")
+	buf.Write([]byte{7, 9})
+
+	nii, err := ReadNifti1From(&buf)
+	if err != nil {
+		t.Fatalf("ReadNifti1From failed: %v", err)
+	}
+	if diff := cmp.Diff([]NiftiExtension{{Code: 6, Data: []byte("comment\x00")}}, nii.Extensions); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]uint8{7, 9}, nii.Volume.Data().DataMriUchar); diff != "" {
+		t.Error(diff)
+	}
+}
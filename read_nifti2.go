@@ -0,0 +1,252 @@
+package neuro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// NIFTI2_MAGIC is the magic string of single file NIfTI-2 files.
+const NIFTI2_MAGIC string = "n+2\x00\r\n\x1a\n"
+
+// Nifti2Header models the 540 byte header of a NIfTI-2 file.
+//
+// NIfTI-2 is a version of NIfTI-1 with 64 bit dimensions and double precision fields, used for large surface-based datasets
+// and as the container of CIFTI files. The field names follow the C struct nifti_2_header, see https://nifti.nimh.nih.gov/nifti-2/.
+// See Nifti1Header for the meaning of the fields.
+type Nifti2Header struct {
+	SizeofHdr     int32      // size of the header, must be 540
+	Magic         [8]byte    // "n+2\0\r\n\032\n" for single file NIfTI-2, see NIFTI2_MAGIC
+	Datatype      int16      // the data type code of the voxel values, see NIFTI_TYPE_UINT8 and the other NIFTI_TYPE_* constants
+	Bitpix        int16      // number of bits per voxel value
+	Dim           [8]int64   // the data dimensions. Dim[0] is the number of dimensions, Dim[1] to Dim[7] are the lengths of the dimensions.
+	IntentP1      float64    // first parameter of the intent
+	IntentP2      float64    // second parameter of the intent
+	IntentP3      float64    // third parameter of the intent
+	Pixdim        [8]float64 // the grid spacings. Pixdim[0] is qfac, the sign of the third axis for the qform, and Pixdim[1] to Pixdim[3] are the voxel sizes.
+	VoxOffset     int64      // offset of the voxel data in the file, in bytes
+	SclSlope      float64    // data scaling slope. If not 0, voxel values are SclSlope * stored value + SclInter.
+	SclInter      float64    // data scaling offset
+	CalMax        float64    // maximum display intensity
+	CalMin        float64    // minimum display intensity
+	SliceDuration float64    // time for one slice
+	Toffset       float64    // time axis shift
+	SliceStart    int64      // first slice index
+	SliceEnd      int64      // last slice index
+	Descrip       [80]byte   // free text description, zero-padded
+	AuxFile       [24]byte   // name of an auxiliary file, zero-padded
+	QformCode     int32      // the NIFTI_XFORM_* code of the qform. 0 means the qform is not set.
+	SformCode     int32      // the NIFTI_XFORM_* code of the sform. 0 means the sform is not set.
+	QuaternB      float64    // quaternion b parameter of the qform rotation
+	QuaternC      float64    // quaternion c parameter of the qform rotation
+	QuaternD      float64    // quaternion d parameter of the qform rotation
+	QoffsetX      float64    // x offset of the qform
+	QoffsetY      float64    // y offset of the qform
+	QoffsetZ      float64    // z offset of the qform
+	SrowX         [4]float64 // first row of the sform affine matrix
+	SrowY         [4]float64 // second row of the sform affine matrix
+	SrowZ         [4]float64 // third row of the sform affine matrix
+	SliceCode     int32      // slice timing order
+	XyztUnits     int32      // units of Pixdim[1] to Pixdim[4]
+	IntentCode    int32      // the NIFTI_INTENT_* code, describes what the data means
+	IntentName    [16]byte   // name or meaning of the data, zero-padded
+	DimInfo       uint8      // the frequency, phase and slice encoding directions
+	UnusedStr     [15]byte   // unused, padding to 540 bytes
+}
+
+// Nifti2 models a NIfTI-2 file, with the header, the extensions and the voxel data.
+type Nifti2 struct {
+	Header     Nifti2Header     // The header.
+	Extensions []NiftiExtension // The extensions stored between the header and the voxel data, nil if there are none. For CIFTI files, this contains the CIFTI XML (Code 32).
+	Volume     Volume           // The voxel data, in the same volume model as MGH data. See getNiftiDataTypeInfo for how the NIfTI data types are mapped to MRI data types.
+}
+
+// Description returns the free text description from the header.
+func (hdr Nifti2Header) Description() string {
+	return trimNiftiString(hdr.Descrip[:])
+}
+
+// QformMatrix computes the qform affine matrix that maps voxel indices to world coordinates, from the quaternion representation in the header.
+//
+// Returns:
+//   - [4][4]float64: the affine matrix, row-major. It is only meaningful if QformCode is greater than 0.
+func (hdr Nifti2Header) QformMatrix() [4][4]float64 {
+	quatern := [3]float64{hdr.QuaternB, hdr.QuaternC, hdr.QuaternD}
+	qoffset := [3]float64{hdr.QoffsetX, hdr.QoffsetY, hdr.QoffsetZ}
+	pixdim := [4]float64{hdr.Pixdim[0], hdr.Pixdim[1], hdr.Pixdim[2], hdr.Pixdim[3]}
+	return getNiftiQformMatrix(quatern, qoffset, pixdim)
+}
+
+// SformMatrix returns the sform affine matrix that maps voxel indices to world coordinates, from the SrowX, SrowY and SrowZ fields of the header.
+//
+// Returns:
+//   - [4][4]float64: the affine matrix, row-major. It is only meaningful if SformCode is greater than 0.
+func (hdr Nifti2Header) SformMatrix() [4][4]float64 {
+	return [4][4]float64{hdr.SrowX, hdr.SrowY, hdr.SrowZ, {0, 0, 0, 1}}
+}
+
+// Vox2Ras returns the affine matrix that maps voxel indices to world (RAS) coordinates, see Nifti1Header.Vox2Ras.
+//
+// Returns:
+//   - [4][4]float64: the affine matrix, row-major
+func (hdr Nifti2Header) Vox2Ras() [4][4]float64 {
+	if hdr.SformCode > 0 {
+		return hdr.SformMatrix()
+	}
+	if hdr.QformCode > 0 {
+		return hdr.QformMatrix()
+	}
+	return [4][4]float64{{hdr.Pixdim[1], 0, 0, 0}, {0, hdr.Pixdim[2], 0, 0}, {0, 0, hdr.Pixdim[3], 0}, {0, 0, 0, 1}}
+}
+
+// readNifti2Header reads and checks a NIfTI-2 header from r. Exactly the 540 header bytes are consumed from r.
+//
+// The byte order is detected from the SizeofHdr field, which must be 540.
+//
+// Parameters:
+//   - r: reader positioned at the start of the uncompressed NIfTI-2 data
+//
+// Returns:
+//   - Nifti2Header: the header
+//   - binary.ByteOrder: the byte order of the file
+//   - error: an error if one occurred, e.g., if this is not a NIfTI-2 file
+func readNifti2Header(r io.Reader) (Nifti2Header, binary.ByteOrder, error) {
+	var hdr Nifti2Header
+	var order binary.ByteOrder = binary.LittleEndian
+
+	buf := make([]byte, 540)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return hdr, order, fmt.Errorf("readNifti2Header: failed to read NIfTI-2 header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(buf) != 540 {
+		order = binary.BigEndian
+		if binary.BigEndian.Uint32(buf) != 540 {
+			return hdr, order, fmt.Errorf("readNifti2Header: header size field is not 540 in either byte order, this is not a NIfTI-2 file.")
+		}
+	}
+	if err := binary.Read(bytes.NewReader(buf), order, &hdr); err != nil {
+		return hdr, order, fmt.Errorf("readNifti2Header: failed to decode NIfTI-2 header: %w", err)
+	}
+
+	magic := string(hdr.Magic[:])
+	if magic == "ni2\x00\r\n\x1a\n" {
+		return hdr, order, fmt.Errorf("readNifti2Header: the header declares a separate data file (.hdr/.img pair), which is not supported. Only single NIfTI-2 files (.nii) are supported.")
+	}
+	if magic != NIFTI2_MAGIC {
+		return hdr, order, fmt.Errorf("readNifti2Header: invalid magic %q, this is not a NIfTI-2 file.", magic)
+	}
+
+	if Verbosity > 0 {
+		fmt.Printf("readNifti2Header: NIfTI-2 dimensions: %v, data type=%d, byte order=%s.\n", hdr.Dim, hdr.Datatype, order)
+	}
+	return hdr, order, nil
+}
+
+// readNifti2 reads a NIfTI-2 file from r.
+//
+// Parameters:
+//   - r: the reader, positioned at the start of the NIfTI-2 data. Gzip-compressed data is detected and decompressed.
+//
+// Returns:
+//   - Nifti2: the NIfTI-2 header, extensions and voxel data
+//   - error: an error if one occurred
+func readNifti2(r io.Reader) (Nifti2, error) {
+	var nii Nifti2
+
+	s, err := newMghStream(r, "auto")
+	if err != nil {
+		return nii, err
+	}
+	defer s.Close()
+
+	hdr, order, err := readNifti2Header(s)
+	if err != nil {
+		return nii, err
+	}
+	nii.Header = hdr
+
+	shape, err := getNiftiVolumeShape(hdr.Dim)
+	if err != nil {
+		return nii, fmt.Errorf("readNifti2: %w", err)
+	}
+
+	if hdr.VoxOffset < 540 {
+		return nii, fmt.Errorf("readNifti2: invalid data offset %d, must be at least 540.", hdr.VoxOffset)
+	}
+	extensions, err := readNiftiExtensions(s, order, hdr.VoxOffset-540)
+	if err != nil {
+		return nii, fmt.Errorf("readNifti2: %w", err)
+	}
+	nii.Extensions = extensions
+
+	volume, err := readNiftiData(s, order, hdr.Datatype, shape, float32(hdr.SclSlope), float32(hdr.SclInter))
+	if err != nil {
+		return nii, fmt.Errorf("readNifti2: %w", err)
+	}
+	nii.Volume = volume
+	return nii, nil
+}
+
+// ReadNifti2 reads a file in NIfTI-2 format, e.g., a '.nii' or '.nii.gz' file, or a CIFTI file like '.dscalar.nii'.
+//
+// Both byte orders are supported, and gzip-compressed files are detected automatically. The extensions are read, and the voxel data
+// is returned as a Volume, the same volume model used for MGH data. Dimensions 5 to 7 are merged into the 4th dimension of the volume.
+// If the header contains a data scaling (SclSlope not 0, and not the identity), the scaling is applied and the volume has data type MRI_FLOAT.
+//
+// Parameters:
+//   - filepath: path to the NIfTI-2 file
+//
+// Returns:
+//   - Nifti2: the NIfTI-2 header, extensions and voxel data
+//   - error: an error if one occurred, e.g., an *UnsupportedNiftiDataTypeError if the data type is not supported
+func ReadNifti2(filepath string) (Nifti2, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return Nifti2{}, fmt.Errorf("ReadNifti2: could not open NIfTI-2 file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	nii, err := readNifti2(file)
+	if err != nil {
+		return nii, fmt.Errorf("ReadNifti2: failed to read NIfTI-2 file '%s': %w", filepath, err)
+	}
+	return nii, nil
+}
+
+// ReadNifti2FS reads a file in NIfTI-2 format from the file system fsys, see ReadNifti2.
+//
+// Parameters:
+//   - fsys: the file system, e.g., a *zip.Reader or an embed.FS
+//   - name: the name of the NIfTI-2 file in fsys
+//
+// Returns:
+//   - Nifti2: the NIfTI-2 header, extensions and voxel data
+//   - error: an error if one occurred
+func ReadNifti2FS(fsys fs.FS, name string) (Nifti2, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return Nifti2{}, fmt.Errorf("ReadNifti2FS: could not open NIfTI-2 file '%s': %w", name, err)
+	}
+	defer file.Close()
+
+	nii, err := readNifti2(file)
+	if err != nil {
+		return nii, fmt.Errorf("ReadNifti2FS: failed to read NIfTI-2 file '%s': %w", name, err)
+	}
+	return nii, nil
+}
+
+// ReadNifti2From reads data in NIfTI-2 format from r, see ReadNifti2.
+//
+// Parameters:
+//   - r: the reader, e.g., an *os.File or a *bytes.Reader. Gzip-compressed data is detected automatically.
+//
+// Returns:
+//   - Nifti2: the NIfTI-2 header, extensions and voxel data
+//   - error: an error if one occurred
+func ReadNifti2From(r io.Reader) (Nifti2, error) {
+	return readNifti2(r)
+}
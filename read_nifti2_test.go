@@ -0,0 +1,115 @@
+package neuro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// getTestNifti2Header returns a minimal valid NIfTI-2 header for single-file data with the given shape and data type.
+func getTestNifti2Header(dim []int64, datatype int16, bitpix int16) Nifti2Header {
+	hdr := Nifti2Header{SizeofHdr: 540, Datatype: datatype, Bitpix: bitpix, VoxOffset: 544}
+	hdr.Dim[0] = int64(len(dim))
+	copy(hdr.Dim[1:], dim)
+	hdr.Pixdim = [8]float64{1, 1, 1, 1, 1, 1, 1, 1}
+	copy(hdr.Magic[:], NIFTI2_MAGIC)
+	return hdr
+}
+
+func TestNifti2HeaderSize(t *testing.T) {
+	if size := binary.Size(Nifti2Header{}); size != 540 {
+		t.Errorf("got NIfTI-2 header size %d, wanted %d", size, 540)
+	}
+}
+
+func TestReadNifti2FromBothByteOrders(t *testing.T) {
+	hdr := getTestNifti2Header([]int64{2, 3}, NIFTI_TYPE_FLOAT32, 32)
+	data := []float32{-1.5, 0, 1.5, 2.5, 1000, 0.25}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		nii, err := ReadNifti2From(bytes.NewReader(getNiftiBytes(t, order, &hdr, nil, data)))
+		if err != nil {
+			t.Fatalf("ReadNifti2From failed for byte order %s: %v", order, err)
+		}
+		if diff := cmp.Diff(hdr, nii.Header); diff != "" {
+			t.Errorf("byte order %s: %s", order, diff)
+		}
+		if diff := cmp.Diff(data, nii.Volume.Data().DataMriFloat); diff != "" {
+			t.Errorf("byte order %s: %s", order, diff)
+		}
+		if nii.Extensions != nil {
+			t.Errorf("got %d extensions, wanted none", len(nii.Extensions))
+		}
+	}
+}
+
+func TestReadNifti2FromExtensions(t *testing.T) {
+	// Two extension blocks in big endian byte order: 16 bytes with code 6 (comment), and 32 bytes with code 32 (CIFTI).
+	var extensionBytes bytes.Buffer
+	binary.Write(&extensionBytes, binary.BigEndian, []int32{16, 6})
+	extensionBytes.WriteString("comment\x00")
+	binary.Write(&extensionBytes, binary.BigEndian, []int32{32, 32})
+	extensionBytes.WriteString("<CIFTI Version=\"2\"/>\x00\x00\x00\x00")
+
+	// A CIFTI-like file with 6 dimensions, 3 maps of 4 vertices.
+	hdr := getTestNifti2Header([]int64{1, 1, 1, 1, 3, 4}, NIFTI_TYPE_FLOAT32, 32)
+	hdr.VoxOffset = 544 + 48
+	nii, err := ReadNifti2From(bytes.NewReader(getNiftiBytes(t, binary.BigEndian, &hdr, extensionBytes.Bytes(), make([]float32, 12))))
+	if err != nil {
+		t.Fatalf("ReadNifti2From failed: %v", err)
+	}
+
+	want := []NiftiExtension{
+		{Code: 6, Data: []byte("comment\x00")},
+		{Code: 32, Data: []byte("<CIFTI Version=\"2\"/>\x00\x00\x00\x00")},
+	}
+	if diff := cmp.Diff(want, nii.Extensions); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([4]int{1, 1, 1, 12}, nii.Volume.Shape()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReadNifti2FromInvalid(t *testing.T) {
+	hdr := getTestNifti2Header([]int64{4}, NIFTI_TYPE_UINT8, 8)
+	hdr.VoxOffset = 544 + 16
+	var extensionBytes bytes.Buffer
+	binary.Write(&extensionBytes, binary.LittleEndian, []int32{64, 6}) // size exceeds the space before the data
+	extensionBytes.Write(make([]byte, 8))
+	if _, err := ReadNifti2From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr, extensionBytes.Bytes(), make([]uint8, 4)))); err == nil {
+		t.Errorf("expected error for invalid extension size, got nil")
+	}
+
+	hdr = getTestNifti2Header([]int64{4}, NIFTI_TYPE_UINT8, 8)
+	copy(hdr.Magic[:], "n+1\x00")
+	if _, err := ReadNifti2From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr, nil, make([]uint8, 4)))); err == nil {
+		t.Errorf("expected error for invalid magic, got nil")
+	}
+
+	// Dimensions whose product overflows, or wraps to 0, must not be used to allocate the data.
+	for _, dim := range [][]int64{{1 << 62, 3, 1}, {1 << 40, 1 << 40, 1 << 40}} {
+		hdr = getTestNifti2Header(dim, NIFTI_TYPE_UINT8, 8)
+		if _, err := ReadNifti2From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr, nil, make([]uint8, 4)))); err == nil {
+			t.Errorf("expected error for dimensions %v, got nil", dim)
+		}
+	}
+
+	// A NIfTI-1 file is not a NIfTI-2 file.
+	hdr1 := getTestNifti1Header([]int16{4}, NIFTI_TYPE_UINT8, 8)
+	if _, err := ReadNifti2From(bytes.NewReader(getNiftiBytes(t, binary.LittleEndian, &hdr1, nil, make([]uint8, 200)))); err == nil {
+		t.Errorf("expected error for NIfTI-1 file, got nil")
+	}
+}
+
+func TestNifti2HeaderVox2Ras(t *testing.T) {
+	hdr := getTestNifti2Header([]int64{10, 10, 10}, NIFTI_TYPE_UINT8, 8)
+	hdr.SformCode = 4
+	hdr.SrowX = [4]float64{-2, 0, 0, 90}
+	hdr.SrowY = [4]float64{0, 2, 0, -126}
+	hdr.SrowZ = [4]float64{0, 0, 2, -72}
+	want := [4][4]float64{{-2, 0, 0, 90}, {0, 2, 0, -126}, {0, 0, 2, -72}, {0, 0, 0, 1}}
+	checkMatrixAlmostEqual(t, "Vox2Ras", hdr.Vox2Ras(), want)
+}
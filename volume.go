@@ -0,0 +1,253 @@
+package neuro
+
+import (
+	"fmt"
+	"math"
+)
+
+// Volume is a 4D volume with typed access to its voxel values, independent of the MRI data type.
+//
+// A Volume wraps the data slice of an MghData struct, so callers do not have to switch on the MghDataType to access the values.
+// The data is stored in the same column-major order as in MGH files, i.e., the first dimension varies fastest.
+//
+// Values are read as float64, which can represent all values of all supported MRI data types exactly, except for MRI_LONG
+// values with an absolute value above 2^53. When a value is stored
+// in a volume with an integer data type, or when a volume is converted to an integer data type, the value is rounded to the
+// nearest integer (halfway cases away from zero), and then clipped to the range of the data type. NaN is stored as 0.
+type Volume struct {
+	data  MghData
+	shape [4]int
+}
+
+// NewVolume creates a Volume with the given shape and data type, in which all values are 0.
+//
+// Parameters:
+//   - shape: the lengths of the 4 dimensions. Use 1 for unused dimensions, e.g., for 3D volumes.
+//   - dtCode: the MRI data type code, e.g., MRI_FLOAT.
+//
+// Returns:
+//   - Volume: the volume
+//   - error: an error if one occurred, e.g., if the data type is unsupported, a dimension length is less than 1 or the number of values overflows an int
+func NewVolume(shape [4]int, dtCode int32) (Volume, error) {
+	numValues := 1
+	for _, dimLength := range shape {
+		if dimLength < 1 {
+			return Volume{}, fmt.Errorf("NewVolume: all dimension lengths must be at least 1, but shape is %v.", shape)
+		}
+		if numValues > math.MaxInt/dimLength {
+			return Volume{}, fmt.Errorf("NewVolume: the number of values for shape %v overflows an int.", shape)
+		}
+		numValues *= dimLength
+	}
+	data, err := newMghData(dtCode, numValues)
+	if err != nil {
+		return Volume{}, err
+	}
+	return Volume{data: data, shape: shape}, nil
+}
+
+// NewVolumeFromMgh creates a Volume for the data of an Mgh struct, e.g., from ReadFsMgh.
+//
+// The volume shares the data slice with mgh.Data, so changes made with Set are visible in mgh.Data and vice versa.
+//
+// Parameters:
+//   - mgh: the Mgh struct
+//
+// Returns:
+//   - Volume: the volume
+//   - error: an error if one occurred, e.g., if the data type is unsupported or the data length does not match the header dimensions
+func NewVolumeFromMgh(mgh Mgh) (Volume, error) {
+	if err := checkMghDataType(mgh); err != nil {
+		return Volume{}, err
+	}
+	shape := [4]int{int(mgh.Header.Dim1Length), int(mgh.Header.Dim2Length), int(mgh.Header.Dim3Length), int(mgh.Header.Dim4Length)}
+	v := Volume{data: mgh.Data, shape: shape}
+	if int64(v.Len()) != getMghNumValues(mgh.Header) {
+		return Volume{}, fmt.Errorf("NewVolumeFromMgh: header dimensions require %d values, but data has %d.", getMghNumValues(mgh.Header), v.Len())
+	}
+	return v, nil
+}
+
+// Shape returns the lengths of the 4 dimensions of the volume.
+func (v Volume) Shape() [4]int {
+	return v.shape
+}
+
+// Strides returns the distance between consecutive values in each of the 4 dimensions, in number of values.
+//
+// The value at (i, j, k, t) is at index i*s[0] + j*s[1] + k*s[2] + t*s[3] of the data slice, where s are the strides.
+func (v Volume) Strides() [4]int {
+	return [4]int{1, v.shape[0], v.shape[0] * v.shape[1], v.shape[0] * v.shape[1] * v.shape[2]}
+}
+
+// Len returns the number of values in the volume.
+func (v Volume) Len() int {
+	switch v.data.MghDataType {
+	case MRI_UCHAR:
+		return len(v.data.DataMriUchar)
+	case MRI_INT:
+		return len(v.data.DataMriInt)
+	case MRI_FLOAT:
+		return len(v.data.DataMriFloat)
+	case MRI_SHORT:
+		return len(v.data.DataMriShort)
+	case MRI_LONG:
+		return len(v.data.DataMriLong)
+	case MRI_USHRT:
+		return len(v.data.DataMriUshort)
+	default:
+		return 0
+	}
+}
+
+// DataType returns the MRI data type code of the volume, e.g., MRI_FLOAT.
+func (v Volume) DataType() int32 {
+	return v.data.MghDataType
+}
+
+// Data returns the MghData struct of the volume. It shares the data slice with the volume.
+func (v Volume) Data() MghData {
+	return v.data
+}
+
+// index computes the index in the data slice for the given voxel indices.
+func (v Volume) index(i int, j int, k int, t int) (int, error) {
+	idx := [4]int{i, j, k, t}
+	for dim := 0; dim < 4; dim++ {
+		if idx[dim] < 0 || idx[dim] >= v.shape[dim] {
+			return 0, fmt.Errorf("voxel index %v out of range for volume with shape %v.", idx, v.shape)
+		}
+	}
+	s := v.Strides()
+	return i*s[0] + j*s[1] + k*s[2] + t*s[3], nil
+}
+
+// at returns the value at the given index of the data slice.
+func (v Volume) at(idx int) float64 {
+	switch v.data.MghDataType {
+	case MRI_UCHAR:
+		return float64(v.data.DataMriUchar[idx])
+	case MRI_INT:
+		return float64(v.data.DataMriInt[idx])
+	case MRI_FLOAT:
+		return float64(v.data.DataMriFloat[idx])
+	case MRI_SHORT:
+		return float64(v.data.DataMriShort[idx])
+	case MRI_LONG:
+		return float64(v.data.DataMriLong[idx])
+	case MRI_USHRT:
+		return float64(v.data.DataMriUshort[idx])
+	default:
+		return 0
+	}
+}
+
+// set stores the value at the given index of the data slice, applying the rounding and clipping rules of the data type.
+func (v Volume) set(idx int, val float64) {
+	switch v.data.MghDataType {
+	case MRI_UCHAR:
+		v.data.DataMriUchar[idx] = uint8(roundAndClip(val, 0, math.MaxUint8))
+	case MRI_INT:
+		v.data.DataMriInt[idx] = int32(roundAndClip(val, math.MinInt32, math.MaxInt32))
+	case MRI_FLOAT:
+		v.data.DataMriFloat[idx] = float32(val)
+	case MRI_SHORT:
+		v.data.DataMriShort[idx] = int16(roundAndClip(val, math.MinInt16, math.MaxInt16))
+	case MRI_LONG:
+		// float64(math.MaxInt64) is 2^63, which is out of the int64 range, so it is handled separately.
+		if val >= float64(math.MaxInt64) {
+			v.data.DataMriLong[idx] = math.MaxInt64
+		} else {
+			v.data.DataMriLong[idx] = int64(roundAndClip(val, math.MinInt64, math.MaxInt64))
+		}
+	case MRI_USHRT:
+		v.data.DataMriUshort[idx] = uint16(roundAndClip(val, 0, math.MaxUint16))
+	}
+}
+
+// roundAndClip rounds val to the nearest integer, with halfway cases away from zero, and clips the result to the range [min, max]. NaN is mapped to 0.
+func roundAndClip(val float64, min float64, max float64) float64 {
+	if math.IsNaN(val) {
+		return 0
+	}
+	return math.Max(min, math.Min(max, math.Round(val)))
+}
+
+// At returns the value of the voxel at (i, j, k, t).
+//
+// Parameters:
+//   - i, j, k, t: the indices of the voxel in the 4 dimensions
+//
+// Returns:
+//   - float64: the value
+//   - error: an error if the indices are out of range
+func (v Volume) At(i int, j int, k int, t int) (float64, error) {
+	idx, err := v.index(i, j, k, t)
+	if err != nil {
+		return 0, fmt.Errorf("At: %w", err)
+	}
+	return v.at(idx), nil
+}
+
+// Set sets the value of the voxel at (i, j, k, t).
+//
+// For integer data types, the value is rounded and clipped to the range of the type, see Volume.
+//
+// Parameters:
+//   - i, j, k, t: the indices of the voxel in the 4 dimensions
+//   - val: the new value
+//
+// Returns:
+//   - error: an error if the indices are out of range
+func (v Volume) Set(i int, j int, k int, t int, val float64) error {
+	idx, err := v.index(i, j, k, t)
+	if err != nil {
+		return fmt.Errorf("Set: %w", err)
+	}
+	v.set(idx, val)
+	return nil
+}
+
+// AsFloat32 returns a copy of all values of the volume as float32, in column-major order.
+//
+// Note that MRI_INT values with an absolute value above 2^24 cannot be represented exactly as float32.
+func (v Volume) AsFloat32() []float32 {
+	if v.data.MghDataType == MRI_FLOAT {
+		return append([]float32(nil), v.data.DataMriFloat...)
+	}
+	values := make([]float32, v.Len())
+	for idx := range values {
+		values[idx] = float32(v.at(idx))
+	}
+	return values
+}
+
+// AsFloat64 returns a copy of all values of the volume as float64, in column-major order.
+func (v Volume) AsFloat64() []float64 {
+	values := make([]float64, v.Len())
+	for idx := range values {
+		values[idx] = v.at(idx)
+	}
+	return values
+}
+
+// ConvertTo returns a copy of the volume with the given data type.
+//
+// When converting to an integer data type, values are rounded and clipped to the range of the type, see Volume.
+//
+// Parameters:
+//   - dtCode: the MRI data type code of the new volume, e.g., MRI_FLOAT.
+//
+// Returns:
+//   - Volume: the new volume
+//   - error: an error if the data type is unsupported
+func (v Volume) ConvertTo(dtCode int32) (Volume, error) {
+	converted, err := NewVolume(v.shape, dtCode)
+	if err != nil {
+		return converted, err
+	}
+	for idx := 0; idx < v.Len(); idx++ {
+		converted.set(idx, v.at(idx))
+	}
+	return converted, nil
+}
@@ -0,0 +1,147 @@
+package neuro
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewVolumeFromMgh(t *testing.T) {
+	v, err := NewVolumeFromMgh(getTestMgh4D())
+	if err != nil {
+		t.Fatalf("NewVolumeFromMgh failed: %v", err)
+	}
+	if diff := cmp.Diff([4]int{4, 3, 2, 5}, v.Shape()); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([4]int{1, 4, 12, 24}, v.Strides()); diff != "" {
+		t.Error(diff)
+	}
+	if v.Len() != 120 || v.DataType() != MRI_FLOAT {
+		t.Errorf("got Len=%d and DataType=%d, wanted 120 and %d", v.Len(), v.DataType(), MRI_FLOAT)
+	}
+
+	val, err := v.At(3, 2, 1, 4)
+	if err != nil {
+		t.Fatalf("At failed: %v", err)
+	}
+	if val != 4123 {
+		t.Errorf("got value %f, wanted %f", val, 4123.0)
+	}
+
+	if _, err := v.At(4, 0, 0, 0); err == nil {
+		t.Errorf("expected error for index out of range, got nil")
+	}
+	if err := v.Set(0, 0, 0, -1, 1.0); err == nil {
+		t.Errorf("expected error for negative index, got nil")
+	}
+}
+
+func TestNewVolumeFromMghInvalid(t *testing.T) {
+	mgh := getTestMgh4D()
+	mgh.Data.DataMriFloat = mgh.Data.DataMriFloat[:10]
+	if _, err := NewVolumeFromMgh(mgh); err == nil {
+		t.Errorf("expected error for data length not matching header dimensions, got nil")
+	}
+
+	if _, err := NewVolume([4]int{2, 0, 1, 1}, MRI_FLOAT); err == nil {
+		t.Errorf("expected error for dimension length 0, got nil")
+	}
+	if _, err := NewVolume([4]int{1 << 62, 3, 1, 1}, MRI_UCHAR); err == nil {
+		t.Errorf("expected error for overflowing number of values, got nil")
+	}
+	if _, err := NewVolume([4]int{1 << 32, 1 << 32, 1, 1}, MRI_UCHAR); err == nil {
+		t.Errorf("expected error for number of values wrapping to 0, got nil")
+	}
+	if _, err := NewVolume([4]int{2, 2, 1, 1}, 99); err == nil {
+		t.Errorf("expected error for invalid data type, got nil")
+	}
+}
+
+func TestVolumeSetSharesData(t *testing.T) {
+	mgh := getTestMgh4D()
+	v, err := NewVolumeFromMgh(mgh)
+	if err != nil {
+		t.Fatalf("NewVolumeFromMgh failed: %v", err)
+	}
+	if err := v.Set(1, 0, 0, 0, -2.5); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if mgh.Data.DataMriFloat[1] != -2.5 {
+		t.Errorf("got value %f in MghData, wanted %f", mgh.Data.DataMriFloat[1], -2.5)
+	}
+}
+
+func TestVolumeSetRoundsAndClips(t *testing.T) {
+	testCases := []struct {
+		dtCode int32
+		inputs []float64
+		want   []float64
+	}{
+		{MRI_UCHAR, []float64{-3, 2.5, 254.4, 300, math.NaN()}, []float64{0, 3, 254, 255, 0}},
+		{MRI_SHORT, []float64{-40000, -2.5, 1.49, 40000, math.Inf(1)}, []float64{-32768, -3, 1, 32767, 32767}},
+		{MRI_INT, []float64{-3e10, -0.5, 0.5, 7.7, 3e10}, []float64{math.MinInt32, -1, 1, 8, math.MaxInt32}},
+		{MRI_FLOAT, []float64{-3.5, 0.25, 1e6, 7.5, 0}, []float64{-3.5, 0.25, 1e6, 7.5, 0}},
+		{MRI_LONG, []float64{-1e19, -2.5, 5e9, 1e19, math.NaN()}, []float64{math.MinInt64, -3, 5e9, math.MaxInt64, 0}},
+		{MRI_USHRT, []float64{-1, 2.5, 65534.6, 70000, 12}, []float64{0, 3, 65535, 65535, 12}},
+	}
+
+	for _, tc := range testCases {
+		v, err := NewVolume([4]int{5, 1, 1, 1}, tc.dtCode)
+		if err != nil {
+			t.Fatalf("NewVolume failed: %v", err)
+		}
+		for i, val := range tc.inputs {
+			if err := v.Set(i, 0, 0, 0, val); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+		}
+		if diff := cmp.Diff(tc.want, v.AsFloat64()); diff != "" {
+			t.Errorf("data type %d: %s", tc.dtCode, diff)
+		}
+	}
+}
+
+func TestVolumeConvertTo(t *testing.T) {
+	v, err := NewVolume([4]int{2, 2, 1, 1}, MRI_FLOAT)
+	if err != nil {
+		t.Fatalf("NewVolume failed: %v", err)
+	}
+	v.Set(0, 0, 0, 0, -1.5)
+	v.Set(1, 0, 0, 0, 100.4)
+	v.Set(0, 1, 0, 0, 255.5)
+	v.Set(1, 1, 0, 0, 1000.0)
+
+	converted, err := v.ConvertTo(MRI_UCHAR)
+	if err != nil {
+		t.Fatalf("ConvertTo failed: %v", err)
+	}
+	if diff := cmp.Diff([]uint8{0, 100, 255, 255}, converted.Data().DataMriUchar); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]float32{0, 100, 255, 255}, converted.AsFloat32()); diff != "" {
+		t.Error(diff)
+	}
+
+	// The original volume is not modified.
+	if diff := cmp.Diff([]float32{-1.5, 100.4, 255.5, 1000.0}, v.AsFloat32()); diff != "" {
+		t.Error(diff)
+	}
+
+	if _, err := v.ConvertTo(42); err == nil {
+		t.Errorf("expected error for invalid data type, got nil")
+	}
+}
+
+func ExampleNewVolumeFromMgh() {
+	mgh, _ := ReadFsMgh("testdata/brain.mgz", "auto")
+
+	// The Volume gives access to the voxel values without a switch on the MRI data type of the file.
+	volume, _ := NewVolumeFromMgh(mgh)
+	val, _ := volume.At(99, 99, 99, 0)
+
+	fmt.Printf("Volume shape=%v, value at voxel (99, 99, 99)=%.1f", volume.Shape(), val)
+	// Output: Volume shape=[256 256 256 1], value at voxel (99, 99, 99)=77.0
+}
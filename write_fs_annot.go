@@ -0,0 +1,177 @@
+package neuro
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// checkFsAnnotColortable checks that a colortable can be written to an annotation file in the given format.
+//
+// Parameters:
+//   - ct: the colortable
+//   - colortableFormat: "old" or "new"
+//
+// Returns:
+//   - error: an error if the slices of the colortable have different lengths, a color is out of range, two entries have the same label code, or the structure IDs cannot be stored in the old format
+func checkFsAnnotColortable(ct FsColortable, colortableFormat string) error {
+	n := ct.NumEntries()
+	if len(ct.StructureId) != n || len(ct.R) != n || len(ct.G) != n || len(ct.B) != n || len(ct.A) != n {
+		return fmt.Errorf("the slices of the colortable must all have length %d (the number of names).", n)
+	}
+
+	codes := make(map[int32]int, n)
+	for idx := 0; idx < n; idx++ {
+		for _, c := range []int32{ct.R[idx], ct.G[idx], ct.B[idx], ct.A[idx]} {
+			if c < 0 || c > 255 {
+				return fmt.Errorf("color value %d of colortable entry %d ('%s') is out of range 0 to 255.", c, idx, ct.Name[idx])
+			}
+		}
+		code := getFsAnnotLabelCode(ct.R[idx], ct.G[idx], ct.B[idx])
+		if other, ok := codes[code]; ok {
+			return fmt.Errorf("colortable entries %d ('%s') and %d ('%s') have the same color, so their vertices cannot be distinguished.", other, ct.Name[other], idx, ct.Name[idx])
+		}
+		codes[code] = idx
+		if colortableFormat == "old" && ct.StructureId[idx] != int32(idx) {
+			return fmt.Errorf("the old colortable format cannot store structure ID %d of entry %d, the structure IDs must be the entry indices. Use the new format instead.", ct.StructureId[idx], idx)
+		}
+	}
+	return nil
+}
+
+// checkFsAnnot checks the colortable format parameter and the colortable of an annotation before writing it.
+func checkFsAnnot(annot FsAnnot, colortableFormat string) error {
+	if colortableFormat != "old" && colortableFormat != "new" {
+		return fmt.Errorf("invalid colortable format '%s', must be 'old' or 'new'.", colortableFormat)
+	}
+	return checkFsAnnotColortable(annot.Colortable, colortableFormat)
+}
+
+// writeFsAnnotString writes a string preceded by its length as an int32, including a terminating zero byte.
+func writeFsAnnotString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.BigEndian, int32(len(s)+1)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, s); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{0})
+	return err
+}
+
+// WriteFsAnnot writes an annotation to a file in FreeSurfer annotation format, e.g., a custom parcellation to '<subject>/label/lh.myatlas.annot'.
+//
+// The file can be used with FreeSurfer tools like freeview and mris_anatomical_stats. The label code of each region is computed from
+// its color, see FsColortable.LabelCodes, so each region must have a unique color.
+//
+// Parameters:
+//   - filepath: path to the output file. The directory must exist.
+//   - annot: the annotation to write
+//   - colortableFormat: the format of the colortable, "new" for the current format (version 2, which stores the structure IDs), or "old" for the old format (where the structure IDs are the entry indices).
+//
+// Returns:
+//   - error: an error if one occurred, e.g., if the colortable is invalid
+func WriteFsAnnot(filepath string, annot FsAnnot, colortableFormat string) error {
+	if err := checkFsAnnot(annot, colortableFormat); err != nil {
+		return fmt.Errorf("WriteFsAnnot: %w", err)
+	}
+
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("WriteFsAnnot: could not create annotation file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	if Verbosity >= 1 {
+		fmt.Printf("WriteFsAnnot: Writing annotation for %d vertices with %d colortable entries to file '%s'.\n", len(annot.VertexLabels), annot.Colortable.NumEntries(), filepath)
+	}
+
+	if err := WriteFsAnnotTo(file, annot, colortableFormat); err != nil {
+		return fmt.Errorf("WriteFsAnnot: failed to write annotation file '%s': %w", filepath, err)
+	}
+	return file.Sync()
+}
+
+// WriteFsAnnotTo writes an annotation in FreeSurfer annotation format to w.
+//
+// This is the io.Writer version of WriteFsAnnot, see there for details.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - annot: the annotation to write
+//   - colortableFormat: the format of the colortable, "new" or "old"
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteFsAnnotTo(w io.Writer, annot FsAnnot, colortableFormat string) error {
+	if err := checkFsAnnot(annot, colortableFormat); err != nil {
+		return fmt.Errorf("WriteFsAnnotTo: %w", err)
+	}
+
+	endian := binary.BigEndian
+	writer := bufio.NewWriter(w)
+
+	// The vertex data is stored as pairs of vertex index and label code.
+	vertexData := make([]int32, 2*len(annot.VertexLabels))
+	for idx, code := range annot.VertexLabels {
+		vertexData[2*idx] = int32(idx)
+		vertexData[2*idx+1] = code
+	}
+	if err := binary.Write(writer, endian, int32(len(annot.VertexLabels))); err != nil {
+		return err
+	}
+	if err := binary.Write(writer, endian, vertexData); err != nil {
+		return err
+	}
+
+	ct := annot.Colortable
+	numEntries := int32(ct.NumEntries())
+	if numEntries == 0 {
+		if err := binary.Write(writer, endian, int32(0)); err != nil {
+			return err
+		}
+		return writer.Flush()
+	}
+
+	var header []int32
+	if colortableFormat == "old" {
+		header = []int32{1, numEntries}
+	} else {
+		// The number of entries in the header is the maximal structure ID plus one, like FreeSurfer writes it.
+		var maxStructureId int32
+		for _, id := range ct.StructureId {
+			if id > maxStructureId {
+				maxStructureId = id
+			}
+		}
+		header = []int32{1, -2, maxStructureId + 1}
+	}
+	if err := binary.Write(writer, endian, header); err != nil {
+		return err
+	}
+	if err := writeFsAnnotString(writer, ct.Filename); err != nil {
+		return err
+	}
+	if colortableFormat == "new" {
+		if err := binary.Write(writer, endian, numEntries); err != nil {
+			return err
+		}
+	}
+
+	for idx := 0; idx < int(numEntries); idx++ {
+		if colortableFormat == "new" {
+			if err := binary.Write(writer, endian, ct.StructureId[idx]); err != nil {
+				return err
+			}
+		}
+		if err := writeFsAnnotString(writer, ct.Name[idx]); err != nil {
+			return err
+		}
+		if err := binary.Write(writer, endian, []int32{ct.R[idx], ct.G[idx], ct.B[idx], ct.A[idx]}); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
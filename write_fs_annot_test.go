@@ -0,0 +1,118 @@
+package neuro
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteRereadFsAnnotBothFormats(t *testing.T) {
+	annot := FsAnnot{VertexLabels: []int32{0, 197121, 197121, 2629140, 0}, Colortable: getTestFsColortable()}
+
+	for _, format := range []string{"old", "new"} {
+		annotFile := filepath.Join(t.TempDir(), "lh.test.annot")
+		if err := WriteFsAnnot(annotFile, annot, format); err != nil {
+			t.Fatalf("%s: WriteFsAnnot failed: %v", format, err)
+		}
+		reread, err := ReadFsAnnot(annotFile)
+		if err != nil {
+			t.Fatalf("%s: ReadFsAnnot failed: %v", format, err)
+		}
+		if diff := cmp.Diff(annot, reread); diff != "" {
+			t.Errorf("%s: %s", format, diff)
+		}
+	}
+}
+
+func TestWriteFsAnnotCustomStructureIds(t *testing.T) {
+	ct := getTestFsColortable()
+	ct.StructureId = []int32{0, 1001, 1024}
+	annot := FsAnnot{VertexLabels: []int32{2629140}, Colortable: ct}
+
+	var buf bytes.Buffer
+	if err := WriteFsAnnotTo(&buf, annot, "new"); err != nil {
+		t.Fatalf("WriteFsAnnotTo failed: %v", err)
+	}
+	reread, err := ReadFsAnnotFrom(&buf)
+	if err != nil {
+		t.Fatalf("ReadFsAnnotFrom failed: %v", err)
+	}
+	if diff := cmp.Diff(ct.StructureId, reread.Colortable.StructureId); diff != "" {
+		t.Error(diff)
+	}
+
+	// The old format cannot store these structure IDs.
+	if err := WriteFsAnnotTo(&bytes.Buffer{}, annot, "old"); err == nil {
+		t.Errorf("expected error for structure IDs in old format, got nil")
+	}
+}
+
+func TestWriteFsAnnotWithoutColortable(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFsAnnotTo(&buf, FsAnnot{VertexLabels: []int32{5, 6}}, "new"); err != nil {
+		t.Fatalf("WriteFsAnnotTo failed: %v", err)
+	}
+	reread, err := ReadFsAnnotFrom(&buf)
+	if err != nil {
+		t.Fatalf("ReadFsAnnotFrom failed: %v", err)
+	}
+	if diff := cmp.Diff([]int32{5, 6}, reread.VertexLabels); diff != "" {
+		t.Error(diff)
+	}
+	if reread.Colortable.NumEntries() != 0 {
+		t.Errorf("got %d colortable entries, wanted 0", reread.Colortable.NumEntries())
+	}
+}
+
+func TestWriteFsAnnotInvalid(t *testing.T) {
+	sameColor := getTestFsColortable()
+	sameColor.R[2], sameColor.G[2], sameColor.B[2] = 1, 2, 3
+	outOfRange := getTestFsColortable()
+	outOfRange.G[1] = 256
+	wrongLength := getTestFsColortable()
+	wrongLength.A = wrongLength.A[:2]
+
+	invalid := map[string]FsColortable{"same color": sameColor, "color out of range": outOfRange, "wrong slice length": wrongLength}
+	for name, ct := range invalid {
+		if err := WriteFsAnnotTo(&bytes.Buffer{}, FsAnnot{VertexLabels: []int32{0}, Colortable: ct}, "new"); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+
+	annotFile := filepath.Join(t.TempDir(), "lh.test.annot")
+	if err := WriteFsAnnot(annotFile, FsAnnot{Colortable: getTestFsColortable()}, "newest"); err == nil {
+		t.Errorf("expected error for invalid colortable format, got nil")
+	}
+	if _, err := os.Stat(annotFile); err == nil {
+		t.Errorf("expected no file to be created for invalid input")
+	}
+}
+
+func ExampleWriteFsAnnot() {
+	// A parcellation of a mesh with 4 vertices into 2 regions.
+	ct := FsColortable{
+		StructureId: []int32{0, 1},
+		Name:        []string{"anterior", "posterior"},
+		R:           []int32{255, 0},
+		G:           []int32{0, 0},
+		B:           []int32{0, 255},
+		A:           []int32{0, 0},
+	}
+	codes := ct.LabelCodes()
+	annot := FsAnnot{VertexLabels: []int32{codes[0], codes[0], codes[1], codes[1]}, Colortable: ct}
+
+	annotFile := filepath.Join(os.TempDir(), "lh.example.annot")
+	defer os.Remove(annotFile)
+	if err := WriteFsAnnot(annotFile, annot, "new"); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	reread, _ := ReadFsAnnot(annotFile)
+	fmt.Println(reread.VertexRegionNames())
+	// Output: [anterior anterior posterior posterior]
+}
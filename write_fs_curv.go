@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"os"
 )
@@ -74,13 +75,35 @@ func float32ToByte(f float32) []byte {
 //
 // Returns:
 //  - error: an error if one occurred, e.g., the slice was empty. Or nil otherwise.
-func WriteFsCurv(filename string, data[]float32) error {
+func WriteFsCurv(filename string, data []float32) error {
 
 	file, err := os.Create(filename)
-	defer file.Close()
 	if err != nil {
 		return err
 	}
+	defer file.Close()
+
+	if Verbosity >= 1 {
+		fmt.Printf("WriteFsCurv: Writing %d per-vertex descriptor values to file '%s'.\n", len(data), filename)
+	}
+
+	if err := WriteFsCurvTo(file, data); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// WriteFsCurvTo writes per-vertex data in FreeSurfer curv format to w.
+//
+// This is the io.Writer version of WriteFsCurv.
+//
+// Parameters:
+//  - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//  - data: the slice of float32 values.
+//
+// Returns:
+//  - error: an error if one occurred, or nil otherwise.
+func WriteFsCurvTo(w io.Writer, data []float32) error {
 
 	curvHdr := getCurvHeaderStruct(data)
 
@@ -90,15 +113,11 @@ func WriteFsCurv(filename string, data[]float32) error {
 		fmt.Printf("WriteFsCurv: curvHdr.NumValuesPerVertex: %d\n", curvHdr.NumValuesPerVertex)
 	}
 
-	err = binary.Write(file, binary.BigEndian, &curvHdr)
-    if err != nil {
-        return err
-    }
-
-	writer := bufio.NewWriter(file)
+	writer := bufio.NewWriter(w)
 
-	if Verbosity >= 1 {
-		fmt.Printf("WriteFsCurv: Writing %d per-vertex descriptor values to file '%s'.\n", len(data), filename)
+	err := binary.Write(writer, binary.BigEndian, &curvHdr)
+	if err != nil {
+		return err
 	}
 
 	numBytesWrittenTotal := 0
@@ -111,10 +130,8 @@ func WriteFsCurv(filename string, data[]float32) error {
 	}
 
 	if Verbosity >= 1 {
-		fmt.Printf("WriteFsCurv: Wrote %d bytes to file '%s'.\n", numBytesWrittenTotal, filename)
+		fmt.Printf("WriteFsCurv: Wrote %d data bytes.\n", numBytesWrittenTotal)
 	}
 
-	writer.Flush()
-
-	return nil
-}
\ No newline at end of file
+	return writer.Flush()
+}
@@ -1,6 +1,7 @@
 package neuro
 
 import (
+	"bytes"
 	"os"
 	"testing"
 
@@ -33,3 +34,28 @@ func TestWriteRereadCurv(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestWriteRereadCurvInMemory(t *testing.T) {
+
+	data := []float32{1.0, 2.0, 3.0, 4.0, 5.0}
+
+	var buf bytes.Buffer
+	err := WriteFsCurvTo(&buf, data)
+	if err != nil {
+		t.Errorf("WriteFsCurvTo failed: %v", err)
+	}
+
+	wantNumBytes := 15 + 4*len(data) // 3 magic bytes, 3 int32 header fields, then the data
+	if buf.Len() != wantNumBytes {
+		t.Errorf("got %d bytes written, wanted %d", buf.Len(), wantNumBytes)
+	}
+
+	data_reread, err := ReadFsCurvFrom(&buf)
+	if err != nil {
+		t.Errorf("ReadFsCurvFrom failed: %v", err)
+	}
+
+	if diff := cmp.Diff(data, data_reread); diff != "" {
+		t.Error(diff)
+	}
+}
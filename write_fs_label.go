@@ -0,0 +1,82 @@
+package neuro
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// checkFsLabel checks that the slices of a label have the same length, so it can be written.
+func checkFsLabel(label FsLabel) error {
+	n := len(label.ElementIndex)
+	if len(label.CoordX) != n || len(label.CoordY) != n || len(label.CoordZ) != n || len(label.Value) != n {
+		return fmt.Errorf("the slices of the label must all have length %d (the number of element indices), found %d, %d, %d and %d coordinates and values.", n, len(label.CoordX), len(label.CoordY), len(label.CoordZ), len(label.Value))
+	}
+	return nil
+}
+
+// WriteFsLabel writes a label to a file in FreeSurfer label format, e.g., a thresholded cluster or a region of interest to '<subject>/label/lh.myroi.label'.
+//
+// The file starts with the standard header line '#!ascii label  , from subject <subject> vox2ras=<coordinate system>', which contains
+// the SubjectName and Vox2Ras fields of the label, followed by the number of elements and one line per element with its index,
+// coordinates and value. This works for both surface labels, where the element indices are vertex indices, and volume labels.
+//
+// Parameters:
+//   - filepath: path to the output file. The directory must exist.
+//   - label: the label to write. If its Vox2Ras field is empty, 'TkReg' is written, like FreeSurfer does.
+//
+// Returns:
+//   - error: an error if one occurred, e.g., if the slices of the label have different lengths
+func WriteFsLabel(filepath string, label FsLabel) error {
+	if err := checkFsLabel(label); err != nil {
+		return fmt.Errorf("WriteFsLabel: %w", err)
+	}
+
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("WriteFsLabel: could not create label file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	if Verbosity >= 1 {
+		fmt.Printf("WriteFsLabel: Writing label with %d elements to file '%s'.\n", len(label.ElementIndex), filepath)
+	}
+
+	if err := WriteFsLabelTo(file, label); err != nil {
+		return fmt.Errorf("WriteFsLabel: failed to write label file '%s': %w", filepath, err)
+	}
+	return file.Sync()
+}
+
+// WriteFsLabelTo writes a label in FreeSurfer label format to w.
+//
+// This is the io.Writer version of WriteFsLabel, see there for details.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - label: the label to write
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteFsLabelTo(w io.Writer, label FsLabel) error {
+	if err := checkFsLabel(label); err != nil {
+		return fmt.Errorf("WriteFsLabelTo: %w", err)
+	}
+
+	vox2ras := label.Vox2Ras
+	if vox2ras == "" {
+		vox2ras = "TkReg"
+	}
+
+	writer := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(writer, "#!ascii label  , from subject %s vox2ras=%s\n%d\n", label.SubjectName, vox2ras, len(label.ElementIndex)); err != nil {
+		return err
+	}
+	for idx, elementIndex := range label.ElementIndex {
+		if _, err := fmt.Fprintf(writer, "%d  %.3f  %.3f  %.3f %.10f\n", elementIndex, label.CoordX[idx], label.CoordY[idx], label.CoordZ[idx], label.Value[idx]); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
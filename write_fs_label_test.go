@@ -0,0 +1,133 @@
+package neuro
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// getTestFsLabel returns a small surface label with values that can be written with the precision of the label format.
+func getTestFsLabel() FsLabel {
+	return FsLabel{
+		ElementIndex: []int32{5, 17, 230},
+		CoordX:       []float32{-1.5, 4.0, 10.125},
+		CoordY:       []float32{2.0, 5.0, -20.25},
+		CoordZ:       []float32{3.25, 6.0, 0.5},
+		Value:        []float32{0.0, 1.5, -2.75},
+		SubjectName:  "bert",
+		Vox2Ras:      "scanner",
+	}
+}
+
+func TestWriteRereadFsLabel(t *testing.T) {
+	label := getTestFsLabel()
+	labelFile := filepath.Join(t.TempDir(), "lh.test.label")
+	if err := WriteFsLabel(labelFile, label); err != nil {
+		t.Fatalf("WriteFsLabel failed: %v", err)
+	}
+	reread, err := ReadFsLabel(labelFile)
+	if err != nil {
+		t.Fatalf("ReadFsLabel failed: %v", err)
+	}
+	if diff := cmp.Diff(label, reread); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWriteRereadFsLabelEmpty(t *testing.T) {
+	// Unused regions of an annotation have empty labels.
+	annot := FsAnnot{VertexLabels: []int32{0, 0}, Colortable: getTestFsColortable()}
+	label, err := annot.RegionLabel("precentral")
+	if err != nil {
+		t.Fatalf("RegionLabel failed: %v", err)
+	}
+	label.SubjectName = "bert"
+
+	var buf bytes.Buffer
+	if err := WriteFsLabelTo(&buf, label); err != nil {
+		t.Fatalf("WriteFsLabelTo failed: %v", err)
+	}
+	reread, err := ReadFsLabelFrom(&buf)
+	if err != nil {
+		t.Fatalf("ReadFsLabelFrom failed: %v", err)
+	}
+	if len(reread.ElementIndex) != 0 || len(reread.CoordX) != 0 || len(reread.Value) != 0 {
+		t.Errorf("got %d elements, wanted 0", len(reread.ElementIndex))
+	}
+	if reread.SubjectName != "bert" {
+		t.Errorf("got subject name '%s', wanted 'bert'", reread.SubjectName)
+	}
+}
+
+func TestWriteFsLabelToHeader(t *testing.T) {
+	label := getTestFsLabel()
+	label.SubjectName = ""
+	label.Vox2Ras = ""
+
+	var buf bytes.Buffer
+	if err := WriteFsLabelTo(&buf, label); err != nil {
+		t.Fatalf("WriteFsLabelTo failed: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "#!ascii label  , from subject  vox2ras=TkReg" || lines[1] != "3" || lines[2] != "5  -1.500  2.000  3.250 0.0000000000" {
+		t.Errorf("unexpected label file content:\n%s", buf.String())
+	}
+
+	reread, err := ReadFsLabelFrom(&buf)
+	if err != nil {
+		t.Fatalf("ReadFsLabelFrom failed: %v", err)
+	}
+	if reread.SubjectName != "" || reread.Vox2Ras != "TkReg" {
+		t.Errorf("got subject '%s' and vox2ras '%s', wanted '' and 'TkReg'", reread.SubjectName, reread.Vox2Ras)
+	}
+}
+
+func TestWriteFsLabelInvalid(t *testing.T) {
+	label := getTestFsLabel()
+	label.Value = label.Value[:2]
+
+	labelFile := filepath.Join(t.TempDir(), "lh.test.label")
+	if err := WriteFsLabel(labelFile, label); err == nil {
+		t.Errorf("expected error for label with slices of different lengths, got nil")
+	}
+	if _, err := os.Stat(labelFile); err == nil {
+		t.Errorf("WriteFsLabel created the file '%s' for an invalid label", labelFile)
+	}
+}
+
+func TestReadFsLabelHeaderWithoutFields(t *testing.T) {
+	label, err := ReadFsLabelFrom(strings.NewReader("#!ascii label\n1\n5  -1.5  2.0  3.25 0.0000000000\n"))
+	if err != nil {
+		t.Fatalf("ReadFsLabelFrom failed: %v", err)
+	}
+	if label.SubjectName != "" || label.Vox2Ras != "" {
+		t.Errorf("got subject '%s' and vox2ras '%s', wanted empty strings", label.SubjectName, label.Vox2Ras)
+	}
+}
+
+func ExampleWriteFsLabel() {
+	// Create a label for the vertices 0 to 2 of a surface, with the coordinates of the vertices.
+	label := FsLabel{
+		ElementIndex: []int32{0, 1, 2},
+		CoordX:       []float32{1.0, 1.0, -1.0},
+		CoordY:       []float32{1.0, -1.0, 1.0},
+		CoordZ:       []float32{1.0, 1.0, 1.0},
+		Value:        []float32{0.0, 0.0, 0.0},
+		SubjectName:  "bert",
+	}
+
+	var buf bytes.Buffer
+	_ = WriteFsLabelTo(&buf, label)
+	fmt.Print(buf.String())
+	// Output:
+	// #!ascii label  , from subject bert vox2ras=TkReg
+	// 3
+	// 0  1.000  1.000  1.000 0.0000000000
+	// 1  1.000  -1.000  1.000 0.0000000000
+	// 2  -1.000  1.000  1.000 0.0000000000
+}
@@ -0,0 +1,183 @@
+package neuro
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// getMghNumValues computes the number of voxel values described by the dimensions in an MghHeader.
+//
+// Parameters:
+//   - hdr: MghHeader struct containing the header data
+//
+// Returns:
+//   - int64: the number of values, i.e., the product of the 4 dimension lengths
+func getMghNumValues(hdr MghHeader) int64 {
+	return int64(hdr.Dim1Length) * int64(hdr.Dim2Length) * int64(hdr.Dim3Length) * int64(hdr.Dim4Length)
+}
+
+// writeFsMghData writes the valid data slice of an MghData struct to w, in big endian byte order.
+//
+// Parameters:
+//   - w: the writer to write to
+//   - hdr: MghHeader struct describing the data, used to determine the data type and the expected number of values
+//   - data: the MghData struct containing the data
+//
+// Returns:
+//   - error: an error if one occurred, e.g., if the data type is unsupported or the data length does not match the header dimensions
+func writeFsMghData(w io.Writer, hdr MghHeader, data MghData) error {
+	endian := binary.BigEndian
+	numValues := getMghNumValues(hdr)
+
+	var dataSlice interface{}
+	var dataLength int
+	switch dt := hdr.MghDataType; dt {
+	case MRI_UCHAR:
+		dataSlice, dataLength = data.DataMriUchar, len(data.DataMriUchar)
+	case MRI_INT:
+		dataSlice, dataLength = data.DataMriInt, len(data.DataMriInt)
+	case MRI_FLOAT:
+		dataSlice, dataLength = data.DataMriFloat, len(data.DataMriFloat)
+	case MRI_SHORT:
+		dataSlice, dataLength = data.DataMriShort, len(data.DataMriShort)
+	case MRI_LONG:
+		dataSlice, dataLength = data.DataMriLong, len(data.DataMriLong)
+	case MRI_USHRT:
+		dataSlice, dataLength = data.DataMriUshort, len(data.DataMriUshort)
+	default:
+		return &UnsupportedMghDataTypeError{Code: dt}
+	}
+
+	if int64(dataLength) != numValues {
+		dataTypeName, _ := getMghDataTypeName(hdr.MghDataType)
+		return fmt.Errorf("writeFsMghData: header dimensions require %d values, but the %s data slice has length %d.", numValues, dataTypeName, dataLength)
+	}
+
+	return binary.Write(w, endian, dataSlice)
+}
+
+// checkMghDataType checks that the data type codes in header and data of an Mgh struct match, and that the type is supported for writing.
+//
+// Parameters:
+//   - mgh: the Mgh struct to check
+//
+// Returns:
+//   - error: an error if the data type is invalid, nil otherwise
+func checkMghDataType(mgh Mgh) error {
+	if mgh.Header.MghDataType != mgh.Data.MghDataType {
+		return fmt.Errorf("MGH header declares data type code %d, but data has type code %d.", mgh.Header.MghDataType, mgh.Data.MghDataType)
+	}
+	_, err := getMghDataTypeName(mgh.Header.MghDataType)
+	return err
+}
+
+// writeFsMghFooter writes an MghFooter to w.
+//
+// The scan parameters are written if the footer has scan parameters or tags, because the tags can only follow the scan parameters. A footer without both is not written at all.
+//
+// Parameters:
+//   - w: the writer to write to
+//   - footer: the footer to write
+//
+// Returns:
+//   - error: an error if one occurred
+func writeFsMghFooter(w io.Writer, footer MghFooter) error {
+	if !footer.HasScanParameters && len(footer.Tags) == 0 {
+		return nil
+	}
+	scanParams := [5]float32{footer.TR, footer.FlipAngle, footer.TE, footer.TI, footer.FoV}
+	if err := binary.Write(w, binary.BigEndian, &scanParams); err != nil {
+		return err
+	}
+	return writeFsTags(w, footer.Tags)
+}
+
+// WriteFsMgh writes an Mgh struct to a file in FreeSurfer MGH or MGZ format.
+//
+// The header is written from mgh.Header, and the data is taken from the field of mgh.Data that matches the MghDataType of the header.
+// The footer, including scan parameters and tags like the command line history, is preserved.
+//
+// Parameters:
+//   - filepath: path to the output file, e.g. '<subject>/mri/brain.mgz'. The directory must exist.
+//   - mgh: the Mgh struct to write. The MghDataType of header and data must match, and the length of the data slice must match the header dimensions.
+//   - compress: Whether to write gzip-compressed MGZ format. If "auto", the file extension is used to determine whether to compress. If not "auto", it has to be "yes"/"mgz" or "no"/"mgh" to force MGZ or MGH format, respectively.
+//
+// Returns:
+//   - error: an error if one occurred, nil otherwise
+func WriteFsMgh(filepath string, mgh Mgh, compress string) error {
+
+	compress = getIsGzippedMgh(compress)
+	if !(compress == "yes" || compress == "no" || compress == "auto") {
+		return fmt.Errorf("WriteFsMgh: invalid value '%s' for parameter compress, must be one of 'yes'/'mgz', 'no'/'mgh' or 'auto'.", compress)
+	}
+	doCompress := getIsGzipped(filepath, compress)
+
+	// Check before creating the file, so no empty file is left behind.
+	if err := checkMghDataType(mgh); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("WriteFsMgh: could not create MGH file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	if Verbosity >= 1 {
+		fmt.Printf("WriteFsMgh: Writing %d values with data type code %d to file '%s', compress=%t.\n", getMghNumValues(mgh.Header), mgh.Header.MghDataType, filepath, doCompress)
+	}
+
+	if err := WriteFsMghTo(file, mgh, doCompress); err != nil {
+		return fmt.Errorf("WriteFsMgh: failed to write MGH file '%s': %w", filepath, err)
+	}
+	return file.Sync()
+}
+
+// WriteFsMghTo writes an Mgh struct to w in FreeSurfer MGH or MGZ format.
+//
+// This is the io.Writer version of WriteFsMgh, see there for details.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - mgh: the Mgh struct to write. The MghDataType of header and data must match, and the length of the data slice must match the header dimensions.
+//   - compress: Whether to write gzip-compressed MGZ format (true) or uncompressed MGH format (false).
+//
+// Returns:
+//   - error: an error if one occurred, nil otherwise
+func WriteFsMghTo(w io.Writer, mgh Mgh, compress bool) error {
+
+	if err := checkMghDataType(mgh); err != nil {
+		return err
+	}
+
+	var gzipWriter *gzip.Writer
+	if compress {
+		gzipWriter = gzip.NewWriter(w)
+		w = gzipWriter
+	}
+	bw := bufio.NewWriter(w)
+
+	if err := binary.Write(bw, binary.BigEndian, &mgh.Header); err != nil {
+		return fmt.Errorf("WriteFsMghTo: failed to write MGH header: %w", err)
+	}
+
+	if err := writeFsMghData(bw, mgh.Header, mgh.Data); err != nil {
+		return fmt.Errorf("WriteFsMghTo: failed to write MGH data: %w", err)
+	}
+
+	if err := writeFsMghFooter(bw, mgh.Footer); err != nil {
+		return fmt.Errorf("WriteFsMghTo: failed to write MGH footer: %w", err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if gzipWriter != nil {
+		return gzipWriter.Close()
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package neuro
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteRereadMgz(t *testing.T) {
+
+	mgh, err := ReadFsMgh("testdata/brain.mgz", "auto")
+	if err != nil {
+		t.Fatalf("ReadFsMgh failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	for _, outFile := range []string{"brain.mgz", "brain.mgh"} {
+		mgh_file_name := filepath.Join(tmpDir, outFile)
+
+		err = WriteFsMgh(mgh_file_name, mgh, "auto")
+		if err != nil {
+			t.Errorf("WriteFsMgh failed for file '%s': %v", outFile, err)
+		}
+
+		mgh_reread, err := ReadFsMgh(mgh_file_name, "auto")
+		if err != nil {
+			t.Errorf("ReadFsMgh failed for file '%s': %v", outFile, err)
+		}
+
+		// Compare the data slice directly, cmp.Diff is too slow for volumes of this size.
+		if diff := cmp.Diff(mgh.Header, mgh_reread.Header); diff != "" {
+			t.Errorf("file '%s': %s", outFile, diff)
+		}
+		if !bytes.Equal(mgh.Data.DataMriUchar, mgh_reread.Data.DataMriUchar) {
+			t.Errorf("file '%s': re-read MRI_UCHAR data differs from written data", outFile)
+		}
+		if diff := cmp.Diff(mgh.Footer, mgh_reread.Footer); diff != "" {
+			t.Errorf("file '%s': %s", outFile, diff)
+		}
+	}
+}
+
+func TestWriteMgzIsCompressed(t *testing.T) {
+
+	mgh, _ := ReadFsMgh("testdata/brain.mgz", "auto")
+
+	mgz_file_name := filepath.Join(t.TempDir(), "brain.mgh")
+	err := WriteFsMgh(mgz_file_name, mgh, "mgz") // force compression, despite the file extension
+	if err != nil {
+		t.Errorf("WriteFsMgh failed: %v", err)
+	}
+
+	stat, err := os.Stat(mgz_file_name)
+	if err != nil {
+		t.Errorf("Stat failed: %v", err)
+	}
+	numBytesUncompressed := int64(284 + 256*256*256)
+	if stat.Size() >= numBytesUncompressed {
+		t.Errorf("got file size %d for compressed output, wanted less than %d", stat.Size(), numBytesUncompressed)
+	}
+}
+
+func TestWriteRereadMghAllDataTypes(t *testing.T) {
+
+	hdr := MghHeader{MghVersion: 1, Dim1Length: 2, Dim2Length: 3, Dim3Length: 1, Dim4Length: 1}
+
+	datas := []MghData{
+		{DataMriUchar: []uint8{0, 1, 2, 3, 4, 255}, MghDataType: MRI_UCHAR},
+		{DataMriInt: []int32{-1, 0, 1, 2, 3, 70000}, MghDataType: MRI_INT},
+		{DataMriFloat: []float32{-1.5, 0.0, 1.5, 2.5, 3.5, 4.5}, MghDataType: MRI_FLOAT},
+		{DataMriShort: []int16{-300, 0, 1, 2, 3, 300}, MghDataType: MRI_SHORT},
+		{DataMriLong: []int64{-5000000000, 0, 1, 2, 3, 5000000000}, MghDataType: MRI_LONG},
+		{DataMriUshort: []uint16{0, 1, 2, 3, 300, 65535}, MghDataType: MRI_USHRT},
+	}
+
+	for _, data := range datas {
+		hdr.MghDataType = data.MghDataType
+		mgh := Mgh{Header: hdr, Data: data}
+
+		mgh_file_name := filepath.Join(t.TempDir(), "vol.mgh")
+		err := WriteFsMgh(mgh_file_name, mgh, "no")
+		if err != nil {
+			t.Errorf("WriteFsMgh failed for data type %d: %v", data.MghDataType, err)
+		}
+
+		mgh_reread, err := ReadFsMgh(mgh_file_name, "no")
+		if err != nil {
+			t.Errorf("ReadFsMgh failed for data type %d: %v", data.MghDataType, err)
+		}
+
+		if diff := cmp.Diff(mgh, mgh_reread); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestWriteMghInvalidDataLength(t *testing.T) {
+
+	hdr := MghHeader{MghVersion: 1, Dim1Length: 2, Dim2Length: 2, Dim3Length: 2, Dim4Length: 1, MghDataType: MRI_FLOAT}
+	data := MghData{DataMriFloat: []float32{1.0, 2.0}, MghDataType: MRI_FLOAT}
+
+	err := WriteFsMgh(filepath.Join(t.TempDir(), "vol.mgh"), Mgh{Header: hdr, Data: data}, "auto")
+	if err == nil {
+		t.Errorf("expected error for data length not matching header dimensions, got nil")
+	}
+}
+
+func TestWriteMghUnsupportedDataType(t *testing.T) {
+
+	hdr := MghHeader{MghVersion: 1, Dim1Length: 2, Dim2Length: 1, Dim3Length: 1, Dim4Length: 1, MghDataType: MRI_TENSOR}
+	data := MghData{MghDataType: MRI_TENSOR}
+
+	err := WriteFsMghTo(&bytes.Buffer{}, Mgh{Header: hdr, Data: data}, false)
+	var dtErr *UnsupportedMghDataTypeError
+	if !errors.As(err, &dtErr) || dtErr.Code != MRI_TENSOR {
+		t.Errorf("expected UnsupportedMghDataTypeError with code %d, got %v", MRI_TENSOR, err)
+	}
+}
@@ -0,0 +1,91 @@
+package neuro
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// checkFsPatch checks that the slices of a patch have matching lengths and the vertex indices are valid, so it can be written.
+func checkFsPatch(patch FsPatch) error {
+	n := len(patch.VertexIndices)
+	if len(patch.Vertices) != 3*n || len(patch.IsBorder) != n {
+		return fmt.Errorf("the patch has %d vertex indices, so it must have %d vertex coordinates and %d border flags, found %d and %d.", n, 3*n, n, len(patch.Vertices), len(patch.IsBorder))
+	}
+	for idx, vertexIndex := range patch.VertexIndices {
+		if vertexIndex < 0 {
+			return fmt.Errorf("invalid negative index %d of patch vertex %d.", vertexIndex, idx)
+		}
+	}
+	return nil
+}
+
+// WriteFsPatch writes a patch to a file in FreeSurfer patch format, e.g., a flattened surface patch for use with freeview or mris_flatten.
+//
+// The current patch format with float32 coordinates is written.
+//
+// Parameters:
+//   - filepath: path to the output file, e.g., 'lh.occip.patch.flat'. The directory must exist.
+//   - patch: the patch to write
+//
+// Returns:
+//   - error: an error if one occurred, e.g., if the slices of the patch have different lengths
+func WriteFsPatch(filepath string, patch FsPatch) error {
+	if err := checkFsPatch(patch); err != nil {
+		return fmt.Errorf("WriteFsPatch: %w", err)
+	}
+
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("WriteFsPatch: could not create patch file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	if Verbosity >= 1 {
+		fmt.Printf("WriteFsPatch: Writing patch with %d vertices to file '%s'.\n", patch.NumVertices(), filepath)
+	}
+
+	if err := WriteFsPatchTo(file, patch); err != nil {
+		return fmt.Errorf("WriteFsPatch: failed to write patch file '%s': %w", filepath, err)
+	}
+	return file.Sync()
+}
+
+// WriteFsPatchTo writes a patch in FreeSurfer patch format to w.
+//
+// This is the io.Writer version of WriteFsPatch, see there for details.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - patch: the patch to write
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteFsPatchTo(w io.Writer, patch FsPatch) error {
+	if err := checkFsPatch(patch); err != nil {
+		return fmt.Errorf("WriteFsPatchTo: %w", err)
+	}
+
+	endian := binary.BigEndian
+	writer := bufio.NewWriter(w)
+
+	if err := binary.Write(writer, endian, []int32{-1, int32(patch.NumVertices())}); err != nil {
+		return err
+	}
+	for idx, vertexIndex := range patch.VertexIndices {
+		// The index is stored starting at 1, so that it can be negated for border vertices.
+		vertexId := vertexIndex + 1
+		if patch.IsBorder[idx] {
+			vertexId = -vertexId
+		}
+		if err := binary.Write(writer, endian, vertexId); err != nil {
+			return err
+		}
+		if err := binary.Write(writer, endian, patch.Vertices[3*idx:3*idx+3]); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
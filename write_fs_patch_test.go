@@ -0,0 +1,67 @@
+package neuro
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteRereadFsPatch(t *testing.T) {
+	patch := getTestFsPatch()
+	patchFile := filepath.Join(t.TempDir(), "lh.test.patch.flat")
+	if err := WriteFsPatch(patchFile, patch); err != nil {
+		t.Fatalf("WriteFsPatch failed: %v", err)
+	}
+	reread, err := ReadFsPatch(patchFile)
+	if err != nil {
+		t.Fatalf("ReadFsPatch failed: %v", err)
+	}
+	if diff := cmp.Diff(patch, reread); diff != "" {
+		t.Error(diff)
+	}
+
+	reread, err = ReadFsPatchFS(os.DirFS(filepath.Dir(patchFile)), filepath.Base(patchFile))
+	if err != nil {
+		t.Fatalf("ReadFsPatchFS failed: %v", err)
+	}
+	if diff := cmp.Diff(patch, reread); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWriteFsPatchInvalid(t *testing.T) {
+	patchFile := filepath.Join(t.TempDir(), "lh.invalid.patch")
+
+	patch := getTestFsPatch()
+	patch.IsBorder = patch.IsBorder[:3]
+	if err := WriteFsPatch(patchFile, patch); err == nil {
+		t.Errorf("expected error for patch with too few border flags, got nil")
+	}
+
+	patch = getTestFsPatch()
+	patch.VertexIndices[1] = -2
+	if err := WriteFsPatch(patchFile, patch); err == nil {
+		t.Errorf("expected error for patch with negative vertex index, got nil")
+	}
+
+	if _, err := os.Stat(patchFile); err == nil {
+		t.Errorf("WriteFsPatch created the file '%s' for an invalid patch", patchFile)
+	}
+}
+
+func ExampleFsPatch_ToMesh() {
+	// A flat patch of one side of the cube, e.g., read with ReadFsPatch. Vertex 2 of the patch is vertex 3 of the cube.
+	patch := FsPatch{
+		Vertices:      []float32{1.0, 1.0, 0.0, -1.0, 1.0, 0.0, -1.0, -1.0, 0.0, 1.0, -1.0, 0.0},
+		VertexIndices: []int32{0, 2, 3, 1},
+		IsBorder:      []bool{true, true, true, true},
+	}
+
+	// Get the faces of the patch from the surface it was cut from, e.g., to plot a 2D flatmap.
+	mesh, _ := patch.ToMesh(GenerateCube())
+	fmt.Printf("Patch mesh has %d vertices and %d faces.\n", NumVertices(mesh), NumFaces(mesh))
+	// Output: Patch mesh has 4 vertices and 2 faces.
+}
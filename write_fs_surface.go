@@ -0,0 +1,177 @@
+package neuro
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// getDefaultSurfaceMetadata returns the metadata written by WriteFsSurface, with a created line like the one FreeSurfer writes.
+func getDefaultSurfaceMetadata() SurfaceMetadata {
+	return SurfaceMetadata{CreatedLine: fmt.Sprintf("created by neuro on %s", time.Now().Format(time.ANSIC))}
+}
+
+// checkFsSurface checks that a mesh and its metadata can be written in FreeSurfer surface format.
+func checkFsSurface(mesh Mesh, metadata SurfaceMetadata) error {
+	if len(mesh.Vertices)%3 != 0 || len(mesh.Faces)%3 != 0 {
+		return fmt.Errorf("invalid mesh with %d vertex coordinates and %d face indices, both must be multiples of 3.", len(mesh.Vertices), len(mesh.Faces))
+	}
+	numVertices := int32(len(mesh.Vertices) / 3)
+	for _, vertexIndex := range mesh.Faces {
+		if vertexIndex < 0 || vertexIndex >= numVertices {
+			return fmt.Errorf("face references vertex %d, but the mesh has only %d vertices.", vertexIndex, numVertices)
+		}
+	}
+	if strings.Contains(metadata.CreatedLine, "\n") || strings.Contains(metadata.CommentLine, "\n") || strings.Contains(metadata.VolumeGeometry.Filename, "\n") {
+		return fmt.Errorf("the created line, the comment line and the volume geometry filename must not contain newlines.")
+	}
+	return nil
+}
+
+// writeVolumeGeometry writes the volume geometry text block of the surface footer, in the format FreeSurfer uses.
+func writeVolumeGeometry(w io.Writer, vg VolumeGeometry) error {
+	valid := "valid = 0  # volume info invalid"
+	if vg.Valid {
+		valid = "valid = 1  # volume info valid"
+	}
+	_, err := fmt.Fprintf(w, "%s\nfilename = %s\nvolume = %d %d %d\n", valid, vg.Filename, vg.Dims[0], vg.Dims[1], vg.Dims[2])
+	if err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		key    string
+		values [3]float64
+	}{{"voxelsize", vg.VoxelSize}, {"xras  ", vg.XRas}, {"yras  ", vg.YRas}, {"zras  ", vg.ZRas}, {"cras  ", vg.CRas}} {
+		if _, err := fmt.Fprintf(w, "%s = %.15e %.15e %.15e\n", field.key, field.values[0], field.values[1], field.values[2]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFsSurfaceFooter writes the footer of a surface file: the useRealRAS flag and the volume geometry, if any, followed by the other tags.
+func writeFsSurfaceFooter(w io.Writer, metadata SurfaceMetadata) error {
+	endian := binary.BigEndian
+	if metadata.HasVolumeGeometry || metadata.UseRealRAS {
+		var useRealRAS int32
+		if metadata.UseRealRAS {
+			useRealRAS = 1
+		}
+		if err := binary.Write(w, endian, []int32{TAG_OLD_USEREALRAS, useRealRAS}); err != nil {
+			return err
+		}
+	}
+	if metadata.HasVolumeGeometry {
+		if err := binary.Write(w, endian, TAG_OLD_SURF_GEOM); err != nil {
+			return err
+		}
+		if err := writeVolumeGeometry(w, metadata.VolumeGeometry); err != nil {
+			return err
+		}
+	}
+	return writeFsTags(w, metadata.Tags)
+}
+
+// WriteFsSurface writes a mesh to a file in FreeSurfer triangular surface format, e.g., a smoothed or transformed surface to '<subject>/surf/lh.mysurface'.
+//
+// The file can be used with FreeSurfer tools like freeview and mris_info. The header contains the created line 'created by neuro on <date>'.
+// Use WriteFsSurfaceWithMetadata to write the header of a surface read with ReadFsSurfaceWithMetadata instead, so that the file is
+// byte-identical to the original if the mesh has not changed.
+//
+// Parameters:
+//   - filepath: path to the output file. The directory must exist.
+//   - mesh: the mesh to write
+//
+// Returns:
+//   - error: an error if one occurred, e.g., if a face references a vertex that does not exist
+func WriteFsSurface(filepath string, mesh Mesh) error {
+	return WriteFsSurfaceWithMetadata(filepath, mesh, getDefaultSurfaceMetadata())
+}
+
+// WriteFsSurfaceTo writes a mesh in FreeSurfer triangular surface format to w.
+//
+// This is the io.Writer version of WriteFsSurface, see there for details.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - mesh: the mesh to write
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteFsSurfaceTo(w io.Writer, mesh Mesh) error {
+	return WriteFsSurfaceWithMetadataTo(w, mesh, getDefaultSurfaceMetadata())
+}
+
+// WriteFsSurfaceWithMetadata writes a mesh to a file in FreeSurfer triangular surface format, with the given header and footer metadata.
+//
+// The footer contains the useRealRAS flag and the volume geometry if HasVolumeGeometry is set, followed by the tags, like in the files written by FreeSurfer.
+//
+// Parameters:
+//   - filepath: path to the output file. The directory must exist.
+//   - mesh: the mesh to write
+//   - metadata: the header and footer metadata, e.g., from ReadFsSurfaceWithMetadata. The lines and the volume geometry filename must not contain newlines.
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteFsSurfaceWithMetadata(filepath string, mesh Mesh, metadata SurfaceMetadata) error {
+	if err := checkFsSurface(mesh, metadata); err != nil {
+		return fmt.Errorf("WriteFsSurfaceWithMetadata: %w", err)
+	}
+
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("WriteFsSurfaceWithMetadata: could not create surface file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	if Verbosity >= 1 {
+		fmt.Printf("WriteFsSurfaceWithMetadata: Writing mesh with %d vertices and %d faces to file '%s'.\n", NumVertices(mesh), NumFaces(mesh), filepath)
+	}
+
+	if err := WriteFsSurfaceWithMetadataTo(file, mesh, metadata); err != nil {
+		return fmt.Errorf("WriteFsSurfaceWithMetadata: failed to write surface file '%s': %w", filepath, err)
+	}
+	return file.Sync()
+}
+
+// WriteFsSurfaceWithMetadataTo writes a mesh in FreeSurfer triangular surface format with the given header and footer metadata to w, see WriteFsSurfaceWithMetadata.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - mesh: the mesh to write
+//   - metadata: the header and footer metadata
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteFsSurfaceWithMetadataTo(w io.Writer, mesh Mesh, metadata SurfaceMetadata) error {
+	if err := checkFsSurface(mesh, metadata); err != nil {
+		return fmt.Errorf("WriteFsSurfaceWithMetadataTo: %w", err)
+	}
+
+	endian := binary.BigEndian
+	writer := bufio.NewWriter(w)
+
+	if _, err := writer.Write([]byte{255, 255, 254}); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(writer, "%s\n%s\n", metadata.CreatedLine, metadata.CommentLine); err != nil {
+		return err
+	}
+	if err := binary.Write(writer, endian, []int32{int32(len(mesh.Vertices) / 3), int32(len(mesh.Faces) / 3)}); err != nil {
+		return err
+	}
+	if err := binary.Write(writer, endian, mesh.Vertices); err != nil {
+		return err
+	}
+	if err := binary.Write(writer, endian, mesh.Faces); err != nil {
+		return err
+	}
+	if err := writeFsSurfaceFooter(writer, metadata); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,101 @@
+package neuro
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteRereadFsSurface(t *testing.T) {
+	cube := GenerateCube()
+	surfFile := filepath.Join(t.TempDir(), "lh.cube")
+	if err := WriteFsSurface(surfFile, cube); err != nil {
+		t.Fatalf("WriteFsSurface failed: %v", err)
+	}
+
+	mesh, metadata, err := ReadFsSurfaceWithMetadata(surfFile)
+	if err != nil {
+		t.Fatalf("ReadFsSurfaceWithMetadata failed: %v", err)
+	}
+	if diff := cmp.Diff(cube, mesh); diff != "" {
+		t.Error(diff)
+	}
+	if !strings.HasPrefix(metadata.CreatedLine, "created by neuro on ") || metadata.CommentLine != "" {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+}
+
+func TestWriteFsSurfaceByteIdentical(t *testing.T) {
+	original := getFsSurfaceBytes(GenerateCube())
+	mesh, metadata, err := ReadFsSurfaceWithMetadataFrom(bytes.NewReader(original))
+	if err != nil {
+		t.Fatalf("ReadFsSurfaceWithMetadataFrom failed: %v", err)
+	}
+	if metadata.CreatedLine != "created by neuro tests" {
+		t.Errorf("got created line '%s', wanted 'created by neuro tests'", metadata.CreatedLine)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFsSurfaceWithMetadataTo(&buf, mesh, metadata); err != nil {
+		t.Fatalf("WriteFsSurfaceWithMetadataTo failed: %v", err)
+	}
+	if !bytes.Equal(original, buf.Bytes()) {
+		t.Errorf("the written surface differs from the original")
+	}
+}
+
+func TestWriteFsSurfaceInvalid(t *testing.T) {
+	surfFile := filepath.Join(t.TempDir(), "lh.invalid")
+	invalidMeshes := map[string]Mesh{
+		"incomplete vertex": {Vertices: []float32{0, 0, 0, 1}, Faces: []int32{}},
+		"invalid face":      {Vertices: []float32{0, 0, 0, 1, 0, 0, 0, 1, 0}, Faces: []int32{0, 1, 3}},
+	}
+	for name, mesh := range invalidMeshes {
+		if err := WriteFsSurface(surfFile, mesh); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+	if _, err := os.Stat(surfFile); err == nil {
+		t.Errorf("WriteFsSurface created the file '%s' for an invalid mesh", surfFile)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFsSurfaceWithMetadataTo(&buf, GenerateCube(), SurfaceMetadata{CreatedLine: "two\nlines"}); err == nil {
+		t.Errorf("expected error for created line with newline, got nil")
+	}
+}
+
+func ExampleWriteFsSurface() {
+	surfFile := filepath.Join(os.TempDir(), "lh.cube")
+	defer os.Remove(surfFile)
+
+	// Write a mesh that can be loaded in freeview, and read it back.
+	if err := WriteFsSurface(surfFile, GenerateCube()); err != nil {
+		fmt.Println(err)
+	}
+	mesh, _ := ReadFsSurface(surfFile)
+
+	fmt.Printf("Mesh has %d vertices and %d faces.\n", NumVertices(mesh), NumFaces(mesh))
+	// Output: Mesh has 8 vertices and 12 faces.
+}
+
+func TestWriteFsSurfaceFooterByteIdentical(t *testing.T) {
+	original := append(getFsSurfaceBytes(GenerateCube()), getFsSurfaceFooterBytes("mris_make_surfaces bert lh")...)
+	mesh, metadata, err := ReadFsSurfaceWithMetadataFrom(bytes.NewReader(original))
+	if err != nil {
+		t.Fatalf("ReadFsSurfaceWithMetadataFrom failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFsSurfaceWithMetadataTo(&buf, mesh, metadata); err != nil {
+		t.Fatalf("WriteFsSurfaceWithMetadataTo failed: %v", err)
+	}
+	if !bytes.Equal(original, buf.Bytes()) {
+		t.Errorf("the written surface with footer differs from the original")
+	}
+}
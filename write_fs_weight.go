@@ -0,0 +1,89 @@
+package neuro
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxFsWeightVertices is the maximal number of vertices of data that can be written in weight format, which stores the vertex indices and the number of entries as 3-byte integers.
+const maxFsWeightVertices = 1<<24 - 1
+
+// WriteFsWeight writes dense per-vertex data to a file in FreeSurfer weight format ('.w' files).
+//
+// Weight files are sparse, so only the vertices with a value other than 0 are written. Read the file with ReadFsWeight to get the dense data back.
+//
+// Parameters:
+//   - filepath: path to the output file, e.g., 'lh.thickness.w'. The directory must exist.
+//   - data: the per-vertex values, one value for each vertex of the surface
+//
+// Returns:
+//   - error: an error if one occurred, e.g., if the data has more vertices than the format supports (16777215)
+func WriteFsWeight(filepath string, data []float32) error {
+	if len(data) > maxFsWeightVertices {
+		return fmt.Errorf("WriteFsWeight: the weight format supports at most %d vertices, found %d.", maxFsWeightVertices, len(data))
+	}
+
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("WriteFsWeight: could not create weight file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	if Verbosity >= 1 {
+		fmt.Printf("WriteFsWeight: Writing data for %d vertices to file '%s'.\n", len(data), filepath)
+	}
+
+	if err := WriteFsWeightTo(file, data); err != nil {
+		return fmt.Errorf("WriteFsWeight: failed to write weight file '%s': %w", filepath, err)
+	}
+	return file.Sync()
+}
+
+// WriteFsWeightTo writes dense per-vertex data in FreeSurfer weight format to w.
+//
+// This is the io.Writer version of WriteFsWeight, see there for details.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - data: the per-vertex values, one value for each vertex of the surface
+//
+// Returns:
+//   - error: an error if one occurred
+func WriteFsWeightTo(w io.Writer, data []float32) error {
+	if len(data) > maxFsWeightVertices {
+		return fmt.Errorf("WriteFsWeightTo: the weight format supports at most %d vertices, found %d.", maxFsWeightVertices, len(data))
+	}
+
+	numEntries := 0
+	for _, value := range data {
+		if value != 0 {
+			numEntries++
+		}
+	}
+
+	endian := binary.BigEndian
+	writer := bufio.NewWriter(w)
+
+	// The latency is always written as 0, like FreeSurfer does.
+	if err := binary.Write(writer, endian, int16(0)); err != nil {
+		return err
+	}
+	if err := writeInt3(writer, int32(numEntries)); err != nil {
+		return err
+	}
+	for vertexIndex, value := range data {
+		if value == 0 {
+			continue
+		}
+		if err := writeInt3(writer, int32(vertexIndex)); err != nil {
+			return err
+		}
+		if err := binary.Write(writer, endian, value); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,59 @@
+package neuro
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteRereadFsWeight(t *testing.T) {
+	data := []float32{0, -1.0, 0, 0, 2.5, 0.125}
+	weightFile := filepath.Join(t.TempDir(), "lh.test.w")
+	if err := WriteFsWeight(weightFile, data); err != nil {
+		t.Fatalf("WriteFsWeight failed: %v", err)
+	}
+
+	reread, err := ReadFsWeight(weightFile, len(data))
+	if err != nil {
+		t.Fatalf("ReadFsWeight failed: %v", err)
+	}
+	if diff := cmp.Diff(data, reread); diff != "" {
+		t.Error(diff)
+	}
+
+	reread, err = ReadFsWeightFS(os.DirFS(filepath.Dir(weightFile)), filepath.Base(weightFile), len(data))
+	if err != nil {
+		t.Fatalf("ReadFsWeightFS failed: %v", err)
+	}
+	if diff := cmp.Diff(data, reread); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWriteFsWeightToIsSparse(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFsWeightTo(&buf, []float32{0, -1.0, 0, 0, 2.5, 0}); err != nil {
+		t.Fatalf("WriteFsWeightTo failed: %v", err)
+	}
+	// Only the 2 non-zero values are written: 2 bytes latency, 3 bytes number of entries, and 3 + 4 bytes per entry.
+	want := []byte{0, 0, 0, 0, 2, 0, 0, 1, 0xbf, 0x80, 0, 0, 0, 0, 4, 0x40, 0x20, 0, 0}
+	if diff := cmp.Diff(want, buf.Bytes()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func ExampleWriteFsWeight() {
+	weightFile := filepath.Join(os.TempDir(), "lh.example.w")
+	defer os.Remove(weightFile)
+
+	// Write values for 2 of the 5 vertices of a surface, and read them back as dense per-vertex data.
+	_ = WriteFsWeight(weightFile, []float32{0.0, 1.5, 0.0, 0.0, 3.0})
+	data, _ := ReadFsWeight(weightFile, 5)
+
+	fmt.Println(data)
+	// Output: [0 1.5 0 0 3]
+}
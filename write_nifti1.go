@@ -0,0 +1,134 @@
+package neuro
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// prepareNifti1Header returns a copy of the header of nii, with all fields that describe the file layout and the data set from the extensions and the volume.
+//
+// Parameters:
+//   - nii: the Nifti1 struct
+//
+// Returns:
+//   - Nifti1Header: the header to write
+//   - error: an error if the volume is empty, its data type cannot be written, or a dimension is too long for NIfTI-1
+func prepareNifti1Header(nii Nifti1) (Nifti1Header, error) {
+	hdr := nii.Header
+	datatype, bitpix, err := getNiftiDataTypeForMri(nii.Volume.DataType())
+	if err != nil {
+		return hdr, err
+	}
+	if nii.Volume.Len() == 0 {
+		return hdr, fmt.Errorf("the volume contains no data, create it with NewVolume or NewVolumeFromMgh.")
+	}
+
+	var oldDim [8]int64
+	for idx, d := range hdr.Dim {
+		oldDim[idx] = int64(d)
+	}
+	dim := getNiftiDim(nii.Volume.Shape(), oldDim)
+	for idx, d := range dim {
+		if d > math.MaxInt16 {
+			return hdr, fmt.Errorf("the length %d of dimension %d exceeds the NIfTI-1 maximum of %d, use NIfTI-2 instead.", d, idx, math.MaxInt16)
+		}
+		hdr.Dim[idx] = int16(d)
+	}
+
+	hdr.SizeofHdr = 348
+	copy(hdr.Magic[:], "n+1\x00")
+	hdr.Datatype = datatype
+	hdr.Bitpix = bitpix
+	hdr.VoxOffset = float32(348 + getNiftiExtensionsSize(nii.Extensions))
+
+	// The volume contains the scaled values, so they must not be scaled again when the file is read.
+	if hdr.SclSlope != 0 {
+		hdr.SclSlope = 1
+		hdr.SclInter = 0
+	}
+	return hdr, nil
+}
+
+// WriteNifti1 writes a Nifti1 struct to a file in NIfTI-1 format.
+//
+// The file is written in little endian byte order, with the extensions and the data of nii.Volume. The fields of the header that
+// describe the layout of the file and the data (like Datatype, Bitpix, Dim and VoxOffset) are set from the volume and the extensions,
+// all other fields are written as they are. The data scaling is reset, because the volume contains scaled values.
+// Use ConvertMghToNifti to create a Nifti1 struct from MGH data.
+//
+// Parameters:
+//   - filepath: path to the output file, e.g. 'brain.nii' or 'brain.nii.gz'. The directory must exist.
+//   - nii: the Nifti1 struct to write
+//   - compress: Whether to write gzip-compressed data. If "auto", the data is compressed if the file extension is '.gz'. If not "auto", it has to be "yes" or "no".
+//
+// Returns:
+//   - error: an error if one occurred, nil otherwise
+func WriteNifti1(filepath string, nii Nifti1, compress string) error {
+	if !(compress == "yes" || compress == "no" || compress == "auto") {
+		return fmt.Errorf("WriteNifti1: invalid value '%s' for parameter compress, must be one of 'yes', 'no' or 'auto'.", compress)
+	}
+
+	if _, err := prepareNifti1Header(nii); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("WriteNifti1: could not create NIfTI-1 file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	if err := WriteNifti1To(file, nii, getIsGzipped(filepath, compress)); err != nil {
+		return fmt.Errorf("WriteNifti1: failed to write NIfTI-1 file '%s': %w", filepath, err)
+	}
+	return file.Sync()
+}
+
+// WriteNifti1To writes a Nifti1 struct to w in NIfTI-1 format.
+//
+// This is the io.Writer version of WriteNifti1, see there for details.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - nii: the Nifti1 struct to write
+//   - compress: Whether to write gzip-compressed data.
+//
+// Returns:
+//   - error: an error if one occurred, nil otherwise
+func WriteNifti1To(w io.Writer, nii Nifti1, compress bool) error {
+	hdr, err := prepareNifti1Header(nii)
+	if err != nil {
+		return err
+	}
+
+	var gzipWriter *gzip.Writer
+	if compress {
+		gzipWriter = gzip.NewWriter(w)
+		w = gzipWriter
+	}
+	bw := bufio.NewWriter(w)
+	order := binary.LittleEndian
+
+	if err := binary.Write(bw, order, &hdr); err != nil {
+		return fmt.Errorf("WriteNifti1To: failed to write NIfTI-1 header: %w", err)
+	}
+	if err := writeNiftiExtensions(bw, order, nii.Extensions); err != nil {
+		return fmt.Errorf("WriteNifti1To: failed to write NIfTI-1 extensions: %w", err)
+	}
+	if err := writeNiftiData(bw, order, nii.Volume); err != nil {
+		return fmt.Errorf("WriteNifti1To: failed to write NIfTI-1 data: %w", err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if gzipWriter != nil {
+		return gzipWriter.Close()
+	}
+	return nil
+}
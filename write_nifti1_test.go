@@ -0,0 +1,102 @@
+package neuro
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func getTestNifti1() Nifti1 {
+	volume, _ := NewVolumeFromMgh(getTestMgh4D())
+	hdr := Nifti1Header{QformCode: 1, SformCode: 1, Pixdim: [8]float32{1, 2, 2, 2, 1, 1, 1, 1}}
+	hdr.SrowX = [4]float32{2, 0, 0, -10}
+	hdr.SrowY = [4]float32{0, 2, 0, -20}
+	hdr.SrowZ = [4]float32{0, 0, 2, -30}
+	copy(hdr.Descrip[:], "written by neuro tests")
+	extensions := []NiftiExtension{{Code: 6, Data: []byte("a comment that needs padding")}}
+	return Nifti1{Header: hdr, Extensions: extensions, Volume: volume}
+}
+
+func TestWriteRereadNifti1InMemory(t *testing.T) {
+	nii := getTestNifti1()
+
+	var buf bytes.Buffer
+	if err := WriteNifti1To(&buf, nii, false); err != nil {
+		t.Fatalf("WriteNifti1To failed: %v", err)
+	}
+
+	// 348 bytes header, 4 bytes extender, 48 bytes extension block (8 + 28 bytes padded to 48), 120 float32 values.
+	if buf.Len() != 348+4+48+120*4 {
+		t.Errorf("got %d bytes, wanted %d", buf.Len(), 348+4+48+120*4)
+	}
+
+	reread, err := ReadNifti1From(&buf)
+	if err != nil {
+		t.Fatalf("ReadNifti1From failed: %v", err)
+	}
+	if diff := cmp.Diff(nii.Volume.AsFloat32(), reread.Volume.AsFloat32()); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([8]int16{4, 4, 3, 2, 5, 1, 1, 1}, reread.Header.Dim); diff != "" {
+		t.Error(diff)
+	}
+	if reread.Header.VoxOffset != 400 || reread.Header.Datatype != NIFTI_TYPE_FLOAT32 || reread.Header.Bitpix != 32 {
+		t.Errorf("got VoxOffset=%f, Datatype=%d, Bitpix=%d, wanted 400, %d, 32", reread.Header.VoxOffset, reread.Header.Datatype, reread.Header.Bitpix, NIFTI_TYPE_FLOAT32)
+	}
+	if reread.Header.Description() != "written by neuro tests" {
+		t.Errorf("got description '%s', wanted '%s'", reread.Header.Description(), "written by neuro tests")
+	}
+	checkMatrixAlmostEqual(t, "Vox2Ras", reread.Header.Vox2Ras(), nii.Header.Vox2Ras())
+	if len(reread.Extensions) != 1 || reread.Extensions[0].Code != 6 {
+		t.Errorf("got extensions %v, wanted one extension with code 6", reread.Extensions)
+	}
+}
+
+func TestWriteRereadNifti1File(t *testing.T) {
+	nii := getTestNifti1()
+	nii.Header.SclSlope = 2.0
+
+	for _, fileName := range []string{"vol.nii", "vol.nii.gz"} {
+		niiFile := filepath.Join(t.TempDir(), fileName)
+		if err := WriteNifti1(niiFile, nii, "auto"); err != nil {
+			t.Fatalf("WriteNifti1 failed: %v", err)
+		}
+		reread, err := ReadNifti1(niiFile)
+		if err != nil {
+			t.Fatalf("ReadNifti1 failed: %v", err)
+		}
+		// The scaling is reset, so the values are not scaled twice.
+		if reread.Header.SclSlope != 1.0 || reread.Header.SclInter != 0.0 {
+			t.Errorf("got SclSlope=%f, SclInter=%f, wanted 1 and 0", reread.Header.SclSlope, reread.Header.SclInter)
+		}
+		if diff := cmp.Diff(nii.Volume.Data(), reread.Volume.Data()); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestWriteNifti1Invalid(t *testing.T) {
+	if err := WriteNifti1To(&bytes.Buffer{}, Nifti1{}, false); err == nil {
+		t.Errorf("expected error for empty volume, got nil")
+	}
+
+	nii := getTestNifti1()
+	if err := WriteNifti1(filepath.Join(t.TempDir(), "vol.nii"), nii, "maybe"); err == nil {
+		t.Errorf("expected error for invalid compress value, got nil")
+	}
+
+	// The dimensions of NIfTI-1 are limited to 32767.
+	large, _ := NewVolume([4]int{40000, 1, 1, 1}, MRI_UCHAR)
+	if err := WriteNifti1To(&bytes.Buffer{}, Nifti1{Volume: large}, false); err == nil {
+		t.Errorf("expected error for too long dimension, got nil")
+	}
+
+	nii.Volume = Volume{data: MghData{MghDataType: MRI_TENSOR}, shape: [4]int{1, 1, 1, 1}}
+	var dtErr *UnsupportedMghDataTypeError
+	if err := WriteNifti1To(&bytes.Buffer{}, nii, false); !errors.As(err, &dtErr) {
+		t.Errorf("expected UnsupportedMghDataTypeError, got %v", err)
+	}
+}
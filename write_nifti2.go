@@ -0,0 +1,121 @@
+package neuro
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// prepareNifti2Header returns a copy of the header of nii, with all fields that describe the file layout and the data set from the extensions and the volume.
+//
+// Parameters:
+//   - nii: the Nifti2 struct
+//
+// Returns:
+//   - Nifti2Header: the header to write
+//   - error: an error if the volume is empty or its data type cannot be written
+func prepareNifti2Header(nii Nifti2) (Nifti2Header, error) {
+	hdr := nii.Header
+	datatype, bitpix, err := getNiftiDataTypeForMri(nii.Volume.DataType())
+	if err != nil {
+		return hdr, err
+	}
+	if nii.Volume.Len() == 0 {
+		return hdr, fmt.Errorf("the volume contains no data, create it with NewVolume or NewVolumeFromMgh.")
+	}
+	hdr.SizeofHdr = 540
+	copy(hdr.Magic[:], NIFTI2_MAGIC)
+	hdr.Datatype = datatype
+	hdr.Bitpix = bitpix
+	hdr.Dim = getNiftiDim(nii.Volume.Shape(), hdr.Dim)
+	hdr.VoxOffset = 540 + getNiftiExtensionsSize(nii.Extensions)
+
+	// The volume contains the scaled values, so they must not be scaled again when the file is read.
+	if hdr.SclSlope != 0 {
+		hdr.SclSlope = 1
+		hdr.SclInter = 0
+	}
+	return hdr, nil
+}
+
+// WriteNifti2 writes a Nifti2 struct to a file in NIfTI-2 format.
+//
+// The file is written in little endian byte order, with the extensions and the data of nii.Volume. The fields of the header that
+// describe the layout of the file and the data (like Datatype, Bitpix, Dim and VoxOffset) are set from the volume and the extensions,
+// all other fields are written as they are. If the dimensions in the header match the shape of the volume, they are kept, so
+// dimensions above 4 (like in CIFTI files) are preserved. The data scaling is reset, because the volume contains scaled values.
+//
+// Parameters:
+//   - filepath: path to the output file, e.g. 'data.nii' or 'data.nii.gz'. The directory must exist.
+//   - nii: the Nifti2 struct to write
+//   - compress: Whether to write gzip-compressed data. If "auto", the data is compressed if the file extension is '.gz'. If not "auto", it has to be "yes" or "no".
+//
+// Returns:
+//   - error: an error if one occurred, nil otherwise
+func WriteNifti2(filepath string, nii Nifti2, compress string) error {
+	if !(compress == "yes" || compress == "no" || compress == "auto") {
+		return fmt.Errorf("WriteNifti2: invalid value '%s' for parameter compress, must be one of 'yes', 'no' or 'auto'.", compress)
+	}
+
+	if _, err := prepareNifti2Header(nii); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("WriteNifti2: could not create NIfTI-2 file '%s': %w", filepath, err)
+	}
+	defer file.Close()
+
+	if err := WriteNifti2To(file, nii, getIsGzipped(filepath, compress)); err != nil {
+		return fmt.Errorf("WriteNifti2: failed to write NIfTI-2 file '%s': %w", filepath, err)
+	}
+	return file.Sync()
+}
+
+// WriteNifti2To writes a Nifti2 struct to w in NIfTI-2 format.
+//
+// This is the io.Writer version of WriteNifti2, see there for details.
+//
+// Parameters:
+//   - w: the writer, e.g., an *os.File or a *bytes.Buffer
+//   - nii: the Nifti2 struct to write
+//   - compress: Whether to write gzip-compressed data.
+//
+// Returns:
+//   - error: an error if one occurred, nil otherwise
+func WriteNifti2To(w io.Writer, nii Nifti2, compress bool) error {
+	hdr, err := prepareNifti2Header(nii)
+	if err != nil {
+		return err
+	}
+
+	var gzipWriter *gzip.Writer
+	if compress {
+		gzipWriter = gzip.NewWriter(w)
+		w = gzipWriter
+	}
+	bw := bufio.NewWriter(w)
+	order := binary.LittleEndian
+
+	if err := binary.Write(bw, order, &hdr); err != nil {
+		return fmt.Errorf("WriteNifti2To: failed to write NIfTI-2 header: %w", err)
+	}
+	if err := writeNiftiExtensions(bw, order, nii.Extensions); err != nil {
+		return fmt.Errorf("WriteNifti2To: failed to write NIfTI-2 extensions: %w", err)
+	}
+	if err := writeNiftiData(bw, order, nii.Volume); err != nil {
+		return fmt.Errorf("WriteNifti2To: failed to write NIfTI-2 data: %w", err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if gzipWriter != nil {
+		return gzipWriter.Close()
+	}
+	return nil
+}
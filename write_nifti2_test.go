@@ -0,0 +1,108 @@
+package neuro
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func getTestNifti2() Nifti2 {
+	volume, _ := NewVolumeFromMgh(getTestMgh4D())
+	hdr := Nifti2Header{QformCode: 1, SformCode: 1, Pixdim: [8]float64{1, 2, 2, 2, 1, 1, 1, 1}}
+	hdr.SrowX = [4]float64{2, 0, 0, -10}
+	hdr.SrowY = [4]float64{0, 2, 0, -20}
+	hdr.SrowZ = [4]float64{0, 0, 2, -30}
+	copy(hdr.Descrip[:], "written by neuro tests")
+	extensions := []NiftiExtension{{Code: 6, Data: []byte("a comment that needs padding")}}
+	return Nifti2{Header: hdr, Extensions: extensions, Volume: volume}
+}
+
+func TestWriteRereadNifti2InMemory(t *testing.T) {
+	nii := getTestNifti2()
+
+	var buf bytes.Buffer
+	if err := WriteNifti2To(&buf, nii, false); err != nil {
+		t.Fatalf("WriteNifti2To failed: %v", err)
+	}
+
+	// 540 bytes header, 4 bytes extender, 48 bytes extension block (8 + 28 bytes padded to 48), 120 float32 values.
+	if buf.Len() != 540+4+48+120*4 {
+		t.Errorf("got %d bytes, wanted %d", buf.Len(), 540+4+48+120*4)
+	}
+
+	reread, err := ReadNifti2From(&buf)
+	if err != nil {
+		t.Fatalf("ReadNifti2From failed: %v", err)
+	}
+	if diff := cmp.Diff(nii.Volume.AsFloat32(), reread.Volume.AsFloat32()); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([4]int{4, 3, 2, 5}, reread.Volume.Shape()); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([8]int64{4, 4, 3, 2, 5, 1, 1, 1}, reread.Header.Dim); diff != "" {
+		t.Error(diff)
+	}
+	if reread.Header.VoxOffset != 592 || reread.Header.Datatype != NIFTI_TYPE_FLOAT32 || reread.Header.Bitpix != 32 {
+		t.Errorf("got VoxOffset=%d, Datatype=%d, Bitpix=%d, wanted 592, %d, 32", reread.Header.VoxOffset, reread.Header.Datatype, reread.Header.Bitpix, NIFTI_TYPE_FLOAT32)
+	}
+	if reread.Header.Description() != "written by neuro tests" {
+		t.Errorf("got description '%s', wanted '%s'", reread.Header.Description(), "written by neuro tests")
+	}
+	checkMatrixAlmostEqual(t, "Vox2Ras", reread.Header.Vox2Ras(), nii.Header.Vox2Ras())
+
+	wantExtensions := []NiftiExtension{{Code: 6, Data: append([]byte("a comment that needs padding"), make([]byte, 12)...)}}
+	if diff := cmp.Diff(wantExtensions, reread.Extensions); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWriteRereadNifti2File(t *testing.T) {
+	nii := getTestNifti2()
+	nii.Header.SclSlope = 2.0
+	nii.Header.SclInter = 1.0
+
+	// Dimensions above 4 are preserved if they match the volume shape.
+	nii.Header.Dim = [8]int64{5, 4, 3, 2, 1, 5, 1, 1}
+
+	for _, fileName := range []string{"vol.nii", "vol.nii.gz"} {
+		niiFile := filepath.Join(t.TempDir(), fileName)
+		if err := WriteNifti2(niiFile, nii, "auto"); err != nil {
+			t.Fatalf("WriteNifti2 failed: %v", err)
+		}
+		reread, err := ReadNifti2(niiFile)
+		if err != nil {
+			t.Fatalf("ReadNifti2 failed: %v", err)
+		}
+		if diff := cmp.Diff(nii.Header.Dim, reread.Header.Dim); diff != "" {
+			t.Error(diff)
+		}
+		// The scaling is reset, so the values are not scaled twice.
+		if reread.Header.SclSlope != 1.0 || reread.Header.SclInter != 0.0 {
+			t.Errorf("got SclSlope=%f, SclInter=%f, wanted 1 and 0", reread.Header.SclSlope, reread.Header.SclInter)
+		}
+		if diff := cmp.Diff(nii.Volume.Data(), reread.Volume.Data()); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestWriteNifti2Invalid(t *testing.T) {
+	if err := WriteNifti2To(&bytes.Buffer{}, Nifti2{}, false); err == nil {
+		t.Errorf("expected error for empty volume, got nil")
+	}
+
+	nii := getTestNifti2()
+	if err := WriteNifti2(filepath.Join(t.TempDir(), "vol.nii"), nii, "maybe"); err == nil {
+		t.Errorf("expected error for invalid compress value, got nil")
+	}
+
+	nii.Volume = Volume{data: MghData{MghDataType: MRI_TENSOR}, shape: [4]int{1, 1, 1, 1}}
+	var dtErr *UnsupportedMghDataTypeError
+	if err := WriteNifti2To(&bytes.Buffer{}, nii, false); !errors.As(err, &dtErr) {
+		t.Errorf("expected UnsupportedMghDataTypeError, got %v", err)
+	}
+}